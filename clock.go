@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Clock provides the current time to time-based metrics.
+// A Registry passes its Clock to each Meter and Distribution it
+// creates. Tests may substitute a manually-advanced Clock, such as
+// the one in the metricstest package.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock used by default. It returns time.Now().
+var SystemClock Clock = systemClock{}
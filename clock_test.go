@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"math"
+	"metrics/metricstest"
+	"testing"
+	"time"
+)
+
+func TestRegistryClockMeter(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	r := NewRegistryClock("testRegistry", c)
+	m := r.NewMeter(testRegistryType{}, "meter")
+
+	m.Set(0)
+	c.Add(time.Minute)
+	m.Set(60)
+
+	s := m.Snapshot()
+	if !s.LastUpdated.Equal(testTime.Add(time.Minute)) {
+		t.Errorf("Meter updated time is %v, expected %v",
+			s.LastUpdated, testTime.Add(time.Minute))
+	}
+	// the rate is 1/s, and the 1 minute average moves halfway to it
+	if math.Abs(s.Derivatives[1][0]-1.0) > 1e-13 {
+		t.Errorf("Meter rate is %f, expected %f", s.Derivatives[1][0], 1.0)
+	}
+	if math.Abs(s.Derivatives[1][1]-0.5) > 1e-13 {
+		t.Errorf("Meter 1 minute rate is %f, expected %f",
+			s.Derivatives[1][1], 0.5)
+	}
+}
+
+func TestRegistryClockDistribution(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	r := NewRegistryClock("testRegistry", c)
+	d := r.NewDistribution(testRegistryType{}, "distribution")
+	d.SetWindow(time.Minute)
+
+	d.Add(1)
+	c.Add(30 * time.Second)
+	d.Add(2)
+
+	s := d.Snapshot()
+	if s.Count != 2 {
+		t.Errorf("Wrong count, got %d expected %d", s.Count, 2)
+	}
+	if !s.LastUpdated.Equal(testTime.Add(30 * time.Second)) {
+		t.Errorf("Distribution updated time is %v, expected %v",
+			s.LastUpdated, testTime.Add(30*time.Second))
+	}
+
+	c.Add(45 * time.Second)
+	s = d.Snapshot()
+	if s.Count != 1 {
+		t.Errorf("Wrong count after pruning, got %d expected %d", s.Count, 1)
+	}
+
+	c.Add(time.Minute)
+	d.Prune()
+	if d.Snapshot().Count != 0 {
+		t.Errorf("Wrong count after pruning everything, got %d expected %d",
+			d.Snapshot().Count, 0)
+	}
+}
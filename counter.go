@@ -1,35 +1,87 @@
 package metrics
 
 import (
-	"sync"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 // Counter contains a single int64, which may be incremented,
-// decremented or set.
+// decremented or set. All operations are atomic and lock-free.
+//
+// A striped Counter, created by NewStripedCounter, spreads increments
+// over several cache-line-sized cells, which are summed when a
+// CounterSnapshot is taken. This avoids contention on a single
+// cache line when many goroutines increment the same Counter, at
+// the cost of slower snapshots and a Set that is not atomic with
+// respect to concurrent increments.
+//
+// A Counter's value accumulates from its start time, when it was
+// created or last reset, which is reported to exporters of cumulative
+// sums such as OTLP.
 type Counter struct {
 	value int64
-	lock  sync.RWMutex
+	// start is the start time, in nanoseconds since the Unix epoch
+	start int64
+	cells []counterCell
+	mask  uint32
+	clock Clock
 }
 
+// counterCell is padded to occupy a full cache line, so that cells
+// incremented on different processors do not share one.
+type counterCell struct {
+	value int64
+	_     [56]byte
+}
+
+const maxCounterCells = 256
+
 type CounterSnapshot struct {
 	Value int64
 }
 
-func newCounter() *Counter {
-	return &Counter{}
+func newCounter(clock Clock) *Counter {
+	return &Counter{
+		start: clock.Now().UnixNano(),
+		clock: clock,
+	}
 }
 
-// Reset sets the Counter to zero.
+func newStripedCounter(clock Clock) *Counter {
+	n := 1
+	for n < 2*runtime.GOMAXPROCS(0) && n < maxCounterCells {
+		n *= 2
+	}
+	return &Counter{
+		start: clock.Now().UnixNano(),
+		cells: make([]counterCell, n),
+		mask:  uint32(n - 1),
+		clock: clock,
+	}
+}
+
+// Reset sets the Counter to zero, and its start time to now.
 func (c *Counter) Reset() {
-	c.lock.Lock()
-	c.value = 0
-	c.lock.Unlock()
+	atomic.StoreInt64(&c.start, c.clock.Now().UnixNano())
+	c.Set(0)
+}
+
+// Start returns the Counter's start time, when it was created or last
+// reset, from which its value accumulates.
+func (c *Counter) Start() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.start))
 }
 
 func (c *Counter) Inc(v int64) {
-	c.lock.Lock()
-	c.value += v
-	c.lock.Unlock()
+	if c.cells != nil {
+		// rand.Uint32 uses per-thread state, so choosing a cell
+		// is itself free of contention.
+		atomic.AddInt64(&c.cells[rand.Uint32()&c.mask].value, v)
+		return
+	}
+	atomic.AddInt64(&c.value, v)
 }
 
 func (c *Counter) Dec(v int64) {
@@ -37,19 +89,20 @@ func (c *Counter) Dec(v int64) {
 }
 
 func (c *Counter) Set(v int64) {
-	c.lock.Lock()
-	c.value = v
-	c.lock.Unlock()
+	atomic.StoreInt64(&c.value, v)
+	for i := range c.cells {
+		atomic.StoreInt64(&c.cells[i].value, 0)
+	}
 }
 
 // Snapshot returns the Counter's value.
 func (c *Counter) Snapshot() CounterSnapshot {
-	c.lock.RLock()
-
-	r := CounterSnapshot{
-		Value: c.value,
+	v := atomic.LoadInt64(&c.value)
+	for i := range c.cells {
+		v += atomic.LoadInt64(&c.cells[i].value)
 	}
 
-	c.lock.RUnlock()
-	return r
+	return CounterSnapshot{
+		Value: v,
+	}
 }
@@ -1,11 +1,17 @@
 package metrics
 
 import (
+	"bytes"
+	"fmt"
+	"metrics/metricstest"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 )
 
 func testCounterInit() *Counter {
-	c := newCounter()
+	c := newCounter(SystemClock)
 	c.Set(1357)
 	return c
 }
@@ -18,3 +24,124 @@ func TestCounterInc(t *testing.T) {
 		t.Errorf("Counter incremented to %d, expected %d", s.Value, 1367)
 	}
 }
+
+func TestStripedCounter(t *testing.T) {
+	c := newStripedCounter(SystemClock)
+	c.Set(1357)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			for j := 0; j < 1000; j++ {
+				c.Inc(2)
+				c.Dec(1)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	s := c.Snapshot()
+	if s.Value != 9357 {
+		t.Errorf("Striped counter incremented to %d, expected %d",
+			s.Value, 9357)
+	}
+
+	c.Set(10)
+	s = c.Snapshot()
+	if s.Value != 10 {
+		t.Errorf("Striped counter set to %d, expected %d", s.Value, 10)
+	}
+
+	c.Reset()
+	s = c.Snapshot()
+	if s.Value != 0 {
+		t.Errorf("Striped counter reset to %d, expected %d", s.Value, 0)
+	}
+}
+
+// testMutexCounter is the Counter implementation prior to the
+// use of sync/atomic, kept for comparison in benchmarks.
+type testMutexCounter struct {
+	value int64
+	lock  sync.RWMutex
+}
+
+func (c *testMutexCounter) Inc(v int64) {
+	c.lock.Lock()
+	c.value += v
+	c.lock.Unlock()
+}
+
+var testBenchmarkProcs = []int{1, 2, 4, 8, 16}
+
+func benchmarkCounterInc(b *testing.B, inc func(int64)) {
+	for _, procs := range testBenchmarkProcs {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					inc(1)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCounterIncMutex(b *testing.B) {
+	c := &testMutexCounter{}
+	benchmarkCounterInc(b, c.Inc)
+}
+
+func BenchmarkCounterIncAtomic(b *testing.B) {
+	c := newCounter(SystemClock)
+	benchmarkCounterInc(b, c.Inc)
+}
+
+func BenchmarkCounterIncStriped(b *testing.B) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(
+		testBenchmarkProcs[len(testBenchmarkProcs)-1]))
+	c := newStripedCounter(SystemClock)
+	benchmarkCounterInc(b, c.Inc)
+}
+
+func BenchmarkCounterSnapshotStriped(b *testing.B) {
+	c := newStripedCounter(SystemClock)
+	for i := 0; i < b.N; i++ {
+		c.Snapshot()
+	}
+}
+
+func TestCounterStart(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := metricstest.NewClock(base)
+	c := newCounter(clock)
+	clock.Add(time.Minute)
+	c.Inc(3)
+	if s := c.Start(); !s.Equal(base) {
+		t.Errorf("Wrong start time, got %v expected %v", s, base)
+	}
+	c.Reset()
+	if s := c.Start(); !s.Equal(base.Add(time.Minute)) {
+		t.Errorf("Wrong start time after Reset, got %v expected %v",
+			s, base.Add(time.Minute))
+	}
+
+	r := NewRegistryClock("testRegistry", clock)
+	r.NewCounter(testRegistryType{}, "counter").Inc(2)
+	var buf bytes.Buffer
+	if err := r.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	clock.Add(time.Hour)
+	restored := NewRegistryClock("testRegistry", clock)
+	rc := restored.NewCounter(testRegistryType{}, "counter")
+	if err := restored.RestoreState(&buf); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+	if s := rc.Start(); !s.Equal(base.Add(time.Minute)) {
+		t.Errorf("Wrong restored start time, got %v expected %v",
+			s, base.Add(time.Minute))
+	}
+}
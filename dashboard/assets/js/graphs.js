@@ -1,6 +1,7 @@
 "use strict"
 
-function Heatmap(div, range){
+function Heatmap(div, range, format){
+  format = format || formatFixedLen
   var w = 345, wmargin = 60, h = 100, hmargin = 20
   var shiftw = 5, nbins = 20
   var newtime, oldtime
@@ -135,10 +136,10 @@ function Heatmap(div, range){
     ctx.save()
     ctx.translate(w, 0)
     ctx.clearRect(0, 0, wmargin, h + hmargin)
-    ctx.fillText(formatFixedLen(range[1]), 5, 20)
-    ctx.fillText(formatFixedLen((range[0] + range[1])/2),
+    ctx.fillText(format(range[1]), 5, 20)
+    ctx.fillText(format((range[0] + range[1])/2),
       5, Math.floor((h + 10)/2))
-    ctx.fillText(formatFixedLen(range[0]), 5, h - 10)
+    ctx.fillText(format(range[0]), 5, h - 10)
     ctx.restore()
     ctx.restore()
 
@@ -156,7 +157,8 @@ function Heatmap(div, range){
   }
 }
 
-function KDEGraph(div){
+function KDEGraph(div, format){
+  format = format || formatFixedLen
   var w = 345, h = 240, wmargin = 55, hmargin = 20
 
   var svg = div.append("svg").attr("id", "samples")
@@ -195,7 +197,7 @@ function KDEGraph(div){
 
     mousemoveline.style("display", "inline")
     var v = kdearr[x]
-    var str = "V: " + formatFixedLen(v[0])
+    var str = "V: " + format(v[0])
     str += " P: " + formatFixedLen(v[1])
     mousemovediv.text(str)
     mousemoveline.attr("x1", mouse[0]).attr("x2", mouse[0])
@@ -267,7 +269,7 @@ function KDEGraph(div){
       .attr("x", function(d, i){ return i * (w - 50)/2 })
       .attr("y", h + 10)
       .attr("dy", ".35em")
-    xlabels.text(formatFixedLen)
+    xlabels.text(format)
 
     updateMousemove()
   }
@@ -18,20 +18,15 @@ $(document).ready(function(){
   d3.select("#hidehides").on("click", togglehides)
     .each(togglehides)
 
-  $.getJSON("/list", {}, function(list){
-    list.sort()
-    var throwaway = []
-    for(var i in list){
-      throwaway.push(new MetricRowUpdater(list[i][0], list[i][1]))
-    }
-    MetricRowUpdater.updateAll()
+  $.getJSON(pathPrefix + "/list", {}, function(list, status, xhr){
+    MetricRowUpdater.sync(list, xhr.getResponseHeader("X-Metrics-Changes"))
 
     $("#graphs").masonry({
       itemSelector: ".graph",
       columnWidth: 410
     })
 
-    setInterval(function(){MetricRowUpdater.updateAll()}, tableInterval)
+    MetricRowUpdater.start()
     setInterval(function(){KDEGraphUpdater.updateAll()}, KDEInterval)
     setInterval(function(){HeatmapUpdater.updateAll()}, heatmapInterval)
   })
@@ -50,6 +45,7 @@ function MetricRow(name){
   this.row = null
   this.name = name
   this.data = null
+  this.meta = {}
 
   this.getDataFunc = function(){
     var m = this
@@ -71,13 +67,44 @@ function MetricRow(name){
     })
   }
 
+  // describe sets the metric's metadata, shown as a tooltip
+  this.describe = function(meta){
+    this.meta = meta || {}
+    var tip = this.meta.Description || ""
+    if(this.meta.Unit)
+      tip += (tip ? " " : "") + "(" + this.meta.Unit + ")"
+    this.row.attr("title", tip || null)
+    this.graphdiv.select("h3#title").attr("title", tip || null)
+  }
+
+  // unitFormat returns a function formatting values in the metric's
+  // unit, or undefined if it has none
+  this.unitFormat = function(){
+    var unit = this.meta.Unit
+    if(!unit)
+      return undefined
+    return function(v){
+      if($.isNumeric(v))
+        v = formatFixedLen(v)
+      return v + " " + unit
+    }
+  }
+
+  // range returns the metric's expected range, or hint if it has none
+  this.range = function(hint){
+    var range = this.meta.Range
+    if(range && (range[0] != 0 || range[1] != 0))
+      return range
+    return hint
+  }
+
   this.clickableCell = function(onclick){
     return function(cell){
       cell.on("click", function(){onclick(cell)})
     }
   }
 
-  this.graphToggle = function(graphname, accessor, Constructor, color){
+  this.graphToggle = function(graphname, accessor, Constructor, color, format){
     var m = this
     return this.clickableCell(function(cell){
       if(m.graphs[graphname] != null){
@@ -94,11 +121,16 @@ function MetricRow(name){
       } else {
         if(m.graphcount == 0){
           m.graphdiv.style("padding", "5px")
-          m.graphdiv.append("h3").attr("id", "title").text(m.name)
+          var title = m.name
+          if(m.meta.Unit)
+            title += " (" + m.meta.Unit + ")"
+          m.graphdiv.append("h3").attr("id", "title").text(title)
+            .attr("title", m.meta.Description || null)
         }
         m.graphcount++
 
-        m.graphs[graphname] = new Constructor(graphname, accessor, m.graphdiv)
+        m.graphs[graphname] = new Constructor(graphname, accessor, m.graphdiv,
+          format || m.unitFormat())
 
         cell.style("background-color", color)
       }
@@ -116,9 +148,14 @@ function MetricRow(name){
 
 MetricRowUpdater.metrics = []
 
-function MetricRowUpdater(name, type){
+function MetricRowUpdater(name, type, meta){
   var row
+  this.name = name
+  this.type = type
+  this.meta = meta
   this.update = function(allmap){
+    if(!show || allmap[name] == null)
+      return
     row.data = allmap[name].Value
     row.draw()
   }
@@ -132,9 +169,17 @@ function MetricRowUpdater(name, type){
       t.hide()
     else
       t.display()
+    MetricRowUpdater.refresh()
   })
 
-  hider.append("span").text(name)
+  var label = hider.append("span").text(name)
+
+  this.describe = function(meta){
+    this.meta = meta
+    label.attr("title", meta && meta.Description || null)
+    if(show)
+      row.describe(meta)
+  }
 
   this.display = function(){
     show = true
@@ -144,16 +189,31 @@ function MetricRowUpdater(name, type){
     case "counter":
       row = new CounterRow(name)
       break
+    case "digest":
+      row = new DigestRow(name)
+      break
     case "distribution":
       row = new DistributionRow(name)
       break
     case "gauge":
       row = new GaugeRow(name)
       break
+    case "intgauge":
+    case "floatgauge":
+      row = new NumericGaugeRow(name)
+      break
     case "meter":
       row = new MeterRow(name)
       break
+    case "timer":
+      row = new TimerRow(name)
+      break
     }
+    row.describe(t.meta)
+  }
+
+  this.shown = function(){
+    return show
   }
 
   this.hide = function(){
@@ -163,18 +223,143 @@ function MetricRowUpdater(name, type){
     row.destroy()
   }
 
+  this.remove = function(){
+    if(show)
+      row.destroy()
+    show = false
+    hider.remove()
+  }
+
   this.display()
+  this.describe(meta)
 
   MetricRowUpdater.metrics.push(this)
 }
 
-MetricRowUpdater.updateAll = function(){
+MetricRowUpdater.changes = null
+
+// sync adds rows for metrics in list that have none, removes rows
+// for metrics that are no longer registered, and updates the metadata
+// of the others.
+MetricRowUpdater.sync = function(list, changes){
+  MetricRowUpdater.changes = changes
+
+  var listed = {}
+  for(var i in list){
+    listed[list[i][0]] = list[i]
+  }
+
+  var kept = [], known = {}
+  var metrics = MetricRowUpdater.metrics
+  for(var i in metrics){
+    var m = metrics[i]
+    var l = listed[m.name]
+    if(l && l[1] === m.type){
+      m.describe(l[2])
+      kept.push(m)
+      known[m.name] = true
+    } else {
+      m.remove()
+    }
+  }
+  MetricRowUpdater.metrics = kept
+
+  list.sort()
+  for(var i in list){
+    if(!known[list[i][0]]){
+      new MetricRowUpdater(list[i][0], list[i][1], list[i][2])
+    }
+  }
+}
+
+MetricRowUpdater.stream = null
+MetricRowUpdater.polling = false
+
+// start updates the rows from /stream, or by polling /all if the
+// browser does not support Server-Sent Events
+MetricRowUpdater.start = function(){
+  if(window.EventSource)
+    MetricRowUpdater.subscribe()
+  else
+    MetricRowUpdater.poll()
+}
+
+MetricRowUpdater.poll = function(){
+  if(MetricRowUpdater.polling)
+    return
+  MetricRowUpdater.polling = true
+  MetricRowUpdater.updateAll()
+  setInterval(function(){MetricRowUpdater.updateAll()}, tableInterval)
+}
+
+// subscribe streams the shown metrics from /stream, replacing any
+// previous stream, and falls back to polling if the server refuses it
+MetricRowUpdater.subscribe = function(){
+  if(MetricRowUpdater.stream)
+    MetricRowUpdater.stream.close()
+
+  var query = "interval=" + tableInterval + "ms"
+  var names = 0
   var metrics = MetricRowUpdater.metrics
-  $.getJSON("/all", null, function(d){
-    for(var i in metrics){
-      metrics[i].update(d)
+  for(var i in metrics){
+    if(metrics[i].shown()){
+      query += "&name=" + encodeURIComponent(metrics[i].name)
+      names++
     }
+  }
+  // without any name, every metric would be streamed
+  if(names == 0)
+    query += "&name="
+
+  var stream = new EventSource(pathPrefix + "/stream?" + query)
+  stream.addEventListener("metrics", function(e){
+    var d = JSON.parse(e.data)
+    MetricRowUpdater.receive(d.Metrics, String(d.Changes))
   })
+  stream.onerror = function(){
+    // the browser reconnects by itself unless the stream was refused
+    if(stream.readyState == EventSource.CLOSED &&
+      stream === MetricRowUpdater.stream){
+
+      MetricRowUpdater.stream = null
+      MetricRowUpdater.poll()
+    }
+  }
+  MetricRowUpdater.stream = stream
+}
+
+// refresh updates the rows at once, after the metrics shown change
+MetricRowUpdater.refresh = function(){
+  if(MetricRowUpdater.polling)
+    MetricRowUpdater.updateAll()
+  else
+    MetricRowUpdater.subscribe()
+}
+
+MetricRowUpdater.updateAll = function(){
+  $.getJSON(pathPrefix + "/all", null, function(d, status, xhr){
+    MetricRowUpdater.receive(d, xhr.getResponseHeader("X-Metrics-Changes"))
+  })
+}
+
+// receive updates the rows from an object mapping metric names to
+// their values, unless the metrics have changed, in which case the
+// rows are synced with /list first
+MetricRowUpdater.receive = function(d, changes){
+  if(changes !== MetricRowUpdater.changes){
+    MetricRowUpdater.changes = changes
+    $.getJSON(pathPrefix + "/list", {}, function(list, status, xhr){
+      MetricRowUpdater.sync(list, xhr.getResponseHeader("X-Metrics-Changes"))
+      $("#graphs").masonry("reload")
+      MetricRowUpdater.refresh()
+    })
+    return
+  }
+
+  var metrics = MetricRowUpdater.metrics
+  for(var i in metrics){
+    metrics[i].update(d)
+  }
 }
 
 function CounterRow(name){
@@ -194,7 +379,8 @@ function CounterRow(name){
   this.makeToggles = function(){
     var row = [
       null,
-      this.graphToggle("Value", function(){return data().Value},
+      this.graphToggle("Value",
+        historyAccessor(name, "Value", function(){return data().Value}),
         CubismGraph, "yellow"),
     ]
 
@@ -205,26 +391,69 @@ function CounterRow(name){
   this.makeToggles()
 }
 
+function DigestRow(name){
+  MetricRow.apply(this)
+  this.name = name
+  var data = this.getDataFunc()
+
+  var cubismToggles = [
+    ["Count", "Count", function(){return data().Count}],
+    ["Mean", "Mean", function(){return data().Mean}],
+    ["Min", "Percentiles.0", function(){return data().Percentiles[0]}],
+    ["Max", "Percentiles.1", function(){return data().Percentiles[7]}],
+    ["Median", "Percentiles.0.5", function(){return data().Percentiles[2]}],
+    ["25%", "Percentiles.0.25", function(){return data().Percentiles[1]}],
+    ["75%", "Percentiles.0.75", function(){return data().Percentiles[3]}],
+    ["95%", "Percentiles.0.95", function(){return data().Percentiles[4]}],
+    ["99%", "Percentiles.0.99", function(){return data().Percentiles[5]}],
+    ["99.9%", "Percentiles.0.999", function(){return data().Percentiles[6]}],
+  ].map(function(t){return [t[0], historyAccessor(name, t[1], t[2])]})
+
+  this.draw = function(){
+    var row = [this.name]
+    for(var i = 0; i < cubismToggles.length; i++){
+      row.push(cubismToggles[i][1]())
+    }
+
+    this.drawRow(row)
+  }
+
+  this.makeToggles = function(){
+    var row = [null]
+    for(var i = 0; i < cubismToggles.length; i++){
+      row.push(this.graphToggle(cubismToggles[i][0],
+        cubismToggles[i][1], CubismGraph, "lightblue"))
+    }
+
+    this.drawRow(row)
+  }
+
+  this.row = d3.select("#digests").append("tr")
+  this.makeToggles()
+}
+
 function DistributionRow(name){
   MetricRow.apply(this)
   this.name = name
   var data = this.getDataFunc()
 
   var cubismToggles = [
-    ["Mean", function(){return data().Mean}],
-    ["Variance", function(){return data().Variance}],
-    ["StdDev", function(){return data().StandardDeviation}],
-    ["Skewness", function(){return data().Skewness}],
-    ["Kurtosis", function(){return data().Kurtosis}],
-    ["Min", function(){return data().Percentiles[0]}],
-    ["Max", function(){return data().Percentiles[7]}],
-    ["Median", function(){return data().Percentiles[2]}],
-    ["25%", function(){return data().Percentiles[1]}],
-    ["75%", function(){return data().Percentiles[3]}],
-    ["95%", function(){return data().Percentiles[4]}],
-    ["99%", function(){return data().Percentiles[5]}],
-    ["99.9%", function(){return data().Percentiles[6]}],
-  ]
+    ["Mean", "Mean", function(){return data().Mean}],
+    ["Variance", "Variance", function(){return data().Variance}],
+    ["StdDev", "StandardDeviation",
+      function(){return data().StandardDeviation}],
+    ["Skewness", "Skewness", function(){return data().Skewness}],
+    ["Kurtosis", "Kurtosis", function(){return data().Kurtosis}],
+    ["Min", 0], ["Max", 1], ["Median", 0.5], ["25%", 0.25], ["75%", 0.75],
+    ["95%", 0.95], ["99%", 0.99], ["99.9%", 0.999],
+  ].map(function(t){
+    if($.isNumeric(t[1])){
+      var q = t[1]
+      return [t[0], historyAccessor(name, "Percentiles." + q,
+        function(){return percentile(data(), q)})]
+    }
+    return [t[0], historyAccessor(name, t[1], t[2])]
+  })
 
   this.draw = function(){
     var name = this.name
@@ -238,11 +467,13 @@ function DistributionRow(name){
 
   this.makeToggles = function(){
     var name = this.name
+    var m = this
     var histogramAccessor = function(){
       return {
           name: name,
           window: data().Window,
-          range: data().RangeHint
+          range: m.range(data().RangeHint),
+          format: m.unitFormat()
       }
     }
 
@@ -282,6 +513,30 @@ function GaugeRow(name){
   this.row = d3.select("#gauges").append("tr")
 }
 
+function NumericGaugeRow(name){
+  MetricRow.apply(this)
+  this.name = name
+  var data = this.getDataFunc()
+
+  this.draw = function(){
+    this.drawRow([this.name, data().Value])
+  }
+
+  this.makeToggles = function(){
+    var row = [
+      null,
+      this.graphToggle("Value",
+        historyAccessor(name, "Value", function(){return data().Value}),
+        CubismGraph, "orange"),
+    ]
+
+    this.drawRow(row)
+  }
+
+  this.row = d3.select("#gauges").append("tr")
+  this.makeToggles()
+}
+
 function MeterRow(name){
   MetricRow.apply(this)
   this.name = name
@@ -289,14 +544,16 @@ function MeterRow(name){
 
   var toggles = [
     ["Value", function(){return data().Value}],
-    ["1 min avg", function(){return data().Derivatives[0][1]}],
-    ["5 min avg", function(){return data().Derivatives[0][2]}],
-    ["15 min avg", function(){return data().Derivatives[0][3]}],
-    ["instant rate", function(){return data().Derivatives[1][0]}],
-    ["1 min rate", function(){return data().Derivatives[1][1]}],
-    ["5 min rate", function(){return data().Derivatives[1][2]}],
-    ["15 min rate", function(){return data().Derivatives[1][3]}]
-  ]
+    ["1 min avg", 0, 1], ["5 min avg", 0, 2], ["15 min avg", 0, 3],
+    ["instant rate", 1, 0], ["1 min rate", 1, 1], ["5 min rate", 1, 2],
+    ["15 min rate", 1, 3]
+  ].map(function(t){
+    if(t.length == 2)
+      return [t[0], historyAccessor(name, "Value", t[1])]
+    var i = t[1], j = t[2]
+    return [t[0], historyAccessor(name, "Derivatives." + i + "." + j,
+      function(){return data().Derivatives[i][j]})]
+  })
 
   this.draw = function(){
     var row = [this.name]
@@ -324,6 +581,81 @@ function MeterRow(name){
   this.makeToggles()
 }
 
+function TimerRow(name){
+  MetricRow.apply(this)
+  this.name = name
+  var data = this.getDataFunc()
+  var format = function(t){
+    if(t == null)
+      return ""
+    return durationFormatter(data().Unit)(t)
+  }
+
+  var durationToggles = [
+    ["Mean", "Mean", function(){return data().Distribution.Mean}],
+    ["StdDev", "StandardDeviation",
+      function(){return data().Distribution.StandardDeviation}],
+    ["Min", 0], ["Median", 0.5], ["75%", 0.75], ["95%", 0.95],
+    ["99%", 0.99], ["99.9%", 0.999], ["Max", 1],
+  ].map(function(t){
+    if($.isNumeric(t[1])){
+      var q = t[1]
+      return [t[0], historyAccessor(name, "Distribution.Percentiles." + q,
+        function(){return percentile(data().Distribution, q)})]
+    }
+    return [t[0], historyAccessor(name, "Distribution." + t[1], t[2])]
+  })
+  var rateToggles = [
+    ["Count", historyAccessor(name, "Meter.Value",
+      function(){return data().Meter.Value})],
+    ["1 min rate", historyAccessor(name, "Meter.Derivatives.1.1",
+      function(){return data().Meter.Derivatives[1][1]})],
+  ]
+
+  this.draw = function(){
+    var row = [this.name]
+    for(var i = 0; i < rateToggles.length; i++){
+      row.push(rateToggles[i][1]())
+    }
+    for(var i = 0; i < durationToggles.length; i++){
+      row.push(format(durationToggles[i][1]()))
+    }
+
+    this.drawRow(row)
+  }
+
+  this.makeToggles = function(){
+    var name = this.name
+    var m = this
+    var histogramAccessor = function(){
+      return {
+          name: name,
+          window: data().Distribution.Window,
+          range: m.range(data().Distribution.RangeHint),
+          format: format
+      }
+    }
+
+    var row = [
+      this.graphToggle("heatmap", histogramAccessor,
+        HeatmapUpdater, "orange"),
+      this.graphToggle("samples", histogramAccessor,
+        KDEGraphUpdater, "orange")
+    ]
+    row.push(this.graphToggle(rateToggles[1][0], rateToggles[1][1],
+      CubismGraph, "orange"))
+    for(var i = 0; i < durationToggles.length; i++){
+      row.push(this.graphToggle(durationToggles[i][0],
+        durationToggles[i][1], CubismGraph, "orange", format))
+    }
+
+    this.drawRow(row)
+  }
+
+  this.row = d3.select("#timers").append("tr")
+  this.makeToggles()
+}
+
 HeatmapUpdater.heatmaps = []
 
 function HeatmapUpdater(name, accessor, div){
@@ -331,7 +663,7 @@ function HeatmapUpdater(name, accessor, div){
 
   this.preload = function(arr){
     var u = this
-    $.getJSON("/metric",
+    $.getJSON(pathPrefix + "/metric",
       {"name" : accessor().name,
        "samples" : true,
        "limit" : 1000},
@@ -342,7 +674,7 @@ function HeatmapUpdater(name, accessor, div){
           var range = dataRange(d.Samples, 0.2, 0.8, 2)[0]
         }
 
-        heatmap = new Heatmap(div, range)
+        heatmap = new Heatmap(div, range, accessor().format)
         n = heatmap.getHRes()
 
         var w = accessor().window/1000000
@@ -368,7 +700,7 @@ function HeatmapUpdater(name, accessor, div){
     var newest = end
     var oldest = new Date(newest.getTime() - (n- 1) * interval)
 
-    $.getJSON("/metric",
+    $.getJSON(pathPrefix + "/metric",
       {"name" : accessor().name,
        "samples" : true,
        "limit" : 100,
@@ -410,7 +742,7 @@ HeatmapUpdater.updateAll = function(){
 var cubismContext = cubism.context()
     .serverDelay(1000).step(tableInterval).size(400)
 
-function CubismGraph(name, accessor, div){
+function CubismGraph(name, accessor, div, format){
   var context = cubismContext
 
   // Force Cubism to synchronously update
@@ -419,22 +751,38 @@ function CubismGraph(name, accessor, div){
   // 7 times. This is not good since the previous values
   // aren't stored on the server.
   var queue = []
+  var read = function(start, stop, step){
+    var res = []
+
+    queue.push(accessor())
+    if(queue.length > 7)
+      queue.shift()
+
+    for(var i = 0; start < stop; i++){
+      start += step
+      res.push(queue[i])
+    }
+    return res
+  }
+
+  // The first read covers the whole graph, so it is backfilled from
+  // the server's history of the metric, if it has one.
+  var history = accessor.history
   var metric = context.metric(
     function(start, stop, step, callback){
       start = +start
       stop = +stop
 
-      var res = []
-
-      queue.push(accessor())
-      if(queue.length > 7)
-        queue.shift()
-
-      for(var i = 0; start < stop; i++){
-        start += step
-        res.push(queue[i])
+      if(history == null){
+        callback(null, read(start, stop, step))
+        return
       }
-      callback(null, res)
+      var h = history
+      history = null
+      fetchHistory(h, start, stop, step, function(values){
+        var res = read(start, stop, step)
+        callback(null, values || res)
+      })
     }, name)
 
 
@@ -447,7 +795,7 @@ function CubismGraph(name, accessor, div){
     .data([metric]).enter()
     .append("div")
     .attr("class", "horizon")
-    .call(context.horizon())
+    .call(format ? context.horizon().format(format) : context.horizon())
 
   var rule = graph.append("div").data([]).attr("class", "rule")
     .call(context.rule())
@@ -466,11 +814,48 @@ function CubismGraph(name, accessor, div){
   }
 }
 
+// historyAccessor marks an accessor of a graphed value as reading the
+// field of the named metric, so that its graph can be backfilled
+function historyAccessor(name, field, accessor){
+  accessor.history = {name: name, field: field}
+  return accessor
+}
+
+// fetchHistory calls done with the averages of a field of a metric over
+// each step from start to stop, in milliseconds, or with null if the
+// server has no history of it
+function fetchHistory(history, start, stop, step, done){
+  $.getJSON(pathPrefix + "/history",
+    {
+     "name" : history.name,
+     "from" : new Date(start).toISOString(),
+     "to" : new Date(stop).toISOString(),
+     "step" : step + "ms"
+    },
+    function(d){
+      var points = d[history.field]
+      if(points == null || points.length == 0){
+        done(null)
+        return
+      }
+
+      var values = []
+      for(var t = start; t < stop; t += step)
+        values.push(NaN)
+      for(var i = 0; i < points.length; i++){
+        var j = Math.floor((Date.parse(points[i].Time) - start)/step)
+        if(j >= 0 && j < values.length)
+          values[j] = points[i].Avg
+      }
+      done(values)
+    }).fail(function(){done(null)})
+}
+
 function KDEGraphUpdater(name, accessor, div){
-  var graph = new KDEGraph(div)
+  var graph = new KDEGraph(div, accessor().format)
 
   this.update = function(){
-    $.getJSON("/metric",
+    $.getJSON(pathPrefix + "/metric",
       {"name" : accessor().name, "samples" : true, "limit" : 1000},
       function(d){
         graph.draw(d.Value.Samples, accessor().range)
@@ -501,26 +886,57 @@ KDEGraphUpdater.updateAll = function(){
   }
 }
 
+// returns the value of a distribution snapshot's percentile at
+// quantile q, or null if it has none
+function percentile(snapshot, q){
+  var p = snapshot.Percentiles
+  for(var i = 0; i < p.length; i++){
+    if(p[i].Quantile == q)
+      return p[i].Value
+  }
+  return null
+}
+
 function identity(d){
   return d
 }
 
+var durationUnits = [
+  [3600e9, "h"],
+  [60e9, "m"],
+  [1e9, "s"],
+  [1e6, "ms"],
+  [1e3, "us"],
+  [1, "ns"]
+]
+
+// formats a duration in nanoseconds in the largest unit
+// smaller than the duration
 function formatDuration(t){
   if(t == 0)
     return "0"
 
-  units = ["ns", "us", "ms"]
-  for(var i = 0; i < 3; i++){
-    if(t < 1000){
-      return t.toString() + units[i]
-    }
-    t /= 1000
+  for(var i = 0; i < durationUnits.length - 1; i++){
+    if(Math.abs(t) >= durationUnits[i][0])
+      return formatFixedLen(t / durationUnits[i][0]) + durationUnits[i][1]
+  }
+  return formatFixedLen(t) + "ns"
+}
+
+// returns a function formatting durations in nanoseconds in the
+// given unit, or in a unit suited to each duration if unit is 0
+function durationFormatter(unit){
+  if(!unit)
+    return formatDuration
+
+  var suffix = formatFixedLen(unit) + "ns"
+  for(var i = 0; i < durationUnits.length; i++){
+    if(durationUnits[i][0] == unit)
+      suffix = durationUnits[i][1]
   }
-  if(t < 60){
-    return t.toString() + "s"
+  return function(t){
+    return formatFixedLen(t / unit) + suffix
   }
-  t /= 60
-  return t.toString() + "m"
 }
 
 function formatFixedLen(n){
@@ -0,0 +1,234 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"metrics"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// errCredentials is returned by the Authorizers of this package when
+// a request's credentials are invalid.
+var errCredentials = errors.New("dashboard: invalid credentials")
+
+// An Authorizer authenticates the requests to a dashboard Handler.
+//
+// Authorize returns the name of the user making a request, or "" if
+// the request has no credentials, in which case it is served to an
+// anonymous viewer if the Handler's options allow it. It returns an
+// error if the request is denied, for instance because its credentials
+// are invalid, in which case the Handler responds with 401
+// Unauthorized.
+//
+// If an Authorizer also has a method Challenge() string, the Handler
+// sends its result in a WWW-Authenticate header when a request is
+// denied, so that browsers ask for credentials.
+type Authorizer interface {
+	Authorize(r *http.Request) (user string, err error)
+}
+
+// AuthorizerFunc adapts a function to an Authorizer.
+type AuthorizerFunc func(r *http.Request) (string, error)
+
+// Authorize calls f(r).
+func (f AuthorizerFunc) Authorize(r *http.Request) (string, error) {
+	return f(r)
+}
+
+type basicAuth struct {
+	realm string
+	// users maps user names to the SHA-256 sums of their passwords
+	users map[string][sha256.Size]byte
+}
+
+// BasicAuth returns an Authorizer of HTTP basic authentication, with
+// a realm shown by browsers and a map of user names to passwords.
+func BasicAuth(realm string, users map[string]string) Authorizer {
+	a := &basicAuth{
+		realm: realm,
+		users: make(map[string][sha256.Size]byte),
+	}
+	for user, password := range users {
+		a.users[user] = sha256.Sum256([]byte(password))
+	}
+	return a
+}
+
+func (a *basicAuth) Authorize(r *http.Request) (string, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", nil
+	}
+	// compare sums in constant time, whatever the password's length
+	expected, known := a.users[user]
+	sum := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(sum[:], expected[:]) != 1 || !known {
+		return "", errCredentials
+	}
+	return user, nil
+}
+
+func (a *basicAuth) Challenge() string {
+	return `Basic realm="` + strings.Replace(a.realm, `"`, `'`, -1) +
+		`", charset="UTF-8"`
+}
+
+type bearerAuth struct {
+	// tokens maps the SHA-256 sums of tokens to user names
+	tokens map[[sha256.Size]byte]string
+}
+
+// BearerAuth returns an Authorizer of bearer tokens, sent in an
+// Authorization header as "Bearer <token>", with a map of tokens to
+// the names of their users.
+func BearerAuth(tokens map[string]string) Authorizer {
+	a := &bearerAuth{tokens: make(map[[sha256.Size]byte]string)}
+	for token, user := range tokens {
+		a.tokens[sha256.Sum256([]byte(token))] = user
+	}
+	return a
+}
+
+func (a *bearerAuth) Authorize(r *http.Request) (string, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return "", nil
+	}
+	// tokens are looked up by their sums, so that the time taken does
+	// not tell how much of a token is right
+	user, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(h[7:])))]
+	if !ok {
+		return "", errCredentials
+	}
+	return user, nil
+}
+
+func (a *bearerAuth) Challenge() string {
+	return "Bearer"
+}
+
+// ClientCertAuth is an Authorizer of TLS client certificates, as
+// verified by a Server with a ClientCAFile. The user is the common name
+// of the certificate's subject.
+var ClientCertAuth Authorizer = AuthorizerFunc(func(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", nil
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+})
+
+type anyAuth []Authorizer
+
+// AnyAuth returns an Authorizer which accepts a request if any of the
+// given Authorizers authenticates its user, trying them in order. It
+// denies a request which none of them authenticates if one of them
+// denied it.
+func AnyAuth(authorizers ...Authorizer) Authorizer {
+	return anyAuth(authorizers)
+}
+
+func (a anyAuth) Authorize(r *http.Request) (string, error) {
+	var denied error
+	for _, auth := range a {
+		user, err := auth.Authorize(r)
+		if err != nil {
+			denied = err
+			continue
+		}
+		if user != "" {
+			return user, nil
+		}
+	}
+	return "", denied
+}
+
+func (a anyAuth) Challenge() string {
+	var challenges []string
+	for _, auth := range a {
+		if c, ok := auth.(interface{ Challenge() string }); ok {
+			challenges = append(challenges, c.Challenge())
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+// HiddenFromAnonymous returns a function for Options.Visible which
+// hides the metrics whose names, or the names of whose labelled
+// families, match any of the patterns, in the syntax of path.Match,
+// from anonymous viewers. Other metrics, and all metrics for
+// authenticated users, are visible. As in path.Match, * does not match
+// the / after the prefix of a sub-registry, so */* is needed to hide
+// its metrics. It panics if a pattern is malformed.
+func HiddenFromAnonymous(patterns ...string) func(user, name string) bool {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("dashboard: invalid pattern %q: %v", pattern, err))
+		}
+	}
+	return func(user, name string) bool {
+		if user != "" {
+			return true
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// userKey is the context key of the user of a request, as returned by
+// the Handler's Authorizer.
+type userKey struct{}
+
+// authorize authenticates a request with the handler's Authorizer. It
+// returns the request with its user, or nil if it is denied, in which
+// case it has responded.
+func (h *handler) authorize(w http.ResponseWriter,
+	r *http.Request) *http.Request {
+
+	if h.auth == nil {
+		return r
+	}
+	user, err := h.auth.Authorize(r)
+	if err != nil || (user == "" && !h.anonymous) {
+		if c, ok := h.auth.(interface{ Challenge() string }); ok {
+			if challenge := c.Challenge(); challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	return r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+}
+
+// visible reports whether the metric with the given name may be shown
+// to the user of a request. The members of a hidden family are hidden.
+func (h *handler) visible(r *http.Request, name string) bool {
+	if h.visibleTo == nil {
+		return true
+	}
+	user, _ := r.Context().Value(userKey{}).(string)
+	if !h.visibleTo(user, name) {
+		return false
+	}
+	family, _ := h.registry.LabelSet(name)
+	return family == name || h.visibleTo(user, family)
+}
+
+// find returns the metric with the given name, or nil if it is not
+// registered or not visible to the user of a request, so that hidden
+// metrics cannot be told from missing ones.
+func (h *handler) find(r *http.Request, name string) metrics.Metric {
+	if !h.visible(r, name) {
+		return nil
+	}
+	return h.registry.FindS(name)
+}
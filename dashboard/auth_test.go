@@ -0,0 +1,164 @@
+package dashboard
+
+import (
+	"bufio"
+	"metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAuthGet serves a GET request for target, with credentials set by
+// auth unless it is nil.
+func testAuthGet(h http.Handler, target string,
+	auth func(*http.Request)) *httptest.ResponseRecorder {
+
+	req := httptest.NewRequest("GET", target, nil)
+	if auth != nil {
+		auth(req)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func testBasic(user, password string) func(*http.Request) {
+	return func(r *http.Request) { r.SetBasicAuth(user, password) }
+}
+
+func testBearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestAuthorizers(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	basic := BasicAuth(`the "test" realm`, map[string]string{"alice": "secret"})
+	bearer := BearerAuth(map[string]string{"token": "bob"})
+
+	for _, test := range []struct {
+		name      string
+		auth      Authorizer
+		request   func(*http.Request)
+		code      int
+		challenge string
+	}{
+		{"basic without credentials", basic, nil, http.StatusUnauthorized,
+			`Basic realm="the 'test' realm", charset="UTF-8"`},
+		{"basic", basic, testBasic("alice", "secret"), http.StatusOK, ""},
+		{"basic with a wrong password", basic, testBasic("alice", "secrets"),
+			http.StatusUnauthorized, `Basic realm="the 'test' realm", charset="UTF-8"`},
+		{"basic with an unknown user", basic, testBasic("eve", "secret"),
+			http.StatusUnauthorized, `Basic realm="the 'test' realm", charset="UTF-8"`},
+		{"bearer without credentials", bearer, nil, http.StatusUnauthorized, "Bearer"},
+		{"bearer", bearer, testBearer("token"), http.StatusOK, ""},
+		{"bearer with a wrong token", bearer, testBearer("tokens"),
+			http.StatusUnauthorized, "Bearer"},
+		{"any without credentials", AnyAuth(basic, bearer), nil,
+			http.StatusUnauthorized,
+			`Basic realm="the 'test' realm", charset="UTF-8", Bearer`},
+		{"any with a token", AnyAuth(basic, bearer), testBearer("token"),
+			http.StatusOK, ""},
+		{"any with a wrong password", AnyAuth(basic, bearer),
+			testBasic("alice", "wrong"), http.StatusUnauthorized,
+			`Basic realm="the 'test' realm", charset="UTF-8", Bearer`},
+	} {
+		h := Handler(r, Options{Authorizer: test.auth})
+		w := testAuthGet(h, "/list", test.request)
+		if w.Code != test.code {
+			t.Errorf("Wrong status for %s, got %d expected %d",
+				test.name, w.Code, test.code)
+		}
+		if c := w.Header().Get("WWW-Authenticate"); c != test.challenge {
+			t.Errorf("Wrong challenge for %s, got %q expected %q",
+				test.name, c, test.challenge)
+		}
+	}
+}
+
+func TestAuthAnonymous(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "requests")
+	r.NewDigest(testType{}, "secret", 100).Add(1)
+	r.NewCounterVec(testType{}, "private").WithLabels(
+		metrics.Labels{"user": "x"}).Inc(1)
+	r.EnableHistory("*")
+	r.RecordHistory()
+	h := Handler(r, Options{
+		Authorizer: BasicAuth("test", map[string]string{"alice": "secret"}),
+		Anonymous:  true,
+		Visible:    HiddenFromAnonymous("*.secret", "*.private"),
+	})
+
+	// wrong credentials are denied, even if anonymous viewers are not
+	w := testAuthGet(h, "/list", testBasic("alice", "wrong"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status with a wrong password, got %d expected %d",
+			w.Code, http.StatusUnauthorized)
+	}
+
+	const secret = "dashboard.testType.secret"
+	for _, target := range []string{"/list", "/all", "/metrics"} {
+		anonymous := testAuthGet(h, target, nil)
+		if anonymous.Code != http.StatusOK {
+			t.Errorf("Wrong status of anonymous %s, got %d expected %d",
+				target, anonymous.Code, http.StatusOK)
+		}
+		if strings.Contains(anonymous.Body.String(), "secret") ||
+			strings.Contains(anonymous.Body.String(), "private") {
+
+			t.Errorf("Anonymous %s shows a hidden metric: %s",
+				target, anonymous.Body)
+		}
+		if !strings.Contains(anonymous.Body.String(), "requests") {
+			t.Errorf("Anonymous %s hides a visible metric: %s",
+				target, anonymous.Body)
+		}
+		if user := testAuthGet(h, target, testBasic("alice", "secret")); !strings.Contains(user.Body.String(), "secret") ||
+			!strings.Contains(user.Body.String(), "private") {
+
+			t.Errorf("Authenticated %s hides a metric: %s", target, user.Body)
+		}
+	}
+
+	member := `/metric?name=dashboard.testType.private{user="x"}`
+	if w := testAuthGet(h, member, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Wrong status of an anonymous hidden member, got %d expected %d",
+			w.Code, http.StatusNotFound)
+	}
+	if w := testAuthGet(h, member, testBasic("alice", "secret")); w.Code != http.StatusOK {
+		t.Errorf("Wrong status of an authenticated member, got %d expected %d",
+			w.Code, http.StatusOK)
+	}
+
+	for _, target := range []string{"/metric", "/history", "/sketch"} {
+		target += "?name=" + secret
+		if w := testAuthGet(h, target, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Wrong status of anonymous %s, got %d expected %d",
+				target, w.Code, http.StatusNotFound)
+		}
+		if w := testAuthGet(h, target, testBasic("alice", "secret")); w.Code != http.StatusOK {
+			t.Errorf("Wrong status of authenticated %s, got %d expected %d",
+				target, w.Code, http.StatusOK)
+		}
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+	resp := testStream(t, server.URL+"/stream")
+	defer resp.Body.Close()
+	_, e := testReadEvent(t, bufio.NewReader(resp.Body))
+	if _, ok := e.Metrics[secret]; ok || len(e.Metrics) != 1 {
+		t.Errorf("Wrong anonymous stream, got %v expected only the requests",
+			e.Metrics)
+	}
+}
+
+func TestHiddenFromAnonymousPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("HiddenFromAnonymous accepted a malformed pattern")
+		}
+	}()
+	HiddenFromAnonymous("*.secret", "[")
+}
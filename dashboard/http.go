@@ -4,51 +4,151 @@
 // aid visualization.
 //
 // /list lists the metrics currently registered, as a JSON array
-// of arrays, each containing a metric name and its type.
+// of arrays, each containing a metric name and its type, followed by
+// its metadata if it has any, as an object with Description, Unit and
+// Range keys.
+// Responses from /list and /all have an X-Metrics-Changes header
+// counting the metrics registered and unregistered since the server
+// started. When it changes, the list has changed.
 //
 // /metric returns a JSON representations of metrics.
 // There is one required parameter in the query string:
 //	name: Name of the metric
-// For Distribution metrics, there are four more optional parameters:
+// For Distribution and Timer metrics, there are five more optional
+// parameters:
 //	samples: A boolean that returns a Distribution's samples if true.
-//	begin, end, limit: These options are passed to Distribution#Samples
+//	begin, end, limit: These options are passed to Distribution#FloatSamples
 // if samples is true. begin and end must be encoded as RFC3339 timestamps.
+//	q: Quantiles between 0 and 1, separated by commas or given as
+// several q parameters, whose values replace the Distribution's
+// percentiles, as computed by Distribution#Quantiles.
 //
 // The return format is always a JSON object with two keys: Type and Value.
 // Type's value is the same type as the metric (lowercase), or
-// "distribution_samples" if the metric is a Distribution and samples is true.
+// "distribution_samples" if the metric is a Distribution or Timer and samples
+// is true.
 // Value's value is the serialized version of the metric's snapshot,
-// or an object with a array of integers and a count for Distribution samples.
+// or an object with an array of numbers and a count for Distribution samples.
+// Members of a labelled metric family, whose names are the family name
+// followed by their labels, e.g. foo.requests{code="200"}, have two more
+// keys: Family, the name of the family, and Labels, an object mapping
+// label names to values.
 //
 // /all returns JSON representations for all registered metrics in a JSON
 // object, where keys correspond to metric names and values correspond
 // to the metric's JSON object, using the same format as /metric.
+// If the family parameter is given, only members of that labelled family
+// are returned. They may be further selected by any number of label
+// parameters in the form name=value, name!=value, name=~regexp or
+// name!~regexp.
+//
+// /stream is a Server-Sent Events stream of the metrics given by any
+// number of name parameters, or of all metrics if there are none.
+// Every interval, a duration parameter such as 500ms, defaulting to 1s
+// and at least 100ms, it sends a metrics event, whose data is a JSON
+// object with two keys: Metrics, an object mapping the names of the
+// metrics which are registered to their JSON objects, in the same
+// format as /metric, and Changes, the number of times metrics have
+// been registered or unregistered, as in the X-Metrics-Changes header.
+//
+// /history returns the history of the metric given by the name
+// parameter, as recorded by Registry#RecordHistory, in a JSON object
+// mapping the metric's fields to arrays of objects with Time, Min, Max
+// and Avg keys. It is read by Registry#History from the optional
+// parameters from and to, encoded as RFC3339 timestamps, and step,
+// a duration such as 1s or 10m. The object is empty if the metric's
+// history is not being recorded, as enabled by Registry#EnableHistory
+// and started by Registry#StartHistory.
+//
+// /sketch returns the t-digest sketch of a Digest metric, given by the
+// name parameter, in the binary form produced by Digest#MarshalBinary.
+// Sketches from many processes may be decoded with
+// statistics.TDigest#UnmarshalBinary and merged.
+//
+// /metrics returns all registered metrics in the Prometheus text
+// exposition format. Metric names are sanitized by replacing characters
+// that Prometheus does not allow with underscores, and members of a
+// labelled family are exported with their labels. Counters are exported
+// as counters, IntGauges, FloatGauges and Gauges with numeric values as
+// gauges, Meters as a gauge
+// for the value and gauges suffixed with _avg and _rate for each of its
+// averages, and Distributions and Digests as summaries with their
+// percentiles as quantiles. Timers are exported as a summary
+// of durations in seconds, with a gauge suffixed with _rate for the rate
+// of events. Labels of a family named quantile or window, which would
+// collide with the labels of these series, are renamed
+// exported_quantile and exported_window. A metric's metadata
+// description is exported as a HELP line. A family whose sanitized
+// series names collide with those of a family before it in name order,
+// such as a_b after a.b, is left out, with a comment saying so.
+//
+// Handler serves these endpoints, and may be mounted under a path
+// prefix on an existing server. NewServer creates a standalone server
+// for them, whose lifecycle is controlled by Serve and Shutdown, and
+// which may serve TLS, requiring client certificates.
+//
+// Requests may be authenticated by an Authorizer, such as BasicAuth or
+// BearerAuth, and metrics may be hidden from some viewers, for instance
+// anonymous ones, by the Visible option.
 package dashboard
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"metrics"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// HTTPServer is a dashboard server started by NewHTTPServer.
 type HTTPServer struct {
-	registry *metrics.Registry
 	*http.Server
 }
 
-func (h *HTTPServer) handlerIndex(w http.ResponseWriter, r *http.Request) {
+// handler serves the dashboard of a registry, as returned by Handler.
+type handler struct {
+	registry *metrics.Registry
+	changes  *int64
+	unwatch  func()
+	prefix   string
+	mux      *http.ServeMux
+
+	auth      Authorizer
+	anonymous bool
+	visibleTo func(user, name string) bool
+
+	// shutdowns are closed when the servers serving streams shut down
+	shutdowns map[*http.Server]chan struct{}
+	lock      sync.Mutex
+}
+
+// setChanges sets the X-Metrics-Changes header to the number of times
+// metrics have been registered or unregistered, so that clients can
+// tell when to fetch /list again.
+func (h *handler) setChanges(w http.ResponseWriter) {
+	w.Header().Set("X-Metrics-Changes",
+		strconv.FormatInt(atomic.LoadInt64(h.changes), 10))
+}
+
+func (h *handler) handlerIndex(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.New("index.html").Parse(asset("index.html"))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	tmpl.Execute(w, h.registry)
+	tmpl.Execute(w, struct {
+		Name   string
+		Prefix string
+	}{h.registry.Name(), h.prefix})
 }
 
-func (h *HTTPServer) handlerJS(w http.ResponseWriter, r *http.Request) {
+func (h *handler) handlerJS(w http.ResponseWriter, r *http.Request) {
 	var s string
 	switch r.FormValue("n") {
 	case "cubism":
@@ -74,12 +174,31 @@ func (h *HTTPServer) handlerJS(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, s)
 }
 
-func (h *HTTPServer) handlerAll(w http.ResponseWriter, r *http.Request) {
+func (h *handler) handlerAll(w http.ResponseWriter, r *http.Request) {
+	h.setChanges(w)
 	m := make(map[string]typeValue)
-	l := h.registry.ListMetrics()
+	var l map[string]metrics.Metric
+
+	if family := r.FormValue("family"); family != "" {
+		var matchers []metrics.LabelMatcher
+		for _, s := range r.Form["label"] {
+			matcher, err := metrics.ParseLabelMatcher(s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			matchers = append(matchers, matcher)
+		}
+		l = h.registry.FindLabeledS(family, matchers...)
+	} else {
+		l = h.registry.ListMetrics()
+	}
 
 	for i, metric := range l {
-		m[i] = typeValueMetric(metric)
+		if !h.visible(r, i) {
+			continue
+		}
+		m[i] = typeValueLabeled(h.registry, i, metric)
 	}
 
 	resp, err := json.Marshal(m)
@@ -91,10 +210,10 @@ func (h *HTTPServer) handlerAll(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%s\n", resp)
 }
 
-func (h *HTTPServer) handlerMetric(w http.ResponseWriter, r *http.Request) {
+func (h *handler) handlerMetric(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 
-	metric := h.registry.FindS(name)
+	metric := h.find(r, name)
 	if metric == nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -104,13 +223,24 @@ func (h *HTTPServer) handlerMetric(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	d, ok := metric.(*metrics.Distribution)
+	if t, isTimer := metric.(*metrics.Timer); isTimer {
+		d, ok = t.Distribution(), true
+	}
 	if ok && r.FormValue("samples") == "true" {
 		tv := typeValueSamples(d, r.FormValue("begin"),
 			r.FormValue("end"), r.FormValue("limit"))
 
 		resp, err = json.Marshal(tv)
 	} else {
-		tv := typeValueMetric(metric)
+		tv := typeValueLabeled(h.registry, name, metric)
+		if params := r.Form["q"]; len(params) > 0 {
+			quantiles, err := parseQuantiles(params)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tv = typeValueQuantiles(tv, metric, quantiles)
+		}
 
 		resp, err = json.Marshal(tv)
 	}
@@ -123,8 +253,78 @@ func (h *HTTPServer) handlerMetric(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%s\n", resp)
 }
 
-func (h *HTTPServer) handlerList(w http.ResponseWriter, r *http.Request) {
-	resp, err := json.Marshal(h.registry.List())
+func (h *handler) handlerHistory(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if h.find(r, name) == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var from, to time.Time
+	var step time.Duration
+	var err error
+	if s := r.FormValue("from"); s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.FormValue("to"); s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.FormValue("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	history := h.registry.History(name, from, to, step)
+	if history == nil {
+		history = make(map[string][]metrics.HistoryPoint)
+	}
+	resp, err := json.Marshal(history)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", resp)
+}
+
+func (h *handler) handlerSketch(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.find(r, r.FormValue("name")).(*metrics.Digest)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	resp, err := d.MarshalBinary()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(resp)
+}
+
+func (h *handler) handlerList(w http.ResponseWriter, r *http.Request) {
+	h.setChanges(w)
+	info := h.registry.ListInfo()
+	list := make([][]interface{}, 0, len(info))
+	for _, m := range info {
+		if !h.visible(r, m.Name) {
+			continue
+		}
+		item := []interface{}{m.Name, m.Type}
+		if !m.Metadata.IsZero() {
+			item = append(item, m.Metadata)
+		}
+		list = append(list, item)
+	}
+	resp, err := json.Marshal(list)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -132,40 +332,232 @@ func (h *HTTPServer) handlerList(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%s", resp)
 }
 
-// NewHTTPServer creates and starts a HTTP server on the specified address
-// for a metrics registry.
-func NewHTTPServer(r *metrics.Registry, addr string) HTTPServer {
-	h := HTTPServer{registry: r}
-	handler := http.NewServeMux()
+// Options configures a dashboard Handler. The zero value is usable.
+type Options struct {
+	// Prefix is the path under which the Handler is mounted, such as
+	// /debug/metrics. The Handler serves the requests whose paths begin
+	// with it, and the dashboard requests the other endpoints under it.
+	// The default is the root.
+	Prefix string
+
+	// Authorizer, if not nil, authenticates every request, including
+	// those for the dashboard page. Requests which it denies, or which
+	// have no credentials unless Anonymous is true, are answered with
+	// 401 Unauthorized.
+	Authorizer Authorizer
+	// Anonymous allows requests without credentials when there is an
+	// Authorizer, whose viewers are anonymous.
+	Anonymous bool
+	// Visible, if not nil, reports whether the metric with the given
+	// name is shown to a user, as returned by the Authorizer, or ""
+	// for anonymous viewers. The members of a labelled family are
+	// hidden with it. Hidden metrics are left out of lists and
+	// streams, and requests for them are answered as if they were not
+	// registered.
+	Visible func(user, name string) bool
+
+	// CertFile and KeyFile, if set, are the paths of the PEM encoded
+	// certificate and key with which a Server serves TLS. They are
+	// reloaded whenever they are modified.
+	CertFile, KeyFile string
+	// ClientCAFile, if set with CertFile, is the path of the PEM encoded
+	// certificates of the authorities by which a Server requires
+	// clients to present a certificate signed, for mutual TLS. It is
+	// reloaded whenever it is modified.
+	ClientCAFile string
+}
 
-	handler.HandleFunc("/",
+// Handler returns an http.Handler serving the dashboard of a registry
+// and the endpoints described in the package documentation, under the
+// path prefix given by the options, so that it can be mounted on an
+// existing server:
+//
+//	mux.Handle("/debug/metrics/", dashboard.Handler(r,
+//		dashboard.Options{Prefix: "/debug/metrics"}))
+//
+// Streams from /stream end when the server serving them shuts down.
+//
+// The Handler watches the registry to count its changes. It is also an
+// io.Closer, whose Close method stops watching, and which should be
+// called once it is no longer served if the registry outlives it.
+func Handler(r *metrics.Registry, opts Options) http.Handler {
+	return newHandler(r, opts)
+}
+
+func newHandler(r *metrics.Registry, opts Options) *handler {
+	h := &handler{
+		registry:  r,
+		changes:   new(int64),
+		prefix:    strings.TrimSuffix(opts.Prefix, "/"),
+		mux:       http.NewServeMux(),
+		shutdowns: make(map[*http.Server]chan struct{}),
+		auth:      opts.Authorizer,
+		anonymous: opts.Anonymous,
+		visibleTo: opts.Visible,
+	}
+	h.unwatch = r.Watch(func(metrics.RegistryEvent) {
+		atomic.AddInt64(h.changes, 1)
+	})
+
+	h.mux.HandleFunc("/",
 		func(w http.ResponseWriter, r *http.Request) {
 			h.handlerIndex(w, r)
 		})
-	handler.HandleFunc("/js",
+	h.mux.HandleFunc("/js",
 		func(w http.ResponseWriter, r *http.Request) {
 			h.handlerJS(w, r)
 		})
-	handler.HandleFunc("/all",
+	h.mux.HandleFunc("/all",
 		func(w http.ResponseWriter, r *http.Request) {
 			h.handlerAll(w, r)
 		})
-	handler.HandleFunc("/metric",
+	h.mux.HandleFunc("/metric",
 		func(w http.ResponseWriter, r *http.Request) {
 			h.handlerMetric(w, r)
 		})
-	handler.HandleFunc("/list",
+	h.mux.HandleFunc("/list",
 		func(w http.ResponseWriter, r *http.Request) {
 			h.handlerList(w, r)
 		})
-	h.Server = &http.Server{
-		Addr:         addr,
-		Handler:      handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+	h.mux.HandleFunc("/metrics",
+		func(w http.ResponseWriter, r *http.Request) {
+			h.handlerPrometheus(w, r)
+		})
+	h.mux.HandleFunc("/stream",
+		func(w http.ResponseWriter, r *http.Request) {
+			h.handlerStream(w, r)
+		})
+	h.mux.HandleFunc("/history",
+		func(w http.ResponseWriter, r *http.Request) {
+			h.handlerHistory(w, r)
+		})
+	h.mux.HandleFunc("/sketch",
+		func(w http.ResponseWriter, r *http.Request) {
+			h.handlerSketch(w, r)
+		})
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the prefix only matches whole path segments
+	if h.prefix != "" && r.URL.Path != h.prefix &&
+		!strings.HasPrefix(r.URL.Path, h.prefix+"/") {
+
+		http.NotFound(w, r)
+		return
+	}
+	if r = h.authorize(w, r); r == nil {
+		return
+	}
+	if h.prefix == "" {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+	// the dashboard's links are relative to the prefix with a slash
+	if r.URL.Path == h.prefix {
+		http.Redirect(w, r, h.prefix+"/", http.StatusMovedPermanently)
+		return
 	}
+	http.StripPrefix(h.prefix, h.mux).ServeHTTP(w, r)
+}
 
-	go h.ListenAndServe()
+// Close stops the handler from watching its registry. It always
+// returns nil.
+func (h *handler) Close() error {
+	h.unwatch()
+	return nil
+}
 
-	return h
+// shutdown returns a channel which is closed when the server serving
+// a request shuts down, or nil if the server is unknown.
+func (h *handler) shutdown(r *http.Request) <-chan struct{} {
+	srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if !ok {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	c, ok := h.shutdowns[srv]
+	if !ok {
+		c = make(chan struct{})
+		h.shutdowns[srv] = c
+		srv.RegisterOnShutdown(func() { close(c) })
+	}
+	return c
+}
+
+// Server is an HTTP server of a dashboard, which is started by Serve
+// and stopped by Shutdown.
+type Server struct {
+	// HTTP is the underlying server. Its settings, such as its
+	// timeouts, may be changed before Serve is called.
+	HTTP *http.Server
+
+	handler *handler
+	tls     *tlsFiles
+}
+
+// NewServer creates a Server of the dashboard of a registry, as served
+// by Handler, on the specified address, with read and write timeouts
+// of 10 seconds. It serves TLS if the options have a CertFile. It does
+// not start it.
+func NewServer(r *metrics.Registry, addr string, opts Options) *Server {
+	h := newHandler(r, opts)
+	s := &Server{
+		HTTP: &http.Server{
+			Addr:         addr,
+			Handler:      h,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+		handler: h,
+	}
+	if opts.CertFile != "" {
+		s.tls = &tlsFiles{
+			certFile: opts.CertFile,
+			keyFile:  opts.KeyFile,
+			caFile:   opts.ClientCAFile,
+		}
+		s.HTTP.TLSConfig = s.tls.serverConfig()
+	}
+	return s
+}
+
+// Serve listens on the server's address and serves the dashboard. Like
+// http.Server's ListenAndServe, it returns http.ErrServerClosed once
+// Shutdown is called, including when it is called after Shutdown, or
+// the error which stopped it, such as failing to listen or to load the
+// TLS certificates.
+func (s *Server) Serve() error {
+	if s.tls != nil {
+		if _, err := s.tls.get(); err != nil {
+			return err
+		}
+		return s.HTTP.ListenAndServeTLS("", "")
+	}
+	return s.HTTP.ListenAndServe()
+}
+
+// Shutdown stops the server gracefully, as by http.Server's Shutdown:
+// it stops listening, ends streams, and waits for the requests in
+// progress to finish until ctx is done. It also stops the server's
+// handler from watching the registry, so the server cannot be served
+// again.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.HTTP.Shutdown(ctx)
+	s.handler.Close()
+	return err
+}
+
+// NewHTTPServer creates and starts a HTTP server on the specified address
+// for a metrics registry.
+//
+// Deprecated: errors from listening are dropped. Use NewServer, or
+// mount Handler on an existing server.
+func NewHTTPServer(r *metrics.Registry, addr string) HTTPServer {
+	s := NewServer(r, addr, Options{})
+	go s.Serve()
+	return HTTPServer{s.HTTP}
 }
@@ -0,0 +1,87 @@
+package dashboard
+
+import (
+	"context"
+	"metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testGet serves a GET request for target, and returns the recorded
+// response.
+func testGet(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func TestHandlerPrefix(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "requests")
+	h := Handler(r, Options{Prefix: "/debug/metrics/"})
+
+	for _, test := range []struct {
+		target string
+		code   int
+	}{
+		{"/debug/metrics/", http.StatusOK},
+		{"/debug/metrics/list", http.StatusOK},
+		{"/debug/metrics/metric?name=dashboard.testType.requests", http.StatusOK},
+		{"/debug/metrics", http.StatusMovedPermanently},
+		{"/debug/metricsfoo", http.StatusNotFound},
+		{"/debug/metricsfoo/list", http.StatusNotFound},
+		{"/list", http.StatusNotFound},
+	} {
+		if w := testGet(h, test.target); w.Code != test.code {
+			t.Errorf("Wrong status of %s, got %d expected %d",
+				test.target, w.Code, test.code)
+		}
+	}
+
+	w := testGet(h, "/debug/metrics")
+	if l := w.Header().Get("Location"); l != "/debug/metrics/" {
+		t.Errorf("Wrong redirect, got %q expected %q", l, "/debug/metrics/")
+	}
+}
+
+func TestHandlerClose(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	h := Handler(r, Options{})
+
+	r.NewCounter(testType{}, "requests")
+	if c := testGet(h, "/list").Header().Get("X-Metrics-Changes"); c != "1" {
+		t.Errorf("Wrong changes, got %q expected %q", c, "1")
+	}
+
+	h.(interface{ Close() error }).Close()
+	r.NewCounter(testType{}, "errors")
+	if c := testGet(h, "/list").Header().Get("X-Metrics-Changes"); c != "1" {
+		t.Errorf("Wrong changes after Close, got %q expected %q", c, "1")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	s := NewServer(r, "127.0.0.1:0", Options{})
+
+	served := make(chan error)
+	go func() { served <- s.Serve() }()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Wrong error from Serve, got %v expected %v",
+			err, http.ErrServerClosed)
+	}
+	if err := s.Serve(); err != http.ErrServerClosed {
+		t.Errorf("Wrong error from Serve after Shutdown, got %v expected %v",
+			err, http.ErrServerClosed)
+	}
+
+	// the handler no longer watches the registry
+	r.NewCounter(testType{}, "requests")
+	if c := testGet(s.HTTP.Handler, "/list").Header().Get("X-Metrics-Changes"); c != "0" {
+		t.Errorf("Wrong changes after Shutdown, got %q expected %q", c, "0")
+	}
+}
@@ -3,12 +3,16 @@ package dashboard
 import (
 	"fmt"
 	"metrics"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type typeValue struct {
-	Type  string
-	Value interface{}
+	Type   string
+	Family string         `json:",omitempty"`
+	Labels metrics.Labels `json:",omitempty"`
+	Value  interface{}
 }
 
 func typeValueMetric(me metrics.Metric) typeValue {
@@ -19,6 +23,10 @@ func typeValueMetric(me metrics.Metric) typeValue {
 		tv.Type = "counter"
 		tv.Value = m.Snapshot()
 
+	case *metrics.Digest:
+		tv.Type = "digest"
+		tv.Value = m.Snapshot()
+
 	case *metrics.Distribution:
 		tv.Type = "distribution"
 		tv.Value = m.Snapshot()
@@ -35,14 +43,83 @@ func typeValueMetric(me metrics.Metric) typeValue {
 		tv.Type = "gauge"
 		tv.Value = stringified
 
+	case *metrics.IntGauge:
+		tv.Type = "intgauge"
+		tv.Value = m.Snapshot()
+
+	case *metrics.FloatGauge:
+		tv.Type = "floatgauge"
+		tv.Value = m.Snapshot()
+
 	case *metrics.Meter:
 		tv.Type = "meter"
 		tv.Value = m.Snapshot()
+
+	case *metrics.Timer:
+		tv.Type = "timer"
+		tv.Value = m.Snapshot()
 	}
 
 	return tv
 }
 
+// typeValueLabeled is typeValueMetric, additionally setting the
+// family and labels of a metric that belongs to a labelled family.
+func typeValueLabeled(r *metrics.Registry, name string,
+	me metrics.Metric) typeValue {
+
+	tv := typeValueMetric(me)
+	if family, labels := r.LabelSet(name); labels != nil {
+		tv.Family = family
+		tv.Labels = labels
+	}
+	return tv
+}
+
+// parseQuantiles parses quantiles given as any number of q parameters,
+// each holding a comma-separated list.
+func parseQuantiles(params []string) ([]float64, error) {
+	var qs []float64
+	for _, param := range params {
+		for _, s := range strings.Split(param, ",") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || !(q >= 0 && q <= 1) {
+				return nil, fmt.Errorf("invalid quantile %q", s)
+			}
+			qs = append(qs, q)
+		}
+	}
+	return qs, nil
+}
+
+// typeValueQuantiles replaces the percentiles in the snapshot of a
+// Distribution or Timer with its values at quantiles.
+func typeValueQuantiles(tv typeValue, me metrics.Metric,
+	quantiles []float64) typeValue {
+
+	percentiles := func(d *metrics.Distribution) []metrics.Percentile {
+		values := d.Quantiles(quantiles)
+		ps := make([]metrics.Percentile, len(quantiles))
+		for i, q := range quantiles {
+			ps[i] = metrics.Percentile{Quantile: q, Value: values[i]}
+		}
+		return ps
+	}
+
+	switch m := me.(type) {
+	case *metrics.Distribution:
+		s := tv.Value.(metrics.DistributionSnapshot)
+		s.Percentiles = percentiles(m)
+		tv.Value = s
+
+	case *metrics.Timer:
+		s := tv.Value.(metrics.TimerSnapshot)
+		s.Distribution.Percentiles = percentiles(m.Distribution())
+		tv.Value = s
+	}
+	return tv
+}
+
 func typeValueSamples(d *metrics.Distribution,
 	beginstr, endstr, limitstr string) typeValue {
 
@@ -64,10 +141,10 @@ func typeValueSamples(d *metrics.Distribution,
 	fmt.Sscanf(limitstr, "%d", &limit)
 
 	var t struct {
-		Samples []int64
+		Samples []float64
 		Count   int64
 	}
-	t.Samples, t.Count = d.Samples(limit, beginptr, endptr)
+	t.Samples, t.Count = d.FloatSamples(limit, beginptr, endptr)
 
 	tv.Value = t
 
@@ -0,0 +1,345 @@
+package dashboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"metrics"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusName converts a registry name such as
+// "metrics.testRegistryType.internal_type" into a valid Prometheus
+// metric name. Characters outside [a-zA-Z0-9_:] are replaced with
+// underscores, and a leading digit is prefixed with an underscore.
+func prometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9', c == '_', c == ':':
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || (b[0] >= '0' && b[0] <= '9') {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// prometheusDuration formats a time constant as a short label
+// value, e.g. "1m" or "30s".
+func prometheusDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return d.String()
+}
+
+func prometheusFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// meterDerivativeSuffix names the Prometheus series for a
+// derivative order of a Meter.
+func meterDerivativeSuffix(order int) string {
+	switch order {
+	case 0:
+		return "_avg"
+	case 1:
+		return "_rate"
+	}
+	return fmt.Sprintf("_deriv%d", order)
+}
+
+// prometheusSeries returns the names of the series which writePrometheus
+// writes for a family with the given sanitized name, whose members
+// have the type of m.
+func prometheusSeries(name string, m metrics.Metric) []string {
+	switch m := m.(type) {
+	case *metrics.Meter:
+		series := []string{name}
+		for order := range m.Snapshot().Derivatives {
+			series = append(series, name+meterDerivativeSuffix(order))
+		}
+		return series
+	case *metrics.Digest, *metrics.Distribution:
+		return []string{name, name + "_sum", name + "_count"}
+	case *metrics.Timer:
+		return []string{name, name + "_sum", name + "_count",
+			name + meterDerivativeSuffix(1)}
+	}
+	return []string{name}
+}
+
+// prometheusMember is a metric in a family exported under one
+// Prometheus metric name. Only members of labelled families have labels.
+type prometheusMember struct {
+	labels metrics.Labels
+	metric metrics.Metric
+}
+
+// prometheusLabels formats a member's labels along with extra
+// label name and value pairs, or returns "" if there are none. A
+// member's label with the name of an extra label, such as quantile, is
+// renamed with the prefix exported_, as Prometheus does when labels of
+// a target collide with its own.
+func prometheusLabels(l metrics.Labels, extra ...string) string {
+	if len(l) == 0 && len(extra) == 0 {
+		return ""
+	}
+	all := make(metrics.Labels, len(l)+len(extra)/2)
+	for name, value := range l {
+		all[name] = value
+	}
+	for _, name := range prometheusReserved {
+		if value, ok := all[name]; ok {
+			delete(all, name)
+			all["exported_"+name] = value
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		all[extra[i]] = extra[i+1]
+	}
+	return all.String()
+}
+
+// prometheusReserved are the names of the labels added to the series
+// of summaries and meters.
+var prometheusReserved = []string{"quantile", "window"}
+
+// prometheusHelp escapes a description for a HELP line.
+var prometheusHelp = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+// writePrometheusType writes the TYPE line of a metric family, preceded
+// by a HELP line with its description. The text format has no place
+// for its unit.
+func writePrometheusType(w io.Writer, name, typ string, md metrics.Metadata) {
+	if md.Description != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name,
+			prometheusHelp.Replace(md.Description))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// writePrometheus writes a metric family in the Prometheus text
+// exposition format. All members of a family have the same type.
+// Gauges whose values are not numeric are skipped.
+func writePrometheus(w io.Writer, name string, md metrics.Metadata,
+	members []prometheusMember) {
+
+	name = prometheusName(name)
+
+	switch members[0].metric.(type) {
+	case *metrics.Counter:
+		writePrometheusType(w, name, "counter", md)
+		for _, me := range members {
+			m := me.metric.(*metrics.Counter)
+			fmt.Fprintf(w, "%s%s %d\n", name, prometheusLabels(me.labels),
+				m.Snapshot().Value)
+		}
+
+	case *metrics.Gauge:
+		typed := false
+		for _, me := range members {
+			s := me.metric.(*metrics.Gauge).Snapshot()
+			if s.Value == nil {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(s.Value.String()), 64)
+			if err != nil {
+				continue
+			}
+			if !typed {
+				writePrometheusType(w, name, "gauge", md)
+				typed = true
+			}
+			fmt.Fprintf(w, "%s%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(v))
+		}
+
+	case *metrics.IntGauge:
+		writePrometheusType(w, name, "gauge", md)
+		for _, me := range members {
+			fmt.Fprintf(w, "%s%s %d\n", name, prometheusLabels(me.labels),
+				me.metric.(*metrics.IntGauge).Value())
+		}
+
+	case *metrics.FloatGauge:
+		writePrometheusType(w, name, "gauge", md)
+		for _, me := range members {
+			fmt.Fprintf(w, "%s%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(me.metric.(*metrics.FloatGauge).Value()))
+		}
+
+	case *metrics.Meter:
+		snapshots := make([]metrics.MeterSnapshot, len(members))
+		writePrometheusType(w, name, "gauge", md)
+		for i, me := range members {
+			snapshots[i] = me.metric.(*metrics.Meter).Snapshot()
+			fmt.Fprintf(w, "%s%s %d\n", name, prometheusLabels(me.labels),
+				snapshots[i].Value)
+		}
+
+		for order := range snapshots[0].Derivatives {
+			series := name + meterDerivativeSuffix(order)
+			fmt.Fprintf(w, "# TYPE %s gauge\n", series)
+			for i, s := range snapshots {
+				d := s.Derivatives[order]
+				// the instantaneous value is already exported above
+				if order != 0 {
+					fmt.Fprintf(w, "%s%s %s\n", series,
+						prometheusLabels(members[i].labels),
+						prometheusFloat(d[0]))
+				}
+				for j, tc := range s.TimeConstants {
+					fmt.Fprintf(w, "%s%s %s\n", series,
+						prometheusLabels(members[i].labels,
+							"window", prometheusDuration(tc)),
+						prometheusFloat(d[j+1]))
+				}
+			}
+		}
+
+	case *metrics.Digest:
+		writePrometheusType(w, name, "summary", md)
+		for _, me := range members {
+			s := me.metric.(*metrics.Digest).Snapshot()
+			for i, q := range s.Quantiles {
+				fmt.Fprintf(w, "%s%s %s\n", name,
+					prometheusLabels(me.labels, "quantile", prometheusFloat(q)),
+					prometheusFloat(s.Percentiles[i]))
+			}
+			fmt.Fprintf(w, "%s_sum%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(s.Mean*s.Count))
+			fmt.Fprintf(w, "%s_count%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(s.Count))
+		}
+
+	case *metrics.Timer:
+		snapshots := make([]metrics.TimerSnapshot, len(members))
+		writePrometheusType(w, name, "summary", md)
+		for i, me := range members {
+			snapshots[i] = me.metric.(*metrics.Timer).Snapshot()
+			s := snapshots[i].Distribution
+			for _, p := range s.Percentiles {
+				seconds := p.Value / float64(time.Second)
+				fmt.Fprintf(w, "%s%s %s\n", name,
+					prometheusLabels(me.labels,
+						"quantile", prometheusFloat(p.Quantile)),
+					prometheusFloat(seconds))
+			}
+			sum := s.Mean * float64(s.Count) / float64(time.Second)
+			fmt.Fprintf(w, "%s_sum%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", name, prometheusLabels(me.labels),
+				s.Count)
+		}
+
+		series := name + meterDerivativeSuffix(1)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", series)
+		for i, s := range snapshots {
+			d := s.Meter.Derivatives[1]
+			fmt.Fprintf(w, "%s%s %s\n", series,
+				prometheusLabels(members[i].labels), prometheusFloat(d[0]))
+			for j, tc := range s.Meter.TimeConstants {
+				fmt.Fprintf(w, "%s%s %s\n", series,
+					prometheusLabels(members[i].labels,
+						"window", prometheusDuration(tc)),
+					prometheusFloat(d[j+1]))
+			}
+		}
+
+	case *metrics.Distribution:
+		writePrometheusType(w, name, "summary", md)
+		for _, me := range members {
+			s := me.metric.(*metrics.Distribution).Snapshot()
+			for _, p := range s.Percentiles {
+				fmt.Fprintf(w, "%s%s %s\n", name,
+					prometheusLabels(me.labels,
+						"quantile", prometheusFloat(p.Quantile)),
+					prometheusFloat(p.Value))
+			}
+			fmt.Fprintf(w, "%s_sum%s %s\n", name, prometheusLabels(me.labels),
+				prometheusFloat(s.Mean*float64(s.Count)))
+			fmt.Fprintf(w, "%s_count%s %d\n", name, prometheusLabels(me.labels),
+				s.Count)
+		}
+	}
+}
+
+func (h *handler) handlerPrometheus(w http.ResponseWriter, r *http.Request) {
+	families := make(map[string][]prometheusMember)
+	for name, metric := range h.registry.ListMetrics() {
+		if !h.visible(r, name) {
+			continue
+		}
+		family, labels := h.registry.LabelSet(name)
+		families[family] = append(families[family],
+			prometheusMember{labels: labels, metric: metric})
+	}
+
+	names := make([]string, 0, len(families))
+	for name, members := range families {
+		names = append(names, name)
+		sort.Sort(prometheusMembers(members))
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", prometheusContentType)
+	bw := bufio.NewWriter(w)
+	// Prometheus rejects a scrape whose series are described twice, so
+	// a family whose series have the same sanitized names as those of a
+	// family written before it, such as a.b and a_b, is left out.
+	written := make(map[string]string)
+	for _, name := range names {
+		members := families[name]
+		series := prometheusSeries(prometheusName(name), members[0].metric)
+		collides := ""
+		for _, s := range series {
+			if other, ok := written[s]; ok {
+				collides = other
+				break
+			}
+		}
+		if collides != "" {
+			fmt.Fprintf(bw, "# %s is not exported: its series collide with those of %s\n",
+				prometheusHelp.Replace(name), prometheusHelp.Replace(collides))
+			continue
+		}
+		for _, s := range series {
+			written[s] = name
+		}
+		writePrometheus(bw, name, h.registry.Metadata(name), members)
+	}
+	bw.Flush()
+}
+
+type prometheusMembers []prometheusMember
+
+func (p prometheusMembers) Len() int      { return len(p) }
+func (p prometheusMembers) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p prometheusMembers) Less(i, j int) bool {
+	return p[i].labels.String() < p[j].labels.String()
+}
@@ -0,0 +1,179 @@
+package dashboard
+
+import (
+	"metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testType struct{}
+
+// testPrometheus returns the lines of the registry's /metrics
+// exposition.
+func testPrometheus(t *testing.T, r *metrics.Registry) []string {
+	w := httptest.NewRecorder()
+	Handler(r, Options{}).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status, got %d expected %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != prometheusContentType {
+		t.Errorf("Wrong content type, got %q expected %q", ct, prometheusContentType)
+	}
+	return strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+}
+
+// testContainsLines reports whether lines contains expected, in order.
+func testContainsLines(lines, expected []string) bool {
+	for i := 0; i+len(expected) <= len(lines); i++ {
+		match := true
+		for j, l := range expected {
+			if lines[i+j] != l {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPrometheusName(t *testing.T) {
+	for _, test := range []struct{ name, expected string }{
+		{"dashboard.testType.requests", "dashboard_testType_requests"},
+		{"pool/a-b:c", "pool_a_b:c"},
+		{"9lives", "_9lives"},
+		{"", "_"},
+	} {
+		if n := prometheusName(test.name); n != test.expected {
+			t.Errorf("Wrong name of %q, got %q expected %q", test.name, n, test.expected)
+		}
+	}
+}
+
+func TestPrometheusCounters(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "requests").Inc(3)
+	r.SetMetadata(testType{}, "requests", metrics.Metadata{
+		Description: "Requests\nserved, in \\ units",
+		Unit:        "requests",
+	})
+	v := r.NewCounterVec(testType{}, "codes")
+	v.WithLabels(metrics.Labels{"path": "a\"b\\c\nd", "code": "200"}).Inc(2)
+	v.WithLabels(metrics.Labels{"path": "/", "code": "404"}).Inc(1)
+
+	expected := []string{
+		"# TYPE dashboard_testType_codes counter",
+		`dashboard_testType_codes{code="200",path="a\"b\\c\nd"} 2`,
+		`dashboard_testType_codes{code="404",path="/"} 1`,
+		`# HELP dashboard_testType_requests Requests\nserved, in \\ units`,
+		"# TYPE dashboard_testType_requests counter",
+		"dashboard_testType_requests 3",
+	}
+	lines := testPrometheus(t, r)
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong exposition, got\n%s\nexpected\n%s",
+			strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestPrometheusSummaries(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	dg := r.NewDigest(testType{}, "digest", 100)
+	for i := 1; i <= 4; i++ {
+		dg.Add(float64(i))
+	}
+	v := r.NewDistributionVec(testType{}, "sizes")
+	d := v.WithLabels(metrics.Labels{"quantile": "user", "window": "w"})
+	d.SetPercentiles([]float64{0.5})
+	for _, x := range []int64{2, 3, 4} {
+		d.Add(x)
+	}
+	tm := r.NewTimer(testType{}, "latency")
+	tm.Distribution().SetPercentiles([]float64{1})
+	tm.Update(2 * time.Second)
+	r.NewMeter(testType{}, "events")
+
+	lines := testPrometheus(t, r)
+	for _, expected := range [][]string{
+		{
+			"# TYPE dashboard_testType_digest summary",
+			`dashboard_testType_digest{quantile="0"} 1`,
+		},
+		{
+			`dashboard_testType_digest{quantile="1"} 4`,
+			"dashboard_testType_digest_sum 10",
+			"dashboard_testType_digest_count 4",
+		},
+		{
+			"# TYPE dashboard_testType_sizes summary",
+			`dashboard_testType_sizes{exported_quantile="user",exported_window="w",quantile="0.5"} 3`,
+			`dashboard_testType_sizes_sum{exported_quantile="user",exported_window="w"} 9`,
+			`dashboard_testType_sizes_count{exported_quantile="user",exported_window="w"} 3`,
+		},
+		{
+			"# TYPE dashboard_testType_latency summary",
+			`dashboard_testType_latency{quantile="1"} 2`,
+			"dashboard_testType_latency_sum 2",
+			"dashboard_testType_latency_count 1",
+			"# TYPE dashboard_testType_latency_rate gauge",
+		},
+		{
+			"# TYPE dashboard_testType_events gauge",
+			"dashboard_testType_events 0",
+			"# TYPE dashboard_testType_events_avg gauge",
+			`dashboard_testType_events_avg{window="1m"} 0`,
+		},
+	} {
+		if !testContainsLines(lines, expected) {
+			t.Errorf("Exposition has no lines\n%s\nin\n%s",
+				strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+		}
+	}
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "# UNIT") {
+			t.Errorf("Exposition has a UNIT line: %s", l)
+		}
+	}
+}
+
+func TestPrometheusCollisions(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "a.b").Inc(1)
+	r.NewCounter(testType{}, "a_b").Inc(2)
+	r.Sub("dashboard.testType").NewIntGauge(testType{}, "x").Set(3)
+	r.NewIntGauge(testType{}, "dashboard.testType.x").Set(4)
+	r.NewTimer(testType{}, "latency")
+	r.NewFloatGauge(testType{}, "latency_rate").Set(5)
+
+	lines := testPrometheus(t, r)
+	types := make(map[string]int)
+	for _, l := range lines {
+		if strings.HasPrefix(l, "# TYPE ") {
+			types[strings.Fields(l)[2]]++
+		}
+	}
+	for name, n := range types {
+		if n != 1 {
+			t.Errorf("Wrong number of TYPE lines for %s, got %d expected 1", name, n)
+		}
+	}
+
+	for _, expected := range []string{
+		"dashboard_testType_a_b 1",
+		"# dashboard.testType.a_b is not exported: its series collide with those of dashboard.testType.a.b",
+		"dashboard_testType_dashboard_testType_x 4",
+		"# dashboard.testType/dashboard.testType.x is not exported: its series collide with those of dashboard.testType.dashboard.testType.x",
+		"# dashboard.testType.latency_rate is not exported: its series collide with those of dashboard.testType.latency",
+	} {
+		if !testContainsLines(lines, []string{expected}) {
+			t.Errorf("Exposition has no line %q in\n%s",
+				expected, strings.Join(lines, "\n"))
+		}
+	}
+}
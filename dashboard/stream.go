@@ -0,0 +1,103 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	streamDefaultInterval = time.Second
+	streamMinInterval     = 100 * time.Millisecond
+	// streamWriteTimeout bounds each event's write, since the server's
+	// WriteTimeout would end the stream.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamEvent is the data of an event sent by /stream.
+type streamEvent struct {
+	Changes int64
+	Metrics map[string]typeValue
+}
+
+// streamEvent returns the metrics with the given names, skipping those
+// which are not registered or not visible to the user of a request, or
+// all visible metrics if names is nil.
+func (h *handler) streamEvent(r *http.Request, names []string) streamEvent {
+	e := streamEvent{
+		Changes: atomic.LoadInt64(h.changes),
+		Metrics: make(map[string]typeValue),
+	}
+	if names == nil {
+		for name, metric := range h.registry.ListMetrics() {
+			if !h.visible(r, name) {
+				continue
+			}
+			e.Metrics[name] = typeValueLabeled(h.registry, name, metric)
+		}
+		return e
+	}
+	for _, name := range names {
+		if metric := h.find(r, name); metric != nil {
+			e.Metrics[name] = typeValueLabeled(h.registry, name, metric)
+		}
+	}
+	return e
+}
+
+// streamInterval parses the interval parameter of /stream, which
+// defaults to streamDefaultInterval and is raised to streamMinInterval.
+func streamInterval(s string) (time.Duration, error) {
+	interval := streamDefaultInterval
+	if s != "" {
+		var err error
+		if interval, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if interval < streamMinInterval {
+		interval = streamMinInterval
+	}
+	return interval, nil
+}
+
+func (h *handler) handlerStream(w http.ResponseWriter, r *http.Request) {
+	interval, err := streamInterval(r.FormValue("interval"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	names := r.Form["name"]
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	shutdown := h.shutdown(r)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		resp, err := json.Marshal(h.streamEvent(r, names))
+		if err != nil {
+			return
+		}
+		// not every ResponseWriter supports deadlines
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, "event: metrics\ndata: %s\n\n", resp); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		case <-shutdown:
+			return
+		}
+	}
+}
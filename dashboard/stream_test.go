@@ -0,0 +1,143 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testReadEvent reads an event from a stream, and returns its name and
+// decoded data.
+func testReadEvent(t *testing.T, br *bufio.Reader) (string, streamEvent) {
+	var lines []string
+	for {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Could not read event: %v", err)
+		}
+		if l == "\n" {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(l, "\n"))
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") ||
+		!strings.HasPrefix(lines[1], "data: ") {
+
+		t.Fatalf("Wrong event framing: %q", lines)
+	}
+
+	var e streamEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e); err != nil {
+		t.Fatalf("Could not decode event data: %v", err)
+	}
+	return strings.TrimPrefix(lines[0], "event: "), e
+}
+
+// testStream opens a stream from a server, failing unless it succeeds.
+func testStream(t *testing.T, url string) *http.Response {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Could not open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status, got %d expected %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Wrong content type, got %q expected %q", ct, "text/event-stream")
+	}
+	return resp
+}
+
+func TestStreamInterval(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		expected time.Duration
+	}{
+		{"", time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"2s", 2 * time.Second},
+		{"10ms", 100 * time.Millisecond},
+		{"-1s", 100 * time.Millisecond},
+	} {
+		if d, err := streamInterval(test.s); err != nil || d != test.expected {
+			t.Errorf("Wrong interval of %q, got %v, %v expected %v",
+				test.s, d, err, test.expected)
+		}
+	}
+	if _, err := streamInterval("soon"); err == nil {
+		t.Errorf("streamInterval accepted an invalid duration")
+	}
+
+	h := Handler(metrics.NewRegistry("test"), Options{})
+	if w := testGet(h, "/stream?interval=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("Wrong status, got %d expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	c := r.NewCounter(testType{}, "requests")
+	r.NewCounter(testType{}, "errors")
+	server := httptest.NewServer(Handler(r, Options{}))
+	defer server.Close()
+
+	resp := testStream(t, server.URL+
+		"/stream?interval=100ms&name=dashboard.testType.requests&name=missing")
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+
+	name, e := testReadEvent(t, br)
+	if name != "metrics" {
+		t.Errorf("Wrong event name, got %q expected %q", name, "metrics")
+	}
+	if len(e.Metrics) != 1 {
+		t.Errorf("Wrong metrics, got %v expected only the requests", e.Metrics)
+	}
+	if m, ok := e.Metrics["dashboard.testType.requests"]; !ok || m.Type != "counter" {
+		t.Errorf("Wrong requests, got %v expected a counter", m)
+	}
+	if e.Changes != 0 {
+		t.Errorf("Wrong changes, got %d expected 0", e.Changes)
+	}
+
+	// the next event has the new value
+	c.Inc(5)
+	_, e = testReadEvent(t, br)
+	v, _ := e.Metrics["dashboard.testType.requests"].Value.(map[string]interface{})
+	if v["Value"] != 5.0 {
+		t.Errorf("Wrong value, got %v expected 5", v["Value"])
+	}
+
+	// without names, every metric is streamed
+	all := testStream(t, server.URL+"/stream")
+	defer all.Body.Close()
+	if _, e := testReadEvent(t, bufio.NewReader(all.Body)); len(e.Metrics) != 2 {
+		t.Errorf("Wrong metrics, got %v expected all of them", e.Metrics)
+	}
+}
+
+func TestStreamShutdown(t *testing.T) {
+	server := httptest.NewServer(Handler(metrics.NewRegistry("test"), Options{}))
+	defer server.Close()
+
+	resp := testStream(t, server.URL+"/stream?interval=1h")
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	testReadEvent(t, br)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if _, err := br.ReadString('\n'); err != io.EOF {
+		t.Errorf("Wrong error after Shutdown, got %v expected %v", err, io.EOF)
+	}
+}
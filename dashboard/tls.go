@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsFiles loads the TLS configuration of a Server from its certificate,
+// key and client CA files, and reloads it when they are modified, so
+// that certificates can be renewed without restarting the server.
+type tlsFiles struct {
+	certFile, keyFile, caFile string
+
+	lock     sync.Mutex
+	modTimes [3]time.Time
+	config   *tls.Config
+}
+
+// serverConfig returns the configuration of the Server's listener,
+// which gets the current configuration for each connection.
+func (f *tlsFiles) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return f.get()
+		},
+	}
+}
+
+// get returns the current configuration, reloading the files if any of
+// them was modified since they were last loaded. If they cannot be
+// loaded, as while they are being replaced, the previous configuration
+// is kept.
+func (f *tlsFiles) get() (*tls.Config, error) {
+	var modTimes [3]time.Time
+	for i, name := range []string{f.certFile, f.keyFile, f.caFile} {
+		if name == "" {
+			continue
+		}
+		if fi, err := os.Stat(name); err == nil {
+			modTimes[i] = fi.ModTime()
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.config != nil && modTimes == f.modTimes {
+		return f.config, nil
+	}
+	config, err := f.load()
+	if err != nil {
+		if f.config != nil {
+			return f.config, nil
+		}
+		return nil, err
+	}
+	f.config, f.modTimes = config, modTimes
+	return config, nil
+}
+
+func (f *tlsFiles) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if f.caFile != "" {
+		pem, err := os.ReadFile(f.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("dashboard: no certificates in %s", f.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,197 @@
+package dashboard
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"metrics"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, in their parsed and PEM forms.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// testIssue creates a certificate for a common name, signed by parent,
+// or self-signed as an authority if parent is nil.
+func testIssue(t *testing.T, name string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer,
+		&key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Could not create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Could not encode key: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// testWriteFile writes a file, with a modification time of mtime.
+func testWriteFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Could not write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Could not set the time of %s: %v", path, err)
+	}
+}
+
+// testTLSFiles writes a certificate and key, and the certificate of
+// their authority, and returns the tlsFiles loading them.
+func testTLSFiles(t *testing.T, ca, server *testCert) *tlsFiles {
+	dir := t.TempDir()
+	f := &tlsFiles{
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "key.pem"),
+		caFile:   filepath.Join(dir, "ca.pem"),
+	}
+	mtime := time.Now().Add(-time.Hour)
+	testWriteFile(t, f.certFile, server.certPEM, mtime)
+	testWriteFile(t, f.keyFile, server.keyPEM, mtime)
+	testWriteFile(t, f.caFile, ca.certPEM, mtime)
+	return f
+}
+
+func TestTLSClientCertificates(t *testing.T) {
+	ca := testIssue(t, "ca", 1, nil)
+	f := testTLSFiles(t, ca, testIssue(t, "server", 2, ca))
+
+	r := metrics.NewRegistry("test")
+	server := httptest.NewUnstartedServer(Handler(r, Options{
+		Authorizer: ClientCertAuth,
+	}))
+	server.TLS = f.serverConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+	}
+
+	if resp, err := client().Get(server.URL + "/list"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Request without a client certificate succeeded")
+	}
+
+	other := testIssue(t, "other", 3, nil)
+	stranger := testIssue(t, "stranger", 4, other)
+	strangerCert, _ := tls.X509KeyPair(stranger.certPEM, stranger.keyPEM)
+	if resp, err := client(strangerCert).Get(server.URL + "/list"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Request with a certificate of another authority succeeded")
+	}
+
+	alice := testIssue(t, "alice", 5, ca)
+	aliceCert, _ := tls.X509KeyPair(alice.certPEM, alice.keyPEM)
+	resp, err := client(aliceCert).Get(server.URL + "/list")
+	if err != nil {
+		t.Fatalf("Request with a client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Wrong status, got %d expected %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	ca := testIssue(t, "ca", 1, nil)
+	f := testTLSFiles(t, ca, testIssue(t, "server", 2, ca))
+
+	serial := func(config *tls.Config) int64 {
+		cert, _ := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		return cert.SerialNumber.Int64()
+	}
+	config, err := f.get()
+	if err != nil {
+		t.Fatalf("Could not load the configuration: %v", err)
+	}
+	if s := serial(config); s != 2 {
+		t.Errorf("Wrong certificate, got serial %d expected 2", s)
+	}
+	if again, _ := f.get(); again != config {
+		t.Errorf("Configuration reloaded without modified files")
+	}
+
+	// a renewed certificate is loaded once its files are modified
+	renewed := testIssue(t, "server", 3, ca)
+	mtime := time.Now()
+	testWriteFile(t, f.certFile, renewed.certPEM, mtime)
+	testWriteFile(t, f.keyFile, renewed.keyPEM, mtime)
+	if config, err = f.get(); err != nil {
+		t.Fatalf("Could not reload the configuration: %v", err)
+	}
+	if s := serial(config); s != 3 {
+		t.Errorf("Wrong reloaded certificate, got serial %d expected 3", s)
+	}
+
+	// files which cannot be loaded, as while they are replaced, leave
+	// the previous configuration
+	testWriteFile(t, f.keyFile, []byte("not a key"), mtime.Add(time.Second))
+	kept, err := f.get()
+	if err != nil {
+		t.Errorf("Failed reload returned an error: %v", err)
+	}
+	if kept != config {
+		t.Errorf("Failed reload replaced the configuration")
+	}
+
+	// without a previous configuration, the error is returned
+	broken := &tlsFiles{certFile: f.certFile, keyFile: f.keyFile}
+	if _, err := broken.get(); err == nil {
+		t.Errorf("Invalid files loaded without an error")
+	}
+	s := NewServer(metrics.NewRegistry("test"), "127.0.0.1:0",
+		Options{CertFile: f.certFile, KeyFile: f.keyFile})
+	if err := s.Serve(); err == nil || err == http.ErrServerClosed {
+		t.Errorf("Wrong error from Serve with invalid files, got %v", err)
+	}
+}
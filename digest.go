@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"metrics/statistics"
+	"sync"
+	"time"
+)
+
+const digestDefaultCompression = 100
+
+// Digest stores a t-digest sketch of all values added since it was
+// created or reset, from which percentiles are estimated. Unlike a
+// Distribution, Digests from many processes can be combined: the
+// sketch returned by MarshalBinary may be decoded with
+// statistics.TDigest.UnmarshalBinary and merged into another
+// Digest or TDigest.
+type Digest struct {
+	t           *statistics.TDigest
+	lastUpdated time.Time
+	clock       Clock
+	lock        sync.RWMutex
+}
+
+type DigestSnapshot struct {
+	Count       float64
+	Mean        float64
+	Percentiles []float64
+	// Quantiles holds the quantile of each of the Percentiles.
+	Quantiles   []float64
+	Compression float64
+	LastUpdated time.Time
+}
+
+func newDigest(clock Clock, compression float64) *Digest {
+	return &Digest{
+		t:     statistics.NewTDigest(compression),
+		clock: clock,
+	}
+}
+
+// Reset removes all values from a Digest.
+func (d *Digest) Reset() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.t.Reset()
+	d.lastUpdated = time.Time{}
+}
+
+func (d *Digest) Add(v float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.t.Add(v)
+	d.lastUpdated = d.clock.Now()
+}
+
+// Merge adds all values in a sketch, such as one decoded from another
+// process's Digest, to the Digest.
+func (d *Digest) Merge(t *statistics.TDigest) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.t.Merge(t)
+	d.lastUpdated = d.clock.Now()
+}
+
+// MarshalBinary returns the binary encoding of the Digest's sketch.
+func (d *Digest) MarshalBinary() ([]byte, error) {
+	// compressing the sketch modifies it
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.t.MarshalBinary()
+}
+
+// Snapshot returns the number of values, their mean and estimates of
+// DistributionPercentiles, as they are when it is called.
+func (d *Digest) Snapshot() DigestSnapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	r := DigestSnapshot{
+		Count:       d.t.Count(),
+		Mean:        d.t.Mean(),
+		Percentiles: make([]float64, len(DistributionPercentiles)),
+		Quantiles:   append([]float64(nil), DistributionPercentiles...),
+		Compression: d.t.Compression(),
+		LastUpdated: d.lastUpdated,
+	}
+	for i, v := range r.Quantiles {
+		r.Percentiles[i] = d.t.Quantile(v)
+	}
+
+	return r
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"metrics/metricstest"
+	"metrics/statistics"
+	"testing"
+)
+
+func testDigestInit() *Digest {
+	d := newDigest(metricstest.NewClock(testTime), digestDefaultCompression)
+	for i := 0; i <= 1000; i++ {
+		d.Add(float64(i))
+	}
+	return d
+}
+
+func TestDigestSnapshot(t *testing.T) {
+	d := testDigestInit()
+	s := d.Snapshot()
+	if s.Count != 1001 || s.Mean != 500 {
+		t.Errorf("Wrong count and mean, got %f, %f expected %d, %d",
+			s.Count, s.Mean, 1001, 500)
+	}
+	if s.Percentiles[0] != 0 || s.Percentiles[len(s.Percentiles)-1] != 1000 {
+		t.Errorf("Wrong minimum and maximum, got %v", s.Percentiles)
+	}
+	if !s.LastUpdated.Equal(testTime) {
+		t.Errorf("Wrong updated time, got %v expected %v",
+			s.LastUpdated, testTime)
+	}
+}
+
+func TestDigestMerge(t *testing.T) {
+	d := testDigestInit()
+	b, err := testDigestInit().MarshalBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal digest: %v", err)
+	}
+
+	var other statistics.TDigest
+	if err := other.UnmarshalBinary(b); err != nil {
+		t.Fatalf("Could not unmarshal digest: %v", err)
+	}
+	d.Merge(&other)
+
+	s := d.Snapshot()
+	if s.Count != 2002 {
+		t.Errorf("Wrong count after merge, got %f expected %d", s.Count, 2002)
+	}
+
+	d.Reset()
+	if d.Snapshot().Count != 0 {
+		t.Errorf("Wrong count after reset, got %f", d.Snapshot().Count)
+	}
+}
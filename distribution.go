@@ -1,9 +1,11 @@
 package metrics
 
 import (
+	"math"
 	"math/rand"
 	"metrics/rbtree"
 	"metrics/statistics"
+	"sort"
 	"sync"
 	"time"
 )
@@ -14,7 +16,8 @@ const (
 )
 
 // The Percentiles slice in a DistributionSnapshot
-// contains the value of these percentiles in a Distribution.
+// contains the value of these percentiles in a Distribution,
+// unless it has its own set, given to SetPercentiles.
 // 0.0 is equivalent to minimum, 0.5 is equivalent to median,
 // and 1.0 is equivalent to maximum.
 var DistributionPercentiles = []float64{
@@ -25,6 +28,18 @@ var DistributionPercentiles = []float64{
 // Old samples are pruned based on a specified length of time
 // whenever the Distribution is written to, or before a
 // DistributionSnapshot is generated.
+//
+// An HDR Distribution, created by NewHDRDistribution, instead records
+// every value in a High Dynamic Range histogram. Its statistics and
+// percentiles reflect all values in the window to a fixed precision,
+// rather than a random sample of them, but individual values are not
+// kept. It records values only within its range: negative values are
+// recorded as 0, and values above its highest trackable value as that
+// value.
+//
+// A float Distribution, created by NewFloatDistribution, keeps float64
+// values. Other Distributions round the values given to AddFloat to
+// the nearest integer.
 type Distribution struct {
 	s              *statistics.Sample
 	times          *rbtree.Tree
@@ -33,6 +48,10 @@ type Distribution struct {
 	populationSize float64
 	maxSampleSize  uint64
 	rangeHint      [2]float64
+	percentiles    []float64
+	quantileMethod statistics.QuantileMethod
+	clock          Clock
+	hdr            *hdrWindow
 	lock           sync.RWMutex
 }
 
@@ -43,31 +62,64 @@ type DistributionSnapshot struct {
 	StandardDeviation float64
 	Skewness          float64
 	Kurtosis          float64
-	Percentiles       []int64
+	Percentiles       []Percentile
 	PopulationSize    float64
 	Window            time.Duration
 	RangeHint         [2]float64
 	LastUpdated       time.Time
 }
 
-func newDistribution() *Distribution {
+// Percentile is the value of a Distribution at a quantile between 0
+// and 1, as estimated by its quantile method.
+type Percentile struct {
+	Quantile float64
+	Value    float64
+}
+
+func newDistribution(clock Clock) *Distribution {
 	return &Distribution{
 		s:             statistics.NewSample(),
 		times:         rbtree.New(),
-		timeBase:      time.Now(),
+		timeBase:      clock.Now(),
+		clock:         clock,
 		window:        distributionDefaultWindow,
 		maxSampleSize: distributionDefaultMaxSamples,
 	}
 }
 
+func newFloatDistribution(clock Clock) *Distribution {
+	d := newDistribution(clock)
+	d.s = statistics.NewFloatSample()
+	return d
+}
+
+// newHDRDistribution returns nil if the histogram parameters are invalid.
+func newHDRDistribution(clock Clock, lowest, highest int64,
+	digits int) *Distribution {
+
+	d := newDistribution(clock)
+	d.hdr = newHDRWindow(lowest, highest, digits, d.window)
+	if d.hdr == nil {
+		return nil
+	}
+	return d
+}
+
 // Reset deletes all samples and statistics from a Distribution.
 func (d *Distribution) Reset() {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	d.s = statistics.NewSample()
+	if d.s.Float() {
+		d.s = statistics.NewFloatSample()
+	} else {
+		d.s = statistics.NewSample()
+	}
 	d.populationSize = 0
 	d.times = rbtree.New()
+	if d.hdr != nil {
+		d.hdr.reset()
+	}
 }
 
 func (d *Distribution) size() uint64 {
@@ -76,7 +128,8 @@ func (d *Distribution) size() uint64 {
 }
 
 // SetMaxSampleSize sets how many sample elements are kept.
-// The default is 1000 elements.
+// The default is 1000 elements. For an HDR Distribution, it only
+// limits how many values Samples returns.
 func (d *Distribution) SetMaxSampleSize(n uint64) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -93,19 +146,26 @@ func (d *Distribution) SetMaxSampleSize(n uint64) {
 // will be kept before a call to Prune removes it. Note that
 // elements are not guaranteed to be kept if the Distribution's
 // Count exceeds the limit set in SetMaxSampleSize.
-// The default is 10 minutes.
+// The default is 10 minutes. Setting the window of an HDR
+// Distribution discards all of its values.
 func (d *Distribution) SetWindow(nsec time.Duration) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
 	d.window = nsec
-	d.prune(time.Now())
+	if d.hdr != nil {
+		d.hdr.setWindow(nsec)
+	}
+	d.prune(d.clock.Now())
 }
 
 // Setting a range hint for a Distribution has no effect,
 // but it is exported in a DistributionSnapshot, where it may
 // be used externally. For example, if set, the dashboard uses
 // this hint to size graphs.
+//
+// Deprecated: set the Range of the Distribution's Metadata with
+// Registry.SetMetadata, which also describes the values' unit.
 func (d *Distribution) SetRangeHint(min, max float64) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -113,6 +173,111 @@ func (d *Distribution) SetRangeHint(min, max float64) {
 	d.rangeHint = [2]float64{min, max}
 }
 
+func (d *Distribution) rangeHintValue() [2]float64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return d.rangeHint
+}
+
+// SetPercentiles sets the quantiles, between 0 and 1, whose values are
+// in the Distribution's snapshots, in place of DistributionPercentiles.
+// They are sorted, and duplicates removed. It returns false, and leaves
+// the set unchanged, if any quantile is outside [0, 1]. If quantiles is
+// empty, DistributionPercentiles is used.
+func (d *Distribution) SetPercentiles(quantiles []float64) bool {
+	qs := make([]float64, 0, len(quantiles))
+	for _, q := range quantiles {
+		if !(q >= 0 && q <= 1) {
+			return false
+		}
+		qs = append(qs, q)
+	}
+	sort.Float64s(qs)
+	for i := 1; i < len(qs); {
+		if qs[i] == qs[i-1] {
+			qs = append(qs[:i], qs[i+1:]...)
+		} else {
+			i++
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.percentiles = nil
+	if len(qs) > 0 {
+		d.percentiles = qs
+	}
+	return true
+}
+
+// Percentiles returns the quantiles whose values are in the
+// Distribution's snapshots.
+func (d *Distribution) Percentiles() []float64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return append([]float64(nil), d.quantiles()...)
+}
+
+func (d *Distribution) quantiles() []float64 {
+	if d.percentiles != nil {
+		return d.percentiles
+	}
+	return DistributionPercentiles
+}
+
+// SetQuantileMethod sets how the Distribution's percentiles are
+// estimated from its sample. The default, statistics.NearestRank,
+// returns values in the sample, while the other methods interpolate
+// between them. An HDR Distribution ignores the method, since it
+// estimates percentiles from its histogram.
+func (d *Distribution) SetQuantileMethod(method statistics.QuantileMethod) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.quantileMethod = method
+}
+
+// Quantile returns the value of the Distribution at a quantile between
+// 0 and 1, estimated from the values within its window.
+func (d *Distribution) Quantile(q float64) float64 {
+	return d.Quantiles([]float64{q})[0]
+}
+
+// Quantiles returns the values of the Distribution at quantiles
+// between 0 and 1, estimated from the values within its window.
+// Quantiles above 1 are treated as 1, and others outside [0, 1] as 0.
+func (d *Distribution) Quantiles(quantiles []float64) []float64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	quantile := d.quantileFunc(d.clock.Now())
+	values := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		if !(q >= 0) {
+			q = 0
+		} else if q > 1 {
+			q = 1
+		}
+		values[i] = quantile(q)
+	}
+	return values
+}
+
+// quantileFunc returns a function estimating the quantiles of the
+// values within the window at now, after pruning those outside it.
+// The caller must hold the lock.
+func (d *Distribution) quantileFunc(now time.Time) func(float64) float64 {
+	if d.hdr != nil {
+		h := d.hdr.current(now.Sub(d.timeBase))
+		return func(q float64) float64 { return float64(h.Percentile(q)) }
+	}
+	d.prune(now)
+	return func(q float64) float64 { return d.s.Quantile(q, d.quantileMethod) }
+}
+
 // Add might insert/replace a sample into a Distribution, following
 // a random algorithm to maintain the maximum sample size set in
 // SetMaxSampleSize.
@@ -120,15 +285,63 @@ func (d *Distribution) Add(v int64) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	if d.hdr != nil {
+		now := d.clock.Now()
+		d.hdr.record(v, now.Sub(d.timeBase), now)
+		return
+	}
+
+	d.add(v, d.clock.Now(), d.randomRank())
+}
+
+// AddFloat is Add for a float64 value, which is rounded to the nearest
+// integer unless the Distribution is a float Distribution. NaNs and
+// infinities are ignored, as they cannot be encoded in JSON.
+func (d *Distribution) AddFloat(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.hdr != nil {
+		now := d.clock.Now()
+		d.hdr.record(int64(math.Round(v)), now.Sub(d.timeBase), now)
+		return
+	}
+
+	d.addFloat(v, d.clock.Now(), d.randomRank())
+}
+
+// randomRank returns the rank of the sample element that a new value
+// replaces if the sample is full, which is past its end if the value
+// is not to be kept.
+func (d *Distribution) randomRank() uint64 {
 	maxRand := int64(d.populationSize)
 	if maxRand == 0 {
-		d.add(v, time.Now(), 0)
-	} else {
-		d.add(v, time.Now(), uint64(rand.Int63n(maxRand)))
+		return 0
 	}
+	return uint64(rand.Int63n(maxRand))
 }
 
 func (d *Distribution) add(v int64, now time.Time, remove uint64) {
+	d.insert(now, remove, func() statistics.SampleElement {
+		return d.s.Add(v)
+	})
+}
+
+func (d *Distribution) addFloat(v float64, now time.Time, remove uint64) {
+	d.insert(now, remove, func() statistics.SampleElement {
+		return d.s.AddFloat(v)
+	})
+}
+
+// insert inserts the element added to the sample by add, unless the
+// sample is full and the element at rank remove is to be kept.
+func (d *Distribution) insert(now time.Time, remove uint64,
+	add func() statistics.SampleElement) {
+
 	d.populationSize++
 	if d.size() >= d.maxSampleSize {
 		if remove < d.maxSampleSize {
@@ -139,7 +352,7 @@ func (d *Distribution) add(v int64, now time.Time, remove uint64) {
 		}
 	}
 
-	se := d.s.Add(v)
+	se := add()
 	d.times.Insert(int64(now.Sub(d.timeBase)), se)
 	d.prune(now)
 }
@@ -150,7 +363,7 @@ func (d *Distribution) Prune() {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	d.prune(time.Now())
+	d.prune(d.clock.Now())
 }
 
 func (d *Distribution) prune(now time.Time) {
@@ -181,7 +394,12 @@ func (d *Distribution) Snapshot() DistributionSnapshot {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	d.prune(time.Now())
+	now := d.clock.Now()
+	if d.hdr != nil {
+		return d.hdrSnapshot(now)
+	}
+
+	quantile := d.quantileFunc(now)
 
 	var lastUpdated time.Time
 	if d.size() != 0 {
@@ -196,19 +414,29 @@ func (d *Distribution) Snapshot() DistributionSnapshot {
 		StandardDeviation: d.s.StandardDeviation(),
 		Skewness:          d.s.Skewness(),
 		Kurtosis:          d.s.Kurtosis(),
-		Percentiles:       make([]int64, len(DistributionPercentiles)),
+		Percentiles:       d.percentilePairs(quantile),
 		PopulationSize:    d.populationSize,
 		Window:            d.window,
 		RangeHint:         d.rangeHint,
 		LastUpdated:       lastUpdated,
 	}
-	for i, v := range DistributionPercentiles {
-		r.Percentiles[i] = d.s.Percentile(v)
-	}
 
 	return r
 }
 
+// percentilePairs returns the values of the Distribution's quantiles,
+// estimated by quantile.
+func (d *Distribution) percentilePairs(
+	quantile func(float64) float64) []Percentile {
+
+	qs := d.quantiles()
+	r := make([]Percentile, len(qs))
+	for i, q := range qs {
+		r[i] = Percentile{q, quantile(q)}
+	}
+	return r
+}
+
 // Samples returns up to limit sample elements (unlimited if limit = 0)
 // from the Distribution. These are taken between a time interval
 // specified with begin (inclusive) and end (non-inclusive).
@@ -221,14 +449,61 @@ func (d *Distribution) Snapshot() DistributionSnapshot {
 // -1 are returned.
 // If the end time is before the Distribution was created/reset, an empty
 // slice and a count of -1 are returned.
+//
+// An HDR Distribution does not keep individual values, so it returns
+// values spread evenly over the distribution of all values recorded
+// around the time interval, to the precision of its window's slices.
+// A float Distribution's values are rounded to the nearest integer.
 func (d *Distribution) Samples(limit uint64,
 	begin, end *time.Time) (vals []int64, count int64) {
 
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
+	if d.hdr != nil {
+		return d.hdrSamples(limit, begin, end)
+	}
+
+	elements, count := d.sampleElements(limit, begin, end)
+	vals = make([]int64, len(elements))
+	for i, se := range elements {
+		vals[i] = se.Value()
+	}
+	return vals, count
+}
+
+// FloatSamples is Samples returning float64 values, which are not
+// rounded.
+func (d *Distribution) FloatSamples(limit uint64,
+	begin, end *time.Time) (vals []float64, count int64) {
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if d.hdr != nil {
+		ints, count := d.hdrSamples(limit, begin, end)
+		vals = make([]float64, len(ints))
+		for i, v := range ints {
+			vals[i] = float64(v)
+		}
+		return vals, count
+	}
+
+	elements, count := d.sampleElements(limit, begin, end)
+	vals = make([]float64, len(elements))
+	for i, se := range elements {
+		vals[i] = se.FloatValue()
+	}
+	return vals, count
+}
+
+// sampleElements is Samples returning the sample elements.
+// The caller must hold the lock.
+func (d *Distribution) sampleElements(limit uint64,
+	begin, end *time.Time) ([]statistics.SampleElement, int64) {
+
 	if d.size() == 0 {
-		return make([]int64, 0), 0
+		return nil, 0
 	}
 	if limit > d.size() || limit == 0 {
 		limit = d.size()
@@ -240,7 +515,7 @@ func (d *Distribution) Samples(limit uint64,
 	if begin != nil {
 		beginNode = d.times.LowerBound(int64(begin.Sub(d.timeBase)))
 		if beginNode == nil {
-			return make([]int64, 0), 0
+			return nil, 0
 		}
 		beginRank = d.times.Rank(beginNode)
 	} else {
@@ -250,12 +525,12 @@ func (d *Distribution) Samples(limit uint64,
 
 	if end != nil {
 		if end.Before(d.timeBase) {
-			return make([]int64, 0), -1
+			return nil, -1
 		}
 
 		endNode = d.times.UpperBound(int64(end.Sub(d.timeBase)))
 		if endNode == nil {
-			return make([]int64, 0), 0
+			return nil, 0
 		}
 		endRank = d.times.Rank(endNode)
 	} else {
@@ -264,27 +539,27 @@ func (d *Distribution) Samples(limit uint64,
 	}
 
 	if endRank < beginRank {
-		return make([]int64, 0), -1
+		return nil, -1
 	}
 
 	ct := endRank - beginRank + 1
-	var m []int64
+	var m []statistics.SampleElement
 	if limit >= ct {
 		// get everything
-		m = make([]int64, ct)
+		m = make([]statistics.SampleElement, ct)
 		for n, i := beginNode, uint64(0); n != nil; n, i = d.times.Next(n), i+1 {
-			m[i] = n.Value().(statistics.SampleElement).Value()
+			m[i] = n.Value().(statistics.SampleElement)
 			if n == endNode {
 				break
 			}
 		}
 	} else {
-		m = make([]int64, limit)
+		m = make([]statistics.SampleElement, limit)
 		s := randCombination(ct, limit)
 		var i uint64 = 0
 		for v := range s {
 			n := d.times.FindByRank(v + beginRank)
-			m[i] = n.Value().(statistics.SampleElement).Value()
+			m[i] = n.Value().(statistics.SampleElement)
 			i++
 		}
 	}
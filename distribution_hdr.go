@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"metrics/statistics"
+	"time"
+)
+
+// Number of histograms over which an HDR Distribution's window is
+// divided. Values expire one slice at a time, so the effective window
+// is between 9/10 of and the full window set by SetWindow.
+const distributionHDRSlices = 10
+
+// hdrWindow records the values of an HDR Distribution. The time since
+// the Distribution's time base is divided into slices of a tenth of the
+// window, and each slice is recorded in its own Histogram. Slice k is
+// kept in histograms[k % distributionHDRSlices], which is reset when
+// slice k begins.
+type hdrWindow struct {
+	histograms []*statistics.Histogram
+	// scratch holds the values within the window, merged by current
+	scratch     *statistics.Histogram
+	slices      []int64
+	sliceLength time.Duration
+	lastUpdated time.Time
+}
+
+func newHDRWindow(lowest, highest int64, digits int,
+	window time.Duration) *hdrWindow {
+
+	w := &hdrWindow{
+		histograms: make([]*statistics.Histogram, distributionHDRSlices),
+		slices:     make([]int64, distributionHDRSlices),
+	}
+	for i := range w.histograms {
+		w.histograms[i] = statistics.NewHistogram(lowest, highest, digits)
+		if w.histograms[i] == nil {
+			return nil
+		}
+	}
+	w.scratch = statistics.NewHistogram(lowest, highest, digits)
+	w.setWindow(window)
+	return w
+}
+
+func (w *hdrWindow) reset() {
+	for i, h := range w.histograms {
+		h.Reset()
+		w.slices[i] = int64(i)
+	}
+	w.lastUpdated = time.Time{}
+}
+
+// setWindow discards all values, since they cannot be reassigned
+// to slices of a different length.
+func (w *hdrWindow) setWindow(window time.Duration) {
+	w.sliceLength = window / distributionHDRSlices
+	w.reset()
+}
+
+func (w *hdrWindow) slice(sinceBase time.Duration) int64 {
+	if w.sliceLength <= 0 || sinceBase < 0 {
+		return 0
+	}
+	return int64(sinceBase / w.sliceLength)
+}
+
+func (w *hdrWindow) record(v int64, sinceBase time.Duration, now time.Time) {
+	k := w.slice(sinceBase)
+	i := k % distributionHDRSlices
+	if w.slices[i] != k {
+		w.histograms[i].Reset()
+		w.slices[i] = k
+	}
+	w.histograms[i].Record(v)
+	if now.After(w.lastUpdated) {
+		w.lastUpdated = now
+	}
+}
+
+// merged returns a new Histogram of the values recorded in slices
+// first to last, inclusive.
+func (w *hdrWindow) merged(first, last int64) *statistics.Histogram {
+	h0 := w.histograms[0]
+	m := statistics.NewHistogram(h0.Lowest(), h0.Highest(), h0.Digits())
+	w.mergeInto(m, first, last)
+	return m
+}
+
+func (w *hdrWindow) mergeInto(m *statistics.Histogram, first, last int64) {
+	for i, h := range w.histograms {
+		if w.slices[i] >= first && w.slices[i] <= last {
+			m.Merge(h)
+		}
+	}
+}
+
+// current returns a Histogram of the values within the window. It
+// reuses the window's scratch Histogram, so the result is only valid
+// until current is called again, and the caller must hold the
+// Distribution's lock for writing.
+func (w *hdrWindow) current(sinceBase time.Duration) *statistics.Histogram {
+	k := w.slice(sinceBase)
+	w.scratch.Reset()
+	w.mergeInto(w.scratch, k-distributionHDRSlices+1, k)
+	return w.scratch
+}
+
+func (d *Distribution) hdrSnapshot(now time.Time) DistributionSnapshot {
+	h := d.hdr.current(now.Sub(d.timeBase))
+
+	var lastUpdated time.Time
+	if h.Count() != 0 {
+		lastUpdated = d.hdr.lastUpdated
+	}
+
+	quantile := func(q float64) float64 { return float64(h.Percentile(q)) }
+	r := DistributionSnapshot{
+		Count:             h.Count(),
+		Mean:              h.Mean(),
+		Variance:          h.Variance(),
+		StandardDeviation: h.StandardDeviation(),
+		Skewness:          h.Skewness(),
+		Kurtosis:          h.Kurtosis(),
+		Percentiles:       d.percentilePairs(quantile),
+		PopulationSize:    float64(h.Count()),
+		Window:            d.window,
+		RangeHint:         d.rangeHint,
+		LastUpdated:       lastUpdated,
+	}
+
+	return r
+}
+
+// hdrSamples returns values spread evenly over those recorded in the
+// slices overlapping the interval from begin to end, since individual
+// values are not kept.
+func (d *Distribution) hdrSamples(limit uint64,
+	begin, end *time.Time) (vals []int64, count int64) {
+
+	if end != nil && end.Before(d.timeBase) {
+		return make([]int64, 0), -1
+	}
+	if begin != nil && end != nil && end.Before(*begin) {
+		return make([]int64, 0), -1
+	}
+
+	// slices before the window have expired
+	last := d.hdr.slice(d.clock.Now().Sub(d.timeBase))
+	first := last - distributionHDRSlices + 1
+	if begin != nil {
+		if k := d.hdr.slice(begin.Sub(d.timeBase)); k > first {
+			first = k
+		}
+	}
+	if end != nil {
+		if k := d.hdr.slice(end.Sub(d.timeBase)); k < last {
+			last = k
+		}
+	}
+
+	h := d.hdr.merged(first, last)
+	if limit > h.Count() || limit == 0 {
+		limit = h.Count()
+	}
+	if limit > d.maxSampleSize {
+		limit = d.maxSampleSize
+	}
+	return h.Values(limit), int64(h.Count())
+}
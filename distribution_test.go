@@ -1,8 +1,13 @@
 package metrics
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 	"math/rand"
+	"metrics/metricstest"
+	"metrics/statistics"
+	"sort"
 	"testing"
 	"time"
 )
@@ -17,7 +22,7 @@ func testCompareSlices(a []int64, b []int64) bool {
 }
 
 func testDistributionInit() *Distribution {
-	d := newDistribution()
+	d := newDistribution(SystemClock)
 	d.SetWindow(time.Hour * 24 * 365 * 200)
 	d.add(12, testTime, 0)
 	d.add(-9, testTime.Add(1), 0)
@@ -146,7 +151,7 @@ func TestDistributionRemoveFromPopulation(t *testing.T) {
 	}
 
 	if d.populationSize != 3 {
-		t.Errorf("Wrong population size after remove, got %d expected %d",
+		t.Errorf("Wrong population size after remove, got %f expected %d",
 			d.populationSize, 3)
 	}
 }
@@ -217,7 +222,7 @@ func TestDistributionSamples(t *testing.T) {
 }
 
 func BenchmarkDistributionAdd(b *testing.B) {
-	d := newDistribution()
+	d := newDistribution(SystemClock)
 	for i := 0; i < b.N; i++ {
 		d.Add(rand.Int63())
 		if i%10000 == 0 {
@@ -225,3 +230,225 @@ func BenchmarkDistributionAdd(b *testing.B) {
 		}
 	}
 }
+
+func TestHDRDistribution(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	if newHDRDistribution(c, 0, 1000, 3) != nil {
+		t.Errorf("HDR Distribution with invalid parameters was created")
+	}
+
+	d := newHDRDistribution(c, 1, 1000*1000, 3)
+	d.SetWindow(10 * time.Minute)
+	for i := int64(1); i <= 1000; i++ {
+		d.Add(i)
+	}
+
+	s := d.Snapshot()
+	if s.Count != 1000 || s.PopulationSize != 1000 {
+		t.Errorf("Wrong count, got %d, %f expected %d", s.Count,
+			s.PopulationSize, 1000)
+	}
+	expected := []int64{1, 250, 500, 750, 950, 990, 999, 1000}
+	if !testCompareSlices(testPercentileValues(s.Percentiles), expected) {
+		t.Errorf("Wrong percentiles, got %v expected %v",
+			s.Percentiles, expected)
+	}
+	if math.Abs(s.Mean-500.5) > 1e-13 {
+		t.Errorf("Wrong mean, got %f expected %f", s.Mean, 500.5)
+	}
+	if !s.LastUpdated.Equal(testTime) {
+		t.Errorf("Wrong updated time, got %v expected %v",
+			s.LastUpdated, testTime)
+	}
+
+	samples, n := d.Samples(4, nil, nil)
+	if n != 1000 || !testCompareSlices(samples, []int64{126, 376, 626, 876}) {
+		t.Errorf("Wrong samples, got %v, %d", samples, n)
+	}
+
+	c.Add(5 * time.Minute)
+	d.Add(2000)
+	end := testTime.Add(time.Minute)
+	if _, n = d.Samples(0, nil, &end); n != 1000 {
+		t.Errorf("Wrong sample count before end, got %d expected %d", n, 1000)
+	}
+
+	c.Add(5*time.Minute + time.Second)
+	s = d.Snapshot()
+	if s.Count != 1 || s.Percentiles[0].Value != 2000 {
+		t.Errorf("Values outside the window were not expired, got %v", s)
+	}
+
+	d.Reset()
+	if d.Snapshot().Count != 0 {
+		t.Errorf("Wrong count after reset, got %d", d.Snapshot().Count)
+	}
+}
+
+func TestHDRDistributionRange(t *testing.T) {
+	d := newHDRDistribution(metricstest.NewClock(testTime), 1, 1000, 3)
+	d.Add(-5)
+	d.Add(5000)
+
+	// the scratch histogram does not accumulate across snapshots
+	for i := 0; i < 2; i++ {
+		s := d.Snapshot()
+		if s.Count != 2 {
+			t.Errorf("Wrong count, got %d expected 2", s.Count)
+		}
+		if p := s.Percentiles[0].Value; p != 0 {
+			t.Errorf("Wrong minimum, got %v expected 0", p)
+		}
+		if p := s.Percentiles[len(s.Percentiles)-1].Value; p != 1000 {
+			t.Errorf("Wrong maximum, got %v expected 1000", p)
+		}
+	}
+}
+
+func BenchmarkHDRDistributionSnapshot(b *testing.B) {
+	d := newHDRDistribution(SystemClock, 1, 1000*1000, 3)
+	for i := int64(1); i <= 1000; i++ {
+		d.Add(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Snapshot()
+	}
+}
+
+func testPercentileValues(ps []Percentile) []int64 {
+	values := make([]int64, len(ps))
+	for i, p := range ps {
+		values[i] = int64(p.Value)
+	}
+	return values
+}
+
+func TestDistributionPercentiles(t *testing.T) {
+	d := newDistribution(SystemClock)
+	for i := int64(1); i <= 100; i++ {
+		d.Add(i)
+	}
+
+	s := d.Snapshot()
+	if len(s.Percentiles) != len(DistributionPercentiles) {
+		t.Fatalf("Wrong number of percentiles, got %d expected %d",
+			len(s.Percentiles), len(DistributionPercentiles))
+	}
+	for i, p := range s.Percentiles {
+		if p.Quantile != DistributionPercentiles[i] {
+			t.Errorf("Wrong quantile, got %f expected %f",
+				p.Quantile, DistributionPercentiles[i])
+		}
+	}
+
+	if d.SetPercentiles([]float64{0.5, 1.5}) {
+		t.Errorf("SetPercentiles accepted a quantile above 1")
+	}
+	if !d.SetPercentiles([]float64{0.9, 0.1, 0.9}) {
+		t.Fatalf("SetPercentiles rejected valid quantiles")
+	}
+	s = d.Snapshot()
+	expected := []Percentile{{0.1, d.Quantile(0.1)}, {0.9, d.Quantile(0.9)}}
+	if len(s.Percentiles) != 2 || s.Percentiles[0] != expected[0] ||
+		s.Percentiles[1] != expected[1] {
+		t.Errorf("Wrong percentiles, got %v expected %v",
+			s.Percentiles, expected)
+	}
+
+	d.SetPercentiles(nil)
+	if n := len(d.Percentiles()); n != len(DistributionPercentiles) {
+		t.Errorf("Wrong number of percentiles, got %d expected %d",
+			n, len(DistributionPercentiles))
+	}
+}
+
+func TestDistributionQuantiles(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	d := newHDRDistribution(c, 1, 1000, 3)
+	for i := int64(1); i <= 1000; i++ {
+		d.Add(i)
+	}
+
+	got := d.Quantiles([]float64{-1, 0.5, 0.9, 2})
+	expected := []float64{1, 500, 900, 1000}
+	if len(got) != len(expected) || got[0] != expected[0] ||
+		got[1] != expected[1] || got[2] != expected[2] ||
+		got[3] != expected[3] {
+		t.Errorf("Wrong quantiles, got %v expected %v", got, expected)
+	}
+	if q := d.Quantile(0.25); q != 250 {
+		t.Errorf("Wrong quantile, got %v expected 250", q)
+	}
+}
+
+func TestDistributionQuantileMethod(t *testing.T) {
+	d := newDistribution(SystemClock)
+	for _, v := range []int64{1, 2, 3, 4} {
+		d.Add(v)
+	}
+
+	if q := d.Quantile(0.5); q != 3 {
+		t.Errorf("Wrong nearest rank median, got %v expected 3", q)
+	}
+	d.SetQuantileMethod(statistics.HyndmanFan7)
+	if q := d.Quantile(0.5); q != 2.5 {
+		t.Errorf("Wrong type 7 median, got %v expected 2.5", q)
+	}
+	s := d.Snapshot()
+	if p := s.Percentiles[1]; p.Quantile != 0.25 || p.Value != 1.75 {
+		t.Errorf("Wrong type 7 percentile, got %v expected {0.25 1.75}", p)
+	}
+}
+
+func TestFloatDistribution(t *testing.T) {
+	d := newFloatDistribution(SystemClock)
+	for _, v := range []float64{0.25, 0.5, 1.5, math.NaN(), math.Inf(1),
+		math.Inf(-1)} {
+
+		d.AddFloat(v)
+	}
+	d.Add(2)
+
+	s := d.Snapshot()
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("Could not marshal snapshot: %v", err)
+	}
+	if s.Count != 4 {
+		t.Errorf("Wrong count, got %d expected 4", s.Count)
+	}
+	if s.Mean != 1.0625 {
+		t.Errorf("Wrong mean, got %v expected 1.0625", s.Mean)
+	}
+	if q := d.Quantile(0.5); q != 1.5 {
+		t.Errorf("Wrong median, got %v expected 1.5", q)
+	}
+
+	vals, count := d.FloatSamples(0, nil, nil)
+	sort.Float64s(vals)
+	expected := []float64{0.25, 0.5, 1.5, 2}
+	if count != 4 || fmt.Sprint(vals) != fmt.Sprint(expected) {
+		t.Errorf("Wrong samples, got %v (%d) expected %v (4)",
+			vals, count, expected)
+	}
+	ints, _ := d.Samples(0, nil, nil)
+	sort.Slice(ints, func(i, j int) bool { return ints[i] < ints[j] })
+	if !testCompareSlices(ints, []int64{0, 1, 2, 2}) {
+		t.Errorf("Wrong rounded samples, got %v expected [0 1 2 2]", ints)
+	}
+
+	d.Reset()
+	d.AddFloat(0.75)
+	if q := d.Quantile(1); q != 0.75 {
+		t.Errorf("Reset lost float values, got %v expected 0.75", q)
+	}
+
+	// other Distributions round float values
+	d = newDistribution(SystemClock)
+	d.AddFloat(2.5)
+	d.AddFloat(-0.4)
+	if q := d.Quantile(1); q != 3 {
+		t.Errorf("Wrong rounded maximum, got %v expected 3", q)
+	}
+}
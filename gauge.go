@@ -3,14 +3,15 @@ package metrics
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 // Gauge stores a single, instantaneous Gaugable value.
 // It is updated using a stored GaugeFunction.
 type Gauge struct {
-	value    Gaugable
-	function GaugeFunction
-	lock     sync.RWMutex
+	gaugeUpdater
+	value Gaugable
+	lock  sync.RWMutex
 }
 
 type GaugeSnapshot struct {
@@ -24,8 +25,8 @@ type Gaugable interface {
 // A gauge's GaugeFunction is called each time it is updated.
 type GaugeFunction func() Gaugable
 
-func newGauge() *Gauge {
-	return &Gauge{}
+func newGauge(clock Clock) *Gauge {
+	return &Gauge{gaugeUpdater: gaugeUpdater{clock: clock}}
 }
 
 // Reset clears a Gauge's value.
@@ -33,31 +34,31 @@ func (g *Gauge) Reset() {
 	g.lock.Lock()
 	g.value = nil
 	g.lock.Unlock()
+	g.reset()
 }
 
 // SetFunction associates a GaugeFunction to a Gauge.
 func (g *Gauge) SetFunction(fn GaugeFunction) {
-	g.lock.Lock()
-	g.function = fn
-	g.lock.Unlock()
-}
-
-// Update calls the function set in SetFunction, passing
-// the Gauge as its first argument. It stores the function's
-// return value as the Gauge's value.
-func (g *Gauge) Update() {
-	g.lock.Lock()
-	defer g.lock.Unlock()
-	if g.function != nil {
-		g.value = g.function()
+	if fn == nil {
+		g.setFunction(nil)
+		return
 	}
+	g.setFunction(func() {
+		v := fn()
+		g.lock.Lock()
+		g.value = v
+		g.lock.Unlock()
+	})
 }
 
-// Snapshot returns the value of a Gauge.
+// Snapshot returns the value of a Gauge, updating it first if it is
+// older than the maximum age set by SetMaxAge.
 // The value is the Gaugable object. If a string is
 // required, it must be converted manually using the
 // Gaugable's String() method.
 func (g *Gauge) Snapshot() GaugeSnapshot {
+	g.refresh()
+
 	g.lock.RLock()
 
 	r := GaugeSnapshot{
@@ -67,3 +68,109 @@ func (g *Gauge) Snapshot() GaugeSnapshot {
 	g.lock.RUnlock()
 	return r
 }
+
+// gaugeUpdater calls the function of a gauge, which computes and
+// stores its value. Only one call runs at a time. An update requested
+// while one is running waits for its result instead.
+type gaugeUpdater struct {
+	function func()
+	updated  time.Time
+	pending  chan bool
+	maxAge   time.Duration
+	timeout  time.Duration
+	clock    Clock
+	lock     sync.Mutex
+}
+
+func (u *gaugeUpdater) setFunction(fn func()) {
+	u.lock.Lock()
+	u.function = fn
+	u.lock.Unlock()
+}
+
+func (u *gaugeUpdater) reset() {
+	u.lock.Lock()
+	u.updated = time.Time{}
+	u.lock.Unlock()
+}
+
+// SetMaxAge makes Snapshot update the gauge first if it was last
+// updated at least maxAge ago, so that its value is never older than
+// maxAge when read. A maxAge of 0, the default, disables this.
+func (u *gaugeUpdater) SetMaxAge(maxAge time.Duration) {
+	u.lock.Lock()
+	u.maxAge = maxAge
+	u.lock.Unlock()
+}
+
+// SetTimeout limits the time Update waits for the gauge's function.
+// If it takes longer, Update returns leaving the previous value in
+// place, and the value is stored when the function returns.
+// A timeout of 0, the default, waits indefinitely.
+func (u *gaugeUpdater) SetTimeout(timeout time.Duration) {
+	u.lock.Lock()
+	u.timeout = timeout
+	u.lock.Unlock()
+}
+
+// start calls the function in its own goroutine, unless a call is
+// already running. It returns a channel which is closed when the
+// call returns, or nil if there is no function, and the timeout.
+func (u *gaugeUpdater) start() (done chan bool, timeout time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.function == nil {
+		return nil, 0
+	}
+	if u.pending != nil {
+		return u.pending, u.timeout
+	}
+
+	fn := u.function
+	done = make(chan bool)
+	u.pending = done
+	go func() {
+		defer close(done)
+		fn()
+		u.lock.Lock()
+		u.updated = u.clock.Now()
+		u.pending = nil
+		u.lock.Unlock()
+	}()
+	return done, u.timeout
+}
+
+// Update calls the function set in SetFunction, and stores its
+// return value as the gauge's value. It waits for the function to
+// return, for at most the timeout set by SetTimeout.
+func (u *gaugeUpdater) Update() {
+	done, timeout := u.start()
+	if done == nil {
+		return
+	}
+	if timeout == 0 {
+		<-done
+		return
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+	}
+}
+
+// refresh updates the gauge if its value is older than the maximum
+// age set by SetMaxAge.
+func (u *gaugeUpdater) refresh() {
+	u.lock.Lock()
+	stale := u.maxAge > 0 && u.function != nil &&
+		u.clock.Now().Sub(u.updated) >= u.maxAge
+	u.lock.Unlock()
+
+	if stale {
+		u.Update()
+	}
+}
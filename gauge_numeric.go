@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// IntGauge stores a single, instantaneous int64 value. The value is
+// either set directly, or computed by a function when the IntGauge is
+// updated, as for a Gauge.
+type IntGauge struct {
+	// value is first, so that it is 64-bit aligned for atomic
+	// operations on 32-bit platforms
+	value int64
+	gaugeUpdater
+}
+
+type IntGaugeSnapshot struct {
+	Value int64
+}
+
+func newIntGauge(clock Clock) *IntGauge {
+	return &IntGauge{gaugeUpdater: gaugeUpdater{clock: clock}}
+}
+
+// Reset sets an IntGauge's value to 0.
+func (g *IntGauge) Reset() {
+	atomic.StoreInt64(&g.value, 0)
+	g.reset()
+}
+
+// Set sets an IntGauge's value.
+func (g *IntGauge) Set(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+// Add adds delta, which may be negative, to an IntGauge's value.
+func (g *IntGauge) Add(delta int64) {
+	atomic.AddInt64(&g.value, delta)
+}
+
+// SetFunction sets a function which computes the IntGauge's value
+// each time it is updated.
+func (g *IntGauge) SetFunction(fn func() int64) {
+	if fn == nil {
+		g.setFunction(nil)
+		return
+	}
+	g.setFunction(func() { g.Set(fn()) })
+}
+
+// Value returns an IntGauge's value, updating it first if it is older
+// than the maximum age set by SetMaxAge.
+func (g *IntGauge) Value() int64 {
+	g.refresh()
+	return atomic.LoadInt64(&g.value)
+}
+
+func (g *IntGauge) Snapshot() IntGaugeSnapshot {
+	return IntGaugeSnapshot{Value: g.Value()}
+}
+
+// FloatGauge stores a single, instantaneous float64 value. The value
+// is either set directly, or computed by a function when the
+// FloatGauge is updated, as for a Gauge.
+type FloatGauge struct {
+	bits uint64
+	gaugeUpdater
+}
+
+type FloatGaugeSnapshot struct {
+	Value float64
+}
+
+func newFloatGauge(clock Clock) *FloatGauge {
+	return &FloatGauge{gaugeUpdater: gaugeUpdater{clock: clock}}
+}
+
+// Reset sets a FloatGauge's value to 0.
+func (g *FloatGauge) Reset() {
+	atomic.StoreUint64(&g.bits, 0)
+	g.reset()
+}
+
+// Set sets a FloatGauge's value.
+func (g *FloatGauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta, which may be negative, to a FloatGauge's value.
+func (g *FloatGauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		v := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, v) {
+			return
+		}
+	}
+}
+
+// SetFunction sets a function which computes the FloatGauge's value
+// each time it is updated.
+func (g *FloatGauge) SetFunction(fn func() float64) {
+	if fn == nil {
+		g.setFunction(nil)
+		return
+	}
+	g.setFunction(func() { g.Set(fn()) })
+}
+
+// Value returns a FloatGauge's value, updating it first if it is
+// older than the maximum age set by SetMaxAge.
+func (g *FloatGauge) Value() float64 {
+	g.refresh()
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Snapshot returns a FloatGauge's value. Values that are not finite
+// are replaced by 0, since they cannot be represented in JSON.
+func (g *FloatGauge) Snapshot() FloatGaugeSnapshot {
+	v := g.Value()
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		v = 0
+	}
+	return FloatGaugeSnapshot{Value: v}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"math"
+	"metrics/metricstest"
+	"testing"
+	"time"
+)
+
+func TestIntGauge(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	g := r.NewIntGauge(testRegistryType{}, "int")
+
+	g.Set(5)
+	g.Add(-7)
+	if v := g.Value(); v != -2 {
+		t.Errorf("Wrong value, got %d expected -2", v)
+	}
+	if s := g.Snapshot(); s.Value != -2 {
+		t.Errorf("Wrong snapshot value, got %d expected -2", s.Value)
+	}
+
+	g.Reset()
+	if v := g.Value(); v != 0 {
+		t.Errorf("Wrong value after reset, got %d expected 0", v)
+	}
+
+	if r.NewIntGauge(testRegistryType{}, "int") != nil {
+		t.Errorf("NewIntGauge registered the same name twice")
+	}
+}
+
+func TestFloatGauge(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	g := r.NewFloatGauge(testRegistryType{}, "float")
+
+	g.Set(1.5)
+	g.Add(0.25)
+	if v := g.Value(); v != 1.75 {
+		t.Errorf("Wrong value, got %f expected 1.75", v)
+	}
+
+	g.Set(math.NaN())
+	if v := g.Value(); !math.IsNaN(v) {
+		t.Errorf("Wrong value, got %f expected NaN", v)
+	}
+	if s := g.Snapshot(); s.Value != 0 {
+		t.Errorf("Wrong snapshot value, got %f expected 0", s.Value)
+	}
+}
+
+func TestNumericGaugeFunction(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	r := NewRegistryClock("testRegistry", c)
+	i := r.NewIntGauge(testRegistryType{}, "int")
+	f := r.NewFloatGauge(testRegistryType{}, "float")
+
+	var calls int64
+	i.SetFunction(func() int64 {
+		calls++
+		return calls
+	})
+	f.SetFunction(func() float64 { return 0.5 })
+
+	r.UpdateGauges()
+	if v := i.Value(); v != 1 {
+		t.Errorf("Wrong value, got %d expected 1", v)
+	}
+	if v := f.Value(); v != 0.5 {
+		t.Errorf("Wrong value, got %f expected 0.5", v)
+	}
+
+	r.SetGaugeMaxAge(time.Minute)
+	if v := i.Value(); v != 1 {
+		t.Errorf("Wrong value before max age, got %d expected 1", v)
+	}
+	c.Add(time.Minute)
+	if v := i.Value(); v != 2 {
+		t.Errorf("Wrong value after max age, got %d expected 2", v)
+	}
+
+	list := r.List()
+	types := make(map[string]string)
+	for _, l := range list {
+		types[l[0]] = l[1]
+	}
+	if ty := types["metrics.testRegistryType.int"]; ty != "intgauge" {
+		t.Errorf("Wrong type, got %s expected intgauge", ty)
+	}
+	if ty := types["metrics.testRegistryType.float"]; ty != "floatgauge" {
+		t.Errorf("Wrong type, got %s expected floatgauge", ty)
+	}
+}
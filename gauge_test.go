@@ -15,7 +15,7 @@ func (t *testGaugable) String() string {
 }
 
 func testGaugeInit() *Gauge {
-	g := newGauge()
+	g := newGauge(SystemClock)
 	t := testGaugable{}
 	g.SetFunction(func() Gaugable {
 		t.value += 5
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"time"
+)
+
+// gaugeSettings holds the settings a Registry applies to its gauges,
+// and the state of its periodic updates.
+type gaugeSettings struct {
+	maxAge  time.Duration
+	timeout time.Duration
+	stop    chan bool
+	done    chan bool
+}
+
+// updatedGauge is implemented by Gauges, IntGauges and FloatGauges.
+type updatedGauge interface {
+	SetMaxAge(time.Duration)
+	SetTimeout(time.Duration)
+	start() (chan bool, time.Duration)
+}
+
+// apply applies the settings to a newly registered metric, if it is
+// a gauge.
+func (s *gaugeSettings) apply(m Metric) {
+	if g, ok := m.(updatedGauge); ok {
+		g.SetMaxAge(s.maxAge)
+		g.SetTimeout(s.timeout)
+	}
+}
+
+// gaugesLocked returns the receiver's gauges, including members of
+// labelled families and gauges of sub-registries. The caller must hold
+// the lock.
+func (r *Registry) gaugesLocked() []updatedGauge {
+	var gauges []updatedGauge
+	for name, m := range r.metrics {
+		if _, ok := r.localName(name); !ok {
+			continue
+		}
+		if g, ok := m.(updatedGauge); ok {
+			gauges = append(gauges, g)
+		}
+	}
+	return gauges
+}
+
+// SetGaugeMaxAge sets the maximum age of the values of all gauges in
+// the receiver, present and future, as with Gauge.SetMaxAge. Present
+// gauges of its sub-registries are included, and sub-registries
+// created later inherit the setting.
+func (r *Registry) SetGaugeMaxAge(maxAge time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.gauges.maxAge = maxAge
+	for _, g := range r.gaugesLocked() {
+		g.SetMaxAge(maxAge)
+	}
+}
+
+// SetGaugeTimeout sets the update timeout of all gauges in the
+// receiver, present and future, as with Gauge.SetTimeout.
+// Sub-registries are treated as by SetGaugeMaxAge.
+func (r *Registry) SetGaugeTimeout(timeout time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.gauges.timeout = timeout
+	for _, g := range r.gaugesLocked() {
+		g.SetTimeout(timeout)
+	}
+}
+
+// startGauges starts updating every gauge in the receiver, each in
+// its own goroutine, so that a slow function does not delay
+// the others.
+func (r *Registry) startGauges() (done []chan bool, timeouts []time.Duration) {
+	r.lock.RLock()
+	gauges := r.gaugesLocked()
+	r.lock.RUnlock()
+
+	for _, g := range gauges {
+		if d, timeout := g.start(); d != nil {
+			done = append(done, d)
+			timeouts = append(timeouts, timeout)
+		}
+	}
+	return done, timeouts
+}
+
+// UpdateGauges updates every gauge in the receiver concurrently, and
+// waits for them, for at most each gauge's timeout.
+func (r *Registry) UpdateGauges() {
+	begin := time.Now()
+	done, timeouts := r.startGauges()
+	for i, d := range done {
+		if timeouts[i] == 0 {
+			<-d
+			continue
+		}
+		t := time.NewTimer(timeouts[i] - time.Since(begin))
+		select {
+		case <-d:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+// StartGaugeUpdates starts updating every gauge in the receiver at an
+// interval, in the background, replacing any previous schedule.
+// Each update of a gauge starts unless the previous one is still
+// running, and does not wait for the others.
+func (r *Registry) StartGaugeUpdates(interval time.Duration) {
+	r.StopGaugeUpdates()
+
+	stop, done := make(chan bool), make(chan bool)
+	r.lock.Lock()
+	r.gauges.stop, r.gauges.done = stop, done
+	r.lock.Unlock()
+
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		r.startGauges()
+		for {
+			select {
+			case <-t.C:
+				r.startGauges()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopGaugeUpdates stops the updates started by StartGaugeUpdates.
+// Updates already running are not interrupted.
+func (r *Registry) StopGaugeUpdates() {
+	r.lock.Lock()
+	stop, done := r.gauges.stop, r.gauges.done
+	r.gauges.stop, r.gauges.done = nil, nil
+	r.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"metrics/metricstest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testGaugeValue int64
+
+func (v testGaugeValue) String() string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+// testCountingGauge returns a Gauge whose value is the number of times
+// its function has been called.
+func testCountingGauge(g *Gauge) *int64 {
+	var calls int64
+	g.SetFunction(func() Gaugable {
+		return testGaugeValue(atomic.AddInt64(&calls, 1))
+	})
+	return &calls
+}
+
+func TestGaugeMaxAge(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	r := NewRegistryClock("testRegistry", c)
+	g := r.NewGauge(testRegistryType{}, "gauge")
+	calls := testCountingGauge(g)
+
+	// without a maximum age, Snapshot does not update
+	if s := g.Snapshot(); s.Value != nil {
+		t.Errorf("Wrong value, got %v expected nil", s.Value)
+	}
+
+	r.SetGaugeMaxAge(time.Second)
+	g.Snapshot()
+	g.Snapshot()
+	if n := atomic.LoadInt64(calls); n != 1 {
+		t.Errorf("Wrong number of updates, got %d expected 1", n)
+	}
+
+	c.Add(time.Second)
+	if s := g.Snapshot(); s.Value != testGaugeValue(2) {
+		t.Errorf("Wrong value, got %v expected %v", s.Value, testGaugeValue(2))
+	}
+
+	// gauges registered later have the same maximum age
+	v := r.NewGaugeVec(testRegistryType{}, "vec")
+	m := v.WithLabels(Labels{"a": "b"})
+	testCountingGauge(m)
+	if s := m.Snapshot(); s.Value != testGaugeValue(1) {
+		t.Errorf("Wrong value, got %v expected %v", s.Value, testGaugeValue(1))
+	}
+}
+
+func TestGaugeTimeout(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	r.SetGaugeTimeout(10 * time.Millisecond)
+
+	release := make(chan bool)
+	slow := r.NewGauge(testRegistryType{}, "slow")
+	slow.SetFunction(func() Gaugable {
+		<-release
+		return testGaugeValue(1)
+	})
+	fast := r.NewGauge(testRegistryType{}, "fast")
+	calls := testCountingGauge(fast)
+
+	begin := time.Now()
+	r.UpdateGauges()
+	if d := time.Since(begin); d > time.Second {
+		t.Errorf("UpdateGauges took %v, expected about 10ms", d)
+	}
+	if n := atomic.LoadInt64(calls); n != 1 {
+		t.Errorf("Wrong number of updates, got %d expected 1", n)
+	}
+	if s := slow.Snapshot(); s.Value != nil {
+		t.Errorf("Wrong value, got %v expected nil", s.Value)
+	}
+
+	// the slow function is not called again while it is running
+	slow.Update()
+	close(release)
+	slow.SetTimeout(0)
+	slow.Update()
+	if s := slow.Snapshot(); s.Value != testGaugeValue(1) {
+		t.Errorf("Wrong value, got %v expected %v", s.Value, testGaugeValue(1))
+	}
+}
+
+func TestStartGaugeUpdates(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	g := r.NewGauge(testRegistryType{}, "gauge")
+	calls := testCountingGauge(g)
+
+	r.StartGaugeUpdates(time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(calls) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	r.StopGaugeUpdates()
+
+	n := atomic.LoadInt64(calls)
+	if n < 3 {
+		t.Errorf("Wrong number of updates, got %d expected at least 3", n)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if m := atomic.LoadInt64(calls); m > n+1 {
+		t.Errorf("Gauge was updated %d times after stopping", m-n)
+	}
+}
@@ -0,0 +1,540 @@
+package metrics
+
+import (
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryLevel is a resolution at which a Registry's history is kept:
+// the values of each metric are summarized over every Step, and the
+// summaries are kept for Retention.
+type HistoryLevel struct {
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// DefaultHistoryLevels keep a second's resolution for an hour, a
+// minute's for a day and ten minutes' for 30 days. That is 9360 steps,
+// which take about 300KB for each field of a metric once they are all
+// recorded: a Counter or gauge has one field, and a Timer has 23, or
+// 7MB.
+var DefaultHistoryLevels = []HistoryLevel{
+	{time.Second, time.Hour},
+	{time.Minute, 24 * time.Hour},
+	{10 * time.Minute, 30 * 24 * time.Hour},
+}
+
+// HistoryPoint summarizes the values recorded for a field of a metric
+// during the step beginning at Time.
+type HistoryPoint struct {
+	Time time.Time
+	Min  float64
+	Max  float64
+	Avg  float64
+}
+
+// history keeps, for each metric whose full name matches one of the
+// patterns, the values of each of its fields at every level.
+type history struct {
+	levels   []HistoryLevel
+	patterns []string
+	series   map[string]map[string][]*historyRing
+	stop     chan bool
+	done     chan bool
+	lock     sync.Mutex
+}
+
+func newHistory(levels []HistoryLevel) *history {
+	return &history{
+		levels: levels,
+		series: make(map[string]map[string][]*historyRing),
+	}
+}
+
+// validHistoryLevels returns whether each level's Step is a multiple
+// of the previous level's, and is no longer than its Retention.
+func validHistoryLevels(levels []HistoryLevel) bool {
+	if len(levels) == 0 {
+		return false
+	}
+	for i, l := range levels {
+		if l.Step <= 0 || l.Retention < l.Step {
+			return false
+		}
+		if i > 0 && l.Step%levels[i-1].Step != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// historyBucket summarizes the values recorded during a step.
+type historyBucket struct {
+	min   float64
+	max   float64
+	sum   float64
+	count int64
+}
+
+func (b *historyBucket) add(v float64) {
+	if b.count == 0 || v < b.min {
+		b.min = v
+	}
+	if b.count == 0 || v > b.max {
+		b.max = v
+	}
+	b.sum += v
+	b.count++
+}
+
+// historyRing is a ring buffer of the buckets of the most recent steps
+// of a level. Buckets are numbered by the number of steps since the
+// Unix epoch, and the ring only grows to its full size as steps pass.
+type historyRing struct {
+	step    int64
+	size    int
+	buckets []historyBucket
+	// head is the position in buckets of the oldest bucket, first
+	head  int
+	first int64
+}
+
+func newHistoryRing(l HistoryLevel) *historyRing {
+	return &historyRing{
+		step: int64(l.Step),
+		size: int(l.Retention / l.Step),
+	}
+}
+
+// floorDiv divides a by b, rounding toward negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func (r *historyRing) last() int64 {
+	return r.first + int64(len(r.buckets)) - 1
+}
+
+func (r *historyRing) bucket(i int64) *historyBucket {
+	return &r.buckets[(r.head+int(i-r.first))%len(r.buckets)]
+}
+
+// add adds a value recorded at t, in nanoseconds since the Unix epoch.
+// Values older than the oldest bucket are dropped.
+func (r *historyRing) add(t int64, v float64) {
+	i := floorDiv(t, r.step)
+	if len(r.buckets) == 0 || i-r.last() >= int64(r.size) {
+		// every bucket has expired
+		r.buckets = append(r.buckets[:0], historyBucket{})
+		r.head, r.first = 0, i
+	}
+	if i < r.first {
+		return
+	}
+	for r.last() < i {
+		if len(r.buckets) < r.size {
+			r.buckets = append(r.buckets, historyBucket{})
+		} else {
+			r.buckets[r.head] = historyBucket{}
+			r.head = (r.head + 1) % r.size
+			r.first++
+		}
+	}
+	r.bucket(i).add(v)
+}
+
+// points summarizes the buckets beginning from from, inclusive, to to,
+// exclusive, over steps of step, which is rounded up to a multiple of
+// the ring's step. Steps in which no value was recorded are skipped.
+func (r *historyRing) points(from, to, step int64) []HistoryPoint {
+	if step < r.step {
+		step = r.step
+	}
+	step = (step + r.step - 1) / r.step * r.step
+
+	begin, end := floorDiv(from+r.step-1, r.step), floorDiv(to-1, r.step)
+	if len(r.buckets) == 0 || begin > r.last() || end < r.first {
+		return []HistoryPoint{}
+	}
+	if begin < r.first {
+		begin = r.first
+	}
+	if end > r.last() {
+		end = r.last()
+	}
+
+	points := []HistoryPoint{}
+	var sum historyBucket
+	group := floorDiv(begin*r.step, step)
+	flush := func() {
+		if sum.count != 0 {
+			points = append(points, HistoryPoint{
+				Time: time.Unix(0, group*step),
+				Min:  sum.min,
+				Max:  sum.max,
+				Avg:  sum.sum / float64(sum.count),
+			})
+		}
+		sum = historyBucket{}
+	}
+	for i := begin; i <= end; i++ {
+		if g := floorDiv(i*r.step, step); g != group {
+			flush()
+			group = g
+		}
+		b := r.bucket(i)
+		if b.count == 0 {
+			continue
+		}
+		if sum.count == 0 || b.min < sum.min {
+			sum.min = b.min
+		}
+		if sum.count == 0 || b.max > sum.max {
+			sum.max = b.max
+		}
+		sum.sum += b.sum
+		sum.count += b.count
+	}
+	flush()
+	return points
+}
+
+// enabled returns whether the history of the metric with the given
+// full name is kept. The caller must hold the lock.
+func (h *history) enabled(name string) bool {
+	for _, pattern := range h.patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds the values of the fields of each metric, skipping NaNs
+// and infinities, and forgets the metrics which are no longer
+// registered.
+func (h *history) record(now time.Time, values map[string]map[string]float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	t := now.UnixNano()
+	for name, fields := range values {
+		series, ok := h.series[name]
+		if !ok {
+			series = make(map[string][]*historyRing)
+			h.series[name] = series
+		}
+		for field, v := range fields {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			rings, ok := series[field]
+			if !ok {
+				rings = make([]*historyRing, len(h.levels))
+				for i, l := range h.levels {
+					rings[i] = newHistoryRing(l)
+				}
+				series[field] = rings
+			}
+			for _, r := range rings {
+				r.add(t, v)
+			}
+		}
+	}
+	for name := range h.series {
+		if _, ok := values[name]; !ok {
+			delete(h.series, name)
+		}
+	}
+}
+
+// level returns the level from which values since from are read: the
+// coarsest level keeping them whose Step is at most step, or the finest
+// keeping them if all are coarser. If none keeps them, it is the
+// coarsest level.
+func (h *history) level(now, from time.Time, step time.Duration) int {
+	level := -1
+	for i, l := range h.levels {
+		if now.Sub(from) > l.Retention {
+			continue
+		}
+		if level == -1 || l.Step <= step {
+			level = i
+		}
+	}
+	if level == -1 {
+		level = len(h.levels) - 1
+	}
+	return level
+}
+
+func (h *history) query(name string, now, from, to time.Time,
+	step time.Duration) map[string][]HistoryPoint {
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	series, ok := h.series[name]
+	if !ok {
+		return nil
+	}
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.Add(-h.levels[0].Retention)
+	}
+
+	level := h.level(now, from, step)
+	points := make(map[string][]HistoryPoint, len(series))
+	for field, rings := range series {
+		points[field] = rings[level].points(from.UnixNano(), to.UnixNano(),
+			int64(step))
+	}
+	return points
+}
+
+// quantileField names the field of a percentile, e.g. Percentiles.0.5.
+func quantileField(q float64) string {
+	return "Percentiles." + strconv.FormatFloat(q, 'g', -1, 64)
+}
+
+func meterFields(s MeterSnapshot) map[string]float64 {
+	fields := map[string]float64{"Value": float64(s.Value)}
+	for i, d := range s.Derivatives {
+		for j, v := range d {
+			fields["Derivatives."+strconv.Itoa(i)+"."+strconv.Itoa(j)] = v
+		}
+	}
+	return fields
+}
+
+func distributionFields(s DistributionSnapshot) map[string]float64 {
+	fields := map[string]float64{
+		"Count":             float64(s.Count),
+		"Mean":              s.Mean,
+		"Variance":          s.Variance,
+		"StandardDeviation": s.StandardDeviation,
+		"Skewness":          s.Skewness,
+		"Kurtosis":          s.Kurtosis,
+	}
+	for _, p := range s.Percentiles {
+		fields[quantileField(p.Quantile)] = p.Value
+	}
+	return fields
+}
+
+// historyFields returns the numeric values in a metric's snapshot,
+// named by their paths in it, as described by Registry.History. It
+// returns nil for gauges that are not numeric.
+func historyFields(me Metric) map[string]float64 {
+	switch m := me.(type) {
+	case *Counter:
+		return map[string]float64{"Value": float64(m.Snapshot().Value)}
+
+	case *Gauge:
+		s := m.Snapshot()
+		if s.Value == nil {
+			return nil
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(s.Value.String()), 64)
+		if err != nil {
+			return nil
+		}
+		return map[string]float64{"Value": v}
+
+	case *IntGauge:
+		return map[string]float64{"Value": float64(m.Value())}
+
+	case *FloatGauge:
+		return map[string]float64{"Value": m.Value()}
+
+	case *Meter:
+		return meterFields(m.Snapshot())
+
+	case *Distribution:
+		return distributionFields(m.Snapshot())
+
+	case *Digest:
+		s := m.Snapshot()
+		fields := map[string]float64{"Count": s.Count, "Mean": s.Mean}
+		for i, q := range s.Quantiles {
+			fields[quantileField(q)] = s.Percentiles[i]
+		}
+		return fields
+
+	case *Timer:
+		s := m.Snapshot()
+		fields := make(map[string]float64)
+		for field, v := range meterFields(s.Meter) {
+			fields["Meter."+field] = v
+		}
+		for field, v := range distributionFields(s.Distribution) {
+			fields["Distribution."+field] = v
+		}
+		return fields
+	}
+	return nil
+}
+
+// SetHistoryLevels sets the levels at which the receiver's history is
+// kept, from the finest to the coarsest, discarding the history
+// recorded so far. Each level's Step must be a multiple of the previous
+// level's Step, and no longer than its Retention; otherwise the levels
+// are not set, and it returns false. The default levels are
+// DefaultHistoryLevels. Sub-registries share their parent's history.
+func (r *Registry) SetHistoryLevels(levels ...HistoryLevel) bool {
+	if !validHistoryLevels(levels) {
+		return false
+	}
+
+	h := r.history
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.levels = append([]HistoryLevel(nil), levels...)
+	h.series = make(map[string]map[string][]*historyRing)
+	return true
+}
+
+// EnableHistory keeps the history of the receiver's metrics whose
+// names match any of the patterns, in the syntax of path.Match, in
+// addition to those enabled before. In a sub-registry, the patterns
+// match the names within it. As in path.Match, * does not match the /
+// after the prefix of a sub-registry, so */* is needed to match its
+// metrics from its parent. It returns false, and enables none of
+// them, if a pattern is malformed.
+//
+// No history is kept by default, since each metric's takes memory for
+// every step of every level: see DefaultHistoryLevels.
+func (r *Registry) EnableHistory(patterns ...string) bool {
+	prefixed := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false
+		}
+		prefixed[i] = escapeMatch(r.prefix) + pattern
+	}
+
+	h := r.history
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.patterns = append(h.patterns, prefixed...)
+	return true
+}
+
+// escapeMatch escapes the characters of s which are special in the
+// patterns of path.Match.
+func escapeMatch(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// RecordHistory adds the current values of the receiver's metrics whose
+// history is enabled by EnableHistory, including those of its parent
+// and sub-registries, to its history. Each value is added to the step
+// of each level which it falls in, whose minimum, maximum and average
+// it updates. NaNs and infinities are skipped.
+func (r *Registry) RecordHistory() {
+	h := r.history
+	h.lock.Lock()
+	r.lock.RLock()
+	all := make(map[string]Metric)
+	for name, m := range r.metrics {
+		if h.enabled(name) {
+			all[name] = m
+		}
+	}
+	r.lock.RUnlock()
+	h.lock.Unlock()
+
+	values := make(map[string]map[string]float64, len(all))
+	for name, m := range all {
+		if fields := historyFields(m); fields != nil {
+			values[name] = fields
+		}
+	}
+	r.history.record(r.clock.Now(), values)
+}
+
+// StartHistory starts recording the receiver's history in the
+// background, at the Step of its finest level, replacing any previous
+// schedule.
+func (r *Registry) StartHistory() {
+	r.StopHistory()
+
+	h := r.history
+	h.lock.Lock()
+	interval := h.levels[0].Step
+	stop, done := make(chan bool), make(chan bool)
+	h.stop, h.done = stop, done
+	h.lock.Unlock()
+
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		r.RecordHistory()
+		for {
+			select {
+			case <-t.C:
+				r.RecordHistory()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopHistory stops the recording started by StartHistory. The history
+// recorded so far is kept.
+func (r *Registry) StopHistory() {
+	h := r.history
+	h.lock.Lock()
+	stop, done := h.stop, h.done
+	h.stop, h.done = nil, nil
+	h.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// History returns the history of the metric registered under fullname,
+// from from, inclusive, to to, exclusive, summarized over steps of
+// step, if its history is enabled by EnableHistory. If to is zero, it
+// is the current time, and if from is zero, it
+// is the Retention of the finest level before to. The values are read
+// from the coarsest level that keeps values since from and whose Step
+// is at most step, and step is rounded up to a multiple of its Step.
+// Steps in which no values were recorded are omitted.
+//
+// The history is returned by field, named by the path of the field in
+// the metric's snapshot: Value for Counters and numeric gauges, Value
+// and Derivatives.i.j for Meters, Count, Mean, Variance,
+// StandardDeviation, Skewness, Kurtosis and each percentile, e.g.
+// Percentiles.0.5, for Distributions, Count, Mean and the percentiles
+// for Digests, and those of its Meter and Distribution, prefixed by
+// Meter. and Distribution., for Timers. It returns nil if no values
+// of the metric have been recorded.
+func (r *Registry) History(fullname string, from, to time.Time,
+	step time.Duration) map[string][]HistoryPoint {
+
+	return r.history.query(r.prefix+fullname, r.clock.Now(), from, to, step)
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"math"
+	"metrics/metricstest"
+	"testing"
+	"time"
+)
+
+func testHistoryPoints(t *testing.T, name string, points, expected []HistoryPoint) {
+	if len(points) != len(expected) {
+		t.Errorf("Wrong %s, got %v expected %v", name, points, expected)
+		return
+	}
+	for i := range points {
+		if !points[i].Time.Equal(expected[i].Time) ||
+			points[i].Min != expected[i].Min ||
+			points[i].Max != expected[i].Max ||
+			points[i].Avg != expected[i].Avg {
+
+			t.Errorf("Wrong %s point %d, got %v expected %v",
+				name, i, points[i], expected[i])
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := metricstest.NewClock(base)
+	r := NewRegistryClock("testRegistry", c)
+	if r.SetHistoryLevels(HistoryLevel{time.Second, time.Minute},
+		HistoryLevel{1500 * time.Millisecond, time.Hour}) {
+
+		t.Errorf("SetHistoryLevels accepted a step which is not a multiple")
+	}
+	if !r.SetHistoryLevels(HistoryLevel{time.Second, 10 * time.Second},
+		HistoryLevel{10 * time.Second, time.Minute}) {
+
+		t.Fatalf("SetHistoryLevels rejected valid levels")
+	}
+	if r.EnableHistory("[") {
+		t.Errorf("EnableHistory accepted a malformed pattern")
+	}
+	r.EnableHistory("*.gauge")
+
+	g := r.NewIntGauge(testRegistryType{}, "gauge")
+	for i := int64(0); i < 20; i++ {
+		g.Set(i)
+		r.RecordHistory()
+		c.Add(time.Second)
+	}
+	c.Add(-time.Second)
+
+	name := "metrics.testRegistryType.gauge"
+	h := r.History(name, base.Add(10*time.Second), time.Time{}, 5*time.Second)
+	testHistoryPoints(t, "recent history", h["Value"], []HistoryPoint{
+		{base.Add(10 * time.Second), 10, 14, 12},
+		{base.Add(15 * time.Second), 15, 18, 16.5},
+	})
+
+	// the finest level no longer keeps the first values
+	h = r.History(name, base, base.Add(20*time.Second), 0)
+	testHistoryPoints(t, "older history", h["Value"], []HistoryPoint{
+		{base, 0, 9, 4.5},
+		{base.Add(10 * time.Second), 10, 19, 14.5},
+	})
+
+	// values older than the coarsest level's retention expire
+	c.Add(2 * time.Minute)
+	g.Set(100)
+	r.RecordHistory()
+	h = r.History(name, base, time.Time{}, 0)
+	testHistoryPoints(t, "expired history", h["Value"], []HistoryPoint{
+		{base.Add(130 * time.Second), 100, 100, 100},
+	})
+
+	r.Unregister(testRegistryType{}, "gauge")
+	r.RecordHistory()
+	if h := r.History(name, base, time.Time{}, 0); h != nil {
+		t.Errorf("Unregistered metric has a history: %v", h)
+	}
+}
+
+func TestHistoryFields(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := metricstest.NewClock(base)
+	r := NewRegistryClock("testRegistry", c)
+	sub := r.Sub("sub")
+	sub.EnableHistory("*")
+	r.EnableHistory("*.text")
+
+	d := sub.NewDistribution(testRegistryType{}, "distribution")
+	d.Add(3)
+	d.Add(5)
+	sub.NewTimer(testRegistryType{}, "timer")
+	text := r.NewGauge(testRegistryType{}, "text")
+	text.SetFunction(func() Gaugable { return &testGaugable{1, true} })
+	text.Update()
+	r.RecordHistory()
+
+	h := sub.History("metrics.testRegistryType.distribution",
+		time.Time{}, base.Add(time.Second), 0)
+	if h == nil {
+		t.Fatalf("Distribution has no history")
+	}
+	testHistoryPoints(t, "mean", h["Mean"], []HistoryPoint{{base, 4, 4, 4}})
+	testHistoryPoints(t, "maximum", h["Percentiles.1"],
+		[]HistoryPoint{{base, 5, 5, 5}})
+
+	h = r.History("sub/metrics.testRegistryType.timer",
+		time.Time{}, base.Add(time.Second), 0)
+	for _, field := range []string{"Meter.Derivatives.1.1",
+		"Distribution.Percentiles.0.99"} {
+
+		if _, ok := h[field]; !ok {
+			t.Errorf("Timer history has no %s", field)
+		}
+	}
+
+	if h := r.History("metrics.testRegistryType.text",
+		time.Time{}, time.Time{}, 0); h != nil {
+
+		t.Errorf("Non-numeric gauge has a history: %v", h)
+	}
+}
+
+func TestHistoryEnabled(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := metricstest.NewClock(base)
+	r := NewRegistryClock("testRegistry", c)
+	r.EnableHistory("*.float")
+
+	f := r.NewFloatGauge(testRegistryType{}, "float")
+	r.NewIntGauge(testRegistryType{}, "int").Set(1)
+	for _, v := range []float64{1, math.NaN(), math.Inf(1), 3} {
+		f.Set(v)
+		r.RecordHistory()
+	}
+
+	h := r.History("metrics.testRegistryType.float",
+		time.Time{}, base.Add(time.Second), 0)
+	testHistoryPoints(t, "finite history", h["Value"],
+		[]HistoryPoint{{base, 1, 3, 2}})
+
+	if h := r.History("metrics.testRegistryType.int",
+		time.Time{}, time.Time{}, 0); h != nil {
+
+		t.Errorf("Metric whose history is not enabled has one: %v", h)
+	}
+}
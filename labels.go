@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Labels is a set of label names and values that distinguishes
+// the members of a labelled metric family.
+type Labels map[string]string
+
+var labelNameRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+var labelValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+)
+
+// Valid reports whether all label names are nonempty identifiers.
+func (l Labels) Valid() bool {
+	for name := range l {
+		if !labelNameRegexp.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the sorted label names.
+func (l Labels) Names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the canonical form of the label set, with names
+// sorted and values quoted, e.g. {code="200",method="GET"}.
+// This is appended to a family's name to form the name under which
+// each member is registered. An empty label set is "{}".
+func (l Labels) String() string {
+	pairs := make([]string, len(l))
+	for i, name := range l.Names() {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name,
+			labelValueEscaper.Replace(l[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (l Labels) copy() Labels {
+	c := make(Labels, len(l))
+	for name, value := range l {
+		c[name] = value
+	}
+	return c
+}
+
+// A LabelMatcher selects members of a labelled metric family
+// by their label set.
+type LabelMatcher func(Labels) bool
+
+// LabelEquals matches label sets where the named label is value.
+// A missing label is treated as an empty value.
+func LabelEquals(name, value string) LabelMatcher {
+	return func(l Labels) bool {
+		return l[name] == value
+	}
+}
+
+// LabelNotEquals matches label sets where the named label is not value.
+func LabelNotEquals(name, value string) LabelMatcher {
+	return func(l Labels) bool {
+		return l[name] != value
+	}
+}
+
+// LabelMatches matches label sets where the named label matches re
+// in its entirety.
+func LabelMatches(name string, re *regexp.Regexp) LabelMatcher {
+	return func(l Labels) bool {
+		loc := re.FindStringIndex(l[name])
+		return loc != nil && loc[0] == 0 && loc[1] == len(l[name])
+	}
+}
+
+// LabelNotMatches matches label sets where the named label does not
+// match re in its entirety.
+func LabelNotMatches(name string, re *regexp.Regexp) LabelMatcher {
+	m := LabelMatches(name, re)
+	return func(l Labels) bool {
+		return !m(l)
+	}
+}
+
+// ParseLabelMatcher parses a matcher in the form name=value,
+// name!=value, name=~regexp or name!~regexp.
+func ParseLabelMatcher(s string) (LabelMatcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return nil, fmt.Errorf("metrics: invalid label matcher %q", s)
+	}
+	name, op := s[:i], s[i:]
+
+	var value string
+	for _, prefix := range []string{"=~", "!~", "!=", "="} {
+		if strings.HasPrefix(op, prefix) {
+			value = op[len(prefix):]
+			op = prefix
+			break
+		}
+	}
+
+	switch op {
+	case "=":
+		return LabelEquals(name, value), nil
+	case "!=":
+		return LabelNotEquals(name, value), nil
+	case "=~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "=~" {
+			return LabelMatches(name, re), nil
+		}
+		return LabelNotMatches(name, re), nil
+	}
+	return nil, fmt.Errorf("metrics: invalid label matcher %q", s)
+}
+
+func matchLabels(l Labels, matchers []LabelMatcher) bool {
+	for _, m := range matchers {
+		if !m(l) {
+			return false
+		}
+	}
+	return true
+}
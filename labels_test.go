@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLabelsString(t *testing.T) {
+	l := Labels{"method": "GET", "code": "2\"00"}
+	expected := `{code="2\"00",method="GET"}`
+	if l.String() != expected {
+		t.Errorf("Wrong label string, got %s expected %s", l.String(), expected)
+	}
+	if (Labels{}).String() != "{}" {
+		t.Errorf("Wrong empty label string, got %s", Labels{}.String())
+	}
+}
+
+func TestLabelsValid(t *testing.T) {
+	if !(Labels{"_code2": "x"}).Valid() {
+		t.Errorf("Label name _code2 should be valid")
+	}
+	if (Labels{"2code": "x"}).Valid() {
+		t.Errorf("Label name 2code should be invalid")
+	}
+	if (Labels{"": "x"}).Valid() {
+		t.Errorf("Empty label name should be invalid")
+	}
+}
+
+func TestLabelMatchers(t *testing.T) {
+	l := Labels{"code": "200", "method": "GET"}
+	tests := []struct {
+		m        LabelMatcher
+		expected bool
+	}{
+		{LabelEquals("code", "200"), true},
+		{LabelEquals("code", "500"), false},
+		{LabelNotEquals("code", "500"), true},
+		{LabelEquals("missing", ""), true},
+		{LabelMatches("code", regexp.MustCompile("2..")), true},
+		{LabelMatches("code", regexp.MustCompile("2")), false},
+		{LabelNotMatches("method", regexp.MustCompile("POST|PUT")), true},
+	}
+	for i, test := range tests {
+		if test.m(l) != test.expected {
+			t.Errorf("Matcher %d returned %v, expected %v",
+				i, !test.expected, test.expected)
+		}
+	}
+}
+
+func TestParseLabelMatcher(t *testing.T) {
+	l := Labels{"code": "200"}
+	tests := []struct {
+		s        string
+		expected bool
+	}{
+		{"code=200", true},
+		{"code!=200", false},
+		{"code=~2.*", true},
+		{"code!~2.*", false},
+		{"code=", false},
+	}
+	for _, test := range tests {
+		m, err := ParseLabelMatcher(test.s)
+		if err != nil {
+			t.Errorf("Could not parse matcher %s: %v", test.s, err)
+			continue
+		}
+		if m(l) != test.expected {
+			t.Errorf("Matcher %s returned %v, expected %v",
+				test.s, !test.expected, test.expected)
+		}
+	}
+
+	for _, s := range []string{"", "=200", "code", "code!200", "code=~("} {
+		if _, err := ParseLabelMatcher(s); err == nil {
+			t.Errorf("Matcher %q should not parse", s)
+		}
+	}
+}
+
+func TestRegistryCounterVec(t *testing.T) {
+	r := testRegistryInitialize()
+	v := r.NewCounterVec(testRegistryType{}, "requests")
+	if v == nil {
+		t.Fatalf("Could not create CounterVec")
+	}
+	if r.NewCounter(testRegistryType{}, "requests") != nil {
+		t.Errorf("Counter with the same name as a family was created")
+	}
+
+	v.WithLabels(Labels{"code": "200"}).Inc(3)
+	v.WithLabels(Labels{"code": "200"}).Inc(4)
+	v.WithLabels(Labels{"code": "500"}).Inc(1)
+	if v.WithLabels(Labels{"bad-name": "x"}) != nil {
+		t.Errorf("WithLabels should reject invalid label names")
+	}
+
+	name := `metrics.testRegistryType.requests{code="200"}`
+	c, ok := r.FindS(name).(*Counter)
+	if !ok {
+		t.Fatalf("Registry did not contain %s", name)
+	}
+	if c.Snapshot().Value != 7 {
+		t.Errorf("Counter %s is %d, expected %d", name, c.Snapshot().Value, 7)
+	}
+	if r.ListMetrics()[name] == nil {
+		t.Errorf("ListMetrics did not include %s", name)
+	}
+
+	family, labels := r.LabelSet(name)
+	if family != "metrics.testRegistryType.requests" || labels["code"] != "200" {
+		t.Errorf("Wrong label set for %s, got %s %v", name, family, labels)
+	}
+
+	found := r.FindLabeled(testRegistryType{}, "requests",
+		LabelNotEquals("code", "200"))
+	if len(found) != 1 || found[`metrics.testRegistryType.requests{code="500"}`] == nil {
+		t.Errorf("Wrong metrics found by label, got %v", found)
+	}
+	if len(v.Find()) != 2 {
+		t.Errorf("Family has %d members, expected %d", len(v.Find()), 2)
+	}
+
+	v.Reset()
+	if c.Snapshot().Value != 0 {
+		t.Errorf("Counter %s is %d after family reset", name, c.Snapshot().Value)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Metadata describes a metric or labelled family. All of its fields
+// are optional.
+type Metadata struct {
+	// Description says what the metric measures.
+	Description string `json:",omitempty"`
+	// Unit is the unit of the metric's values, e.g. ms, bytes or
+	// requests.
+	Unit string `json:",omitempty"`
+	// Range is the expected range of the metric's values, which the
+	// dashboard uses to size graphs. It is unset if both ends are 0.
+	Range [2]float64
+}
+
+// IsZero returns whether no field of the Metadata is set.
+func (md Metadata) IsZero() bool {
+	return md == Metadata{}
+}
+
+// MetricInfo is a registered metric's name, type and metadata, as
+// listed by ListInfo.
+type MetricInfo struct {
+	Name string
+	Type string
+	Metadata
+}
+
+// SetMetadata sets the metadata of the metric or labelled family
+// registered under a type and name. It may be set before the metric is
+// registered, and is removed when the metric is unregistered. Members
+// of a labelled family share the family's metadata.
+func (r *Registry) SetMetadata(tyep interface{}, name string, md Metadata) {
+	r.SetMetadataS(fmt.Sprintf("%s.%s", realType(tyep), name), md)
+}
+
+func (r *Registry) SetMetadataS(fullname string, md Metadata) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if md.IsZero() {
+		delete(r.meta, r.prefix+fullname)
+		return
+	}
+	r.meta[r.prefix+fullname] = md
+}
+
+// Metadata returns the metadata of the metric registered under
+// fullname, or of its family. If a Distribution or Timer has no Range,
+// its range hint is returned instead.
+func (r *Registry) Metadata(fullname string) Metadata {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.metadataLocked(r.prefix + fullname)
+}
+
+// metadataLocked is Metadata for a name including the receiver's
+// prefix. The caller must hold the lock.
+func (r *Registry) metadataLocked(fullname string) Metadata {
+	md, ok := r.meta[fullname]
+	if ln, labeled := r.labeled[fullname]; !ok && labeled {
+		md = r.meta[ln.family]
+	}
+	if md.Range == [2]float64{} {
+		switch m := r.metrics[fullname].(type) {
+		case *Distribution:
+			md.Range = m.rangeHintValue()
+		case *Timer:
+			md.Range = m.Distribution().rangeHintValue()
+		}
+	}
+	return md
+}
+
+// ListInfo returns the names, types and metadata of the registered
+// metrics, sorted by name.
+func (r *Registry) ListInfo() []MetricInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	list := make([]MetricInfo, 0, len(r.metrics))
+	for fullname, metric := range r.metrics {
+		if name, ok := r.localName(fullname); ok {
+			list = append(list, MetricInfo{
+				Name:     name,
+				Type:     metricType(metric),
+				Metadata: r.metadataLocked(fullname),
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
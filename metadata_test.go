@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	md := Metadata{Description: "Request latency", Unit: "ms",
+		Range: [2]float64{0, 100}}
+
+	// metadata may be set before registration
+	r.SetMetadata(testRegistryType{}, "latency", md)
+	r.NewDistribution(testRegistryType{}, "latency")
+	if got := r.Metadata("metrics.testRegistryType.latency"); got != md {
+		t.Errorf("Wrong metadata, got %+v expected %+v", got, md)
+	}
+
+	r.Unregister(testRegistryType{}, "latency")
+	if got := r.Metadata("metrics.testRegistryType.latency"); !got.IsZero() {
+		t.Errorf("Unregistered metric has metadata %+v", got)
+	}
+}
+
+func TestMetadataFamily(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	v := r.NewCounterVec(testRegistryType{}, "requests")
+	md := Metadata{Description: "Requests served", Unit: "requests"}
+	r.SetMetadata(testRegistryType{}, "requests", md)
+	v.WithLabels(Labels{"code": "200"})
+
+	name := `metrics.testRegistryType.requests{code="200"}`
+	if got := r.Metadata(name); got != md {
+		t.Errorf("Wrong member metadata, got %+v expected %+v", got, md)
+	}
+
+	s := r.Sub("a")
+	s.SetMetadata(testRegistryType{}, "x", Metadata{Unit: "bytes"})
+	s.NewIntGauge(testRegistryType{}, "x")
+	if u := r.Metadata("a/metrics.testRegistryType.x").Unit; u != "bytes" {
+		t.Errorf("Wrong sub-registry unit, got %q expected bytes", u)
+	}
+}
+
+func TestMetadataRangeHint(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	d := r.NewDistribution(testRegistryType{}, "d")
+	d.SetRangeHint(1, 2)
+
+	expected := [2]float64{1, 2}
+	if got := r.Metadata("metrics.testRegistryType.d").Range; got != expected {
+		t.Errorf("Wrong range, got %v expected %v", got, expected)
+	}
+
+	r.SetMetadata(testRegistryType{}, "d", Metadata{Range: [2]float64{3, 4}})
+	expected = [2]float64{3, 4}
+	if got := r.Metadata("metrics.testRegistryType.d").Range; got != expected {
+		t.Errorf("Wrong range, got %v expected %v", got, expected)
+	}
+}
+
+func TestListInfo(t *testing.T) {
+	r := testRegistryInitialize()
+	r.SetMetadata(testTime, "external_type", Metadata{Description: "x"})
+
+	info := r.ListInfo()
+	expected := []MetricInfo{
+		{"metrics.testRegistryType.internal_type", "counter", Metadata{}},
+		{"metrics.testRegistryType.ptr_to_internal_type", "distribution",
+			Metadata{}},
+		{"time.Time.external_type", "gauge", Metadata{Description: "x"}},
+		{"time.Time.ptr_to_external_type", "meter", Metadata{}},
+	}
+	if len(info) != len(expected) {
+		t.Fatalf("Wrong list, got %v expected %v", info, expected)
+	}
+	for i := range info {
+		if info[i] != expected[i] {
+			t.Errorf("Wrong info %d, got %+v expected %+v",
+				i, info[i], expected[i])
+		}
+	}
+}
@@ -21,8 +21,9 @@ var defaultMeterTimeConstants = []time.Duration{
 // change, as well as 1-min, 5-min and 15-min exponentially weighted
 // averages of the value and the rate of change.
 type Meter struct {
-	r    *statistics.Rate
-	lock sync.RWMutex
+	r     *statistics.Rate
+	clock Clock
+	lock  sync.RWMutex
 }
 
 type MeterSnapshot struct {
@@ -32,10 +33,11 @@ type MeterSnapshot struct {
 	Derivatives   [][]float64
 }
 
-func newMeter() *Meter {
+func newMeter(clock Clock) *Meter {
 	return &Meter{
 		r: statistics.NewRate(defaultMeterDerivatives,
 			defaultMeterTimeConstants),
+		clock: clock,
 	}
 }
 
@@ -47,7 +49,7 @@ func (m *Meter) Reset() {
 }
 
 func (m *Meter) Inc(v int64) {
-	m.inc(v, time.Now())
+	m.inc(v, m.clock.Now())
 }
 
 func (m *Meter) inc(v int64, now time.Time) {
@@ -61,7 +63,7 @@ func (m *Meter) Dec(v int64) {
 }
 
 func (m *Meter) Set(v int64) {
-	m.set(v, time.Now())
+	m.set(v, m.clock.Now())
 }
 
 func (m *Meter) set(v int64, now time.Time) {
@@ -81,9 +83,10 @@ func (m *Meter) Snapshot() MeterSnapshot {
 	defer m.lock.RUnlock()
 
 	r := MeterSnapshot{
-		Value:       m.r.Value(),
-		LastUpdated: m.r.LastUpdated(),
-		Derivatives: m.r.Derivatives(),
+		Value:         m.r.Value(),
+		LastUpdated:   m.r.LastUpdated(),
+		TimeConstants: m.r.TimeConstants(),
+		Derivatives:   m.r.Derivatives(),
 	}
 
 	return r
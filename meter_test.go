@@ -6,7 +6,7 @@ import (
 )
 
 func testMeterInit() *Meter {
-	m := newMeter()
+	m := newMeter(SystemClock)
 	m.set(1357, testTime)
 	return m
 }
@@ -25,8 +25,17 @@ func TestMeterInc(t *testing.T) {
 	}
 }
 
+func TestMeterIncSameTime(t *testing.T) {
+	m := testMeterInit()
+	m.inc(1, testTime)
+	m.inc(1, testTime.Add(-time.Second))
+	if s := m.Snapshot(); s.Value != 1359 {
+		t.Errorf("Meter incremented to %d, expected %d", s.Value, 1359)
+	}
+}
+
 func BenchmarkMeterUpdate(b *testing.B) {
-	m := newMeter()
+	m := newMeter(SystemClock)
 	for i := 0; i < b.N; i++ {
 		m.inc(int64(i), testTime.Add(time.Duration(i)))
 	}
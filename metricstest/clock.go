@@ -0,0 +1,41 @@
+// Metricstest provides utilities for testing code instrumented
+// with metrics.
+package metricstest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a metrics.Clock whose time only changes when it is
+// explicitly set or advanced. It is safe for concurrent use.
+type Clock struct {
+	now  time.Time
+	lock sync.RWMutex
+}
+
+// NewClock returns a Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the Clock's current time.
+func (c *Clock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.now
+}
+
+// Add advances the Clock by d.
+func (c *Clock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the Clock's current time.
+func (c *Clock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
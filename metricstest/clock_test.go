@@ -0,0 +1,25 @@
+package metricstest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	base := time.Date(1970, 1, 1, 1, 1, 1, 1, time.UTC)
+	c := NewClock(base)
+	if !c.Now().Equal(base) {
+		t.Errorf("Clock is %v, expected %v", c.Now(), base)
+	}
+
+	c.Add(time.Minute)
+	if !c.Now().Equal(base.Add(time.Minute)) {
+		t.Errorf("Clock is %v after Add, expected %v",
+			c.Now(), base.Add(time.Minute))
+	}
+
+	c.Set(base)
+	if !c.Now().Equal(base) {
+		t.Errorf("Clock is %v after Set, expected %v", c.Now(), base)
+	}
+}
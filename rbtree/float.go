@@ -0,0 +1,32 @@
+package rbtree
+
+import (
+	"math"
+)
+
+const signBit = 1 << 63
+
+// FloatKey returns a key representing a float64, so that a Tree can be
+// keyed on float64 values. Keys are ordered as the values they
+// represent, with -0 before 0, and NaNs at either end depending on
+// their sign bit.
+func FloatKey(f float64) int64 {
+	b := math.Float64bits(f)
+	if b&signBit != 0 {
+		b = ^b
+	} else {
+		b |= signBit
+	}
+	return int64(b ^ signBit)
+}
+
+// KeyFloat returns the float64 represented by a key from FloatKey.
+func KeyFloat(k int64) float64 {
+	b := uint64(k) ^ signBit
+	if b&signBit != 0 {
+		b &^= signBit
+	} else {
+		b = ^b
+	}
+	return math.Float64frombits(b)
+}
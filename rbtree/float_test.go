@@ -0,0 +1,40 @@
+package rbtree
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloatKey(t *testing.T) {
+	values := []float64{math.Inf(-1), -math.MaxFloat64, -1.5, -1,
+		-math.SmallestNonzeroFloat64, math.Copysign(0, -1), 0,
+		math.SmallestNonzeroFloat64, 0.25, 1, 1e300, math.Inf(1)}
+
+	for i, v := range values {
+		k := FloatKey(v)
+		if f := KeyFloat(k); math.Float64bits(f) != math.Float64bits(v) {
+			t.Errorf("Wrong value from key, got %v expected %v", f, v)
+		}
+		if i > 0 && FloatKey(values[i-1]) >= k {
+			t.Errorf("Key of %v is not greater than key of %v",
+				v, values[i-1])
+		}
+	}
+	if KeyFloat(FloatKey(0)) != 0 || FloatKey(0) != 0 {
+		t.Errorf("Wrong key of 0, got %d expected 0", FloatKey(0))
+	}
+}
+
+func TestFloatKeyTree(t *testing.T) {
+	tree := New()
+	for _, v := range []float64{0.5, -2.25, 3, -0.125, 1e-9} {
+		tree.Insert(FloatKey(v), nil)
+	}
+
+	expected := []float64{-2.25, -0.125, 1e-9, 0.5, 3}
+	for i, v := range expected {
+		if f := KeyFloat(tree.FindByRank(uint64(i)).Key()); f != v {
+			t.Errorf("Wrong value of rank %d, got %v expected %v", i, f, v)
+		}
+	}
+}
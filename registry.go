@@ -3,21 +3,114 @@ package metrics
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 )
 
-// Registry stores metrics and their unique names.
+// Registry stores metrics and their unique names. A Registry returned
+// by Sub shares the metrics of its parent, and sees only those whose
+// names begin with its prefix.
 type Registry struct {
+	*registryMetrics
+	prefix string
+	gauges gaugeSettings
+	saving stateSaving
+}
+
+// registryMetrics is the storage shared by a Registry and its
+// sub-registries. Its maps are keyed by names including any prefix.
+type registryMetrics struct {
 	name    string
 	metrics map[string]Metric
-	lock    sync.RWMutex
+	vecs    map[string]*metricVec
+	labeled map[string]labeledName
+	meta    map[string]Metadata
+	clock   Clock
+	history *history
+
+	watchers  map[uint64]func(RegistryEvent)
+	watcherID uint64
+
+	lock sync.RWMutex
+}
+
+// labeledName records the family and labels of a member
+// of a labelled metric family.
+type labeledName struct {
+	family string
+	labels Labels
 }
 
 func NewRegistry(name string) *Registry {
+	return NewRegistryClock(name, SystemClock)
+}
+
+// NewRegistryClock creates a Registry whose time-based metrics
+// take the current time from clock.
+func NewRegistryClock(name string, clock Clock) *Registry {
+	return &Registry{
+		registryMetrics: &registryMetrics{
+			name:    name,
+			metrics: make(map[string]Metric),
+			vecs:    make(map[string]*metricVec),
+			labeled: make(map[string]labeledName),
+			meta:    make(map[string]Metadata),
+			clock:   clock,
+			history: newHistory(DefaultHistoryLevels),
+		},
+	}
+}
+
+// subSeparator follows the prefix of a sub-registry in the names of its
+// metrics. It cannot appear in the type names which begin the names of
+// metrics, so that a sub-registry's metrics cannot be confused with
+// those of its parent, whatever its prefix.
+const subSeparator = "/"
+
+// Sub returns a registry whose metrics are registered with the
+// receiver, under names beginning with prefix and a slash, such as
+// tenant/pkg.Type.name. Metrics registered with it are visible from
+// the receiver under their full names, and from it without the
+// prefix. Sub-registries with the same prefix share their metrics.
+// Sub panics if prefix is empty or contains a slash.
+func (r *Registry) Sub(prefix string) *Registry {
+	if prefix == "" || strings.Contains(prefix, subSeparator) {
+		panic(fmt.Sprintf("metrics: invalid sub-registry prefix %q", prefix))
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
 	return &Registry{
-		name:    name,
-		metrics: make(map[string]Metric),
+		registryMetrics: r.registryMetrics,
+		prefix:          r.prefix + prefix + subSeparator,
+		gauges: gaugeSettings{
+			maxAge:  r.gauges.maxAge,
+			timeout: r.gauges.timeout,
+		},
+	}
+}
+
+// Prefix returns the prefix of the receiver's metrics' names, including
+// the trailing slash, or "" if the receiver was not returned by Sub.
+func (r *Registry) Prefix() string {
+	return r.prefix
+}
+
+// fullName returns the name, including the receiver's prefix, under
+// which a metric is stored.
+func (r *Registry) fullName(tyep interface{}, name string) string {
+	return fmt.Sprintf("%s%s.%s", r.prefix, realType(tyep), name)
+}
+
+// localName returns a stored name without the receiver's prefix, and
+// whether it had the prefix.
+func (r *Registry) localName(fullName string) (string, bool) {
+	if !strings.HasPrefix(fullName, r.prefix) {
+		return "", false
 	}
+	return fullName[len(r.prefix):], true
 }
 
 func realType(tyep interface{}) string {
@@ -29,21 +122,111 @@ func realType(tyep interface{}) string {
 }
 
 func (r *Registry) register(tyep interface{}, name string, m Metric) bool {
+	_, err := r.add(r.fullName(tyep, name), m, false)
+	return err == nil
+}
+
+// add registers m under fullName. If the name is in use, it returns
+// the metric registered under it if getExisting is set and the metric
+// has the same type as m, and a RegistrationError otherwise.
+func (r *Registry) add(fullName string, m Metric,
+	getExisting bool) (Metric, error) {
+
+	r.lock.Lock()
+	if existing, exists := r.metrics[fullName]; exists {
+		r.lock.Unlock()
+		if getExisting && reflect.TypeOf(existing) == reflect.TypeOf(m) {
+			return existing, nil
+		}
+		return nil, &RegistrationError{fullName, metricType(m),
+			metricType(existing)}
+	}
+	if v, exists := r.vecs[fullName]; exists {
+		r.lock.Unlock()
+		return nil, &RegistrationError{fullName, metricType(m), v.typeName()}
+	}
+	r.metrics[fullName] = m
+	r.gauges.apply(m)
+	watchers := r.watchersLocked()
+	r.lock.Unlock()
+
+	notify(watchers, RegistryEvent{fullName, metricType(m), true})
+	return m, nil
+}
+
+func (r *Registry) registerVec(tyep interface{}, name string,
+	newMetric func() Metric) *metricVec {
+
+	v, _ := r.addVec(r.fullName(tyep, name), newMetric, false)
+	return v
+}
+
+// addVec is add for a labelled family of metrics created by newMetric.
+func (r *Registry) addVec(fullName string, newMetric func() Metric,
+	getExisting bool) (*metricVec, error) {
+
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	fullName := fmt.Sprintf("%s.%s", realType(tyep), name)
+	v := &metricVec{
+		registry:  r,
+		name:      fullName,
+		newMetric: newMetric,
+	}
+	if m, exists := r.metrics[fullName]; exists {
+		return nil, &RegistrationError{fullName, v.typeName(), metricType(m)}
+	}
+	if existing, exists := r.vecs[fullName]; exists {
+		if getExisting && existing.typeName() == v.typeName() {
+			return existing, nil
+		}
+		return nil, &RegistrationError{fullName, v.typeName(),
+			existing.typeName()}
+	}
+	r.vecs[fullName] = v
+	return v, nil
+}
+
+// registerLabeled returns the member of a family with the given
+// labels, registering it if it does not exist. Members of a family
+// which has been unregistered are not registered.
+func (r *Registry) registerLabeled(v *metricVec, l Labels) Metric {
+	r.lock.Lock()
 
-	if _, exists := r.metrics[fullName]; exists {
-		return false
+	fullName := v.name + l.String()
+	if m, exists := r.metrics[fullName]; exists {
+		r.lock.Unlock()
+		return m
 	}
+	m := v.newMetric()
+	if r.vecs[v.name] != v {
+		r.lock.Unlock()
+		return m
+	}
+	r.gauges.apply(m)
 	r.metrics[fullName] = m
-	return true
+	r.labeled[fullName] = labeledName{family: v.name, labels: l.copy()}
+	watchers := r.watchersLocked()
+	r.lock.Unlock()
+
+	notify(watchers, RegistryEvent{fullName, metricType(m), true})
+	return m
 }
 
 // NewCounter creates a counter and registers it with the receiver.
 func (r *Registry) NewCounter(tyep interface{}, name string) *Counter {
-	m := newCounter()
+	m := newCounter(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewStripedCounter creates a striped counter, suited to being
+// incremented concurrently by many goroutines, and registers it
+// with the receiver.
+func (r *Registry) NewStripedCounter(tyep interface{}, name string) *Counter {
+	m := newStripedCounter(r.clock)
 	if r.register(tyep, name, m) {
 		return m
 	}
@@ -52,7 +235,7 @@ func (r *Registry) NewCounter(tyep interface{}, name string) *Counter {
 
 // NewMeter creates a meter and registers it with the receiver.
 func (r *Registry) NewMeter(tyep interface{}, name string) *Meter {
-	m := newMeter()
+	m := newMeter(r.clock)
 	if r.register(tyep, name, m) {
 		return m
 	}
@@ -63,7 +246,52 @@ func (r *Registry) NewMeter(tyep interface{}, name string) *Meter {
 func (r *Registry) NewDistribution(tyep interface{},
 	name string) *Distribution {
 
-	m := newDistribution()
+	m := newDistribution(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewFloatDistribution creates a distribution of float64 values and
+// registers it with the receiver.
+func (r *Registry) NewFloatDistribution(tyep interface{},
+	name string) *Distribution {
+
+	m := newFloatDistribution(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewHDRDistribution creates a distribution that records every value
+// in a High Dynamic Range histogram and registers it with the receiver.
+// Values from 0 to highest are recorded, distinguishing values as
+// small as lowest, to digits significant digits. Negative values are
+// recorded as 0, and values above highest as highest. It returns nil if
+// lowest is less than 1, highest is less than twice lowest, or digits
+// is not between 1 and 5.
+func (r *Registry) NewHDRDistribution(tyep interface{}, name string,
+	lowest, highest int64, digits int) *Distribution {
+
+	m := newHDRDistribution(r.clock, lowest, highest, digits)
+	if m != nil && r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewDigest creates a digest and registers it with the receiver.
+// Higher compressions estimate percentiles more accurately but use
+// more space. If compression is 0, the default of 100 is used.
+func (r *Registry) NewDigest(tyep interface{}, name string,
+	compression float64) *Digest {
+
+	if compression == 0 {
+		compression = digestDefaultCompression
+	}
+	m := newDigest(r.clock, compression)
 	if r.register(tyep, name, m) {
 		return m
 	}
@@ -72,7 +300,77 @@ func (r *Registry) NewDistribution(tyep interface{},
 
 // NewGauge creates a gauge and registers it with the receiver.
 func (r *Registry) NewGauge(tyep interface{}, name string) *Gauge {
-	m := newGauge()
+	m := newGauge(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewIntGauge creates an int64 gauge and registers it with the receiver.
+func (r *Registry) NewIntGauge(tyep interface{}, name string) *IntGauge {
+	m := newIntGauge(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewFloatGauge creates a float64 gauge and registers it with the
+// receiver.
+func (r *Registry) NewFloatGauge(tyep interface{}, name string) *FloatGauge {
+	m := newFloatGauge(r.clock)
+	if r.register(tyep, name, m) {
+		return m
+	}
+	return nil
+}
+
+// NewCounterVec creates a family of counters distinguished by labels
+// and registers it with the receiver.
+func (r *Registry) NewCounterVec(tyep interface{}, name string) *CounterVec {
+	v := r.registerVec(tyep, name, func() Metric { return newCounter(r.clock) })
+	if v == nil {
+		return nil
+	}
+	return &CounterVec{v}
+}
+
+// NewDistributionVec creates a family of distributions distinguished
+// by labels and registers it with the receiver.
+func (r *Registry) NewDistributionVec(tyep interface{},
+	name string) *DistributionVec {
+
+	v := r.registerVec(tyep, name, func() Metric { return newDistribution(r.clock) })
+	if v == nil {
+		return nil
+	}
+	return &DistributionVec{v}
+}
+
+// NewGaugeVec creates a family of gauges distinguished by labels
+// and registers it with the receiver.
+func (r *Registry) NewGaugeVec(tyep interface{}, name string) *GaugeVec {
+	v := r.registerVec(tyep, name, func() Metric { return newGauge(r.clock) })
+	if v == nil {
+		return nil
+	}
+	return &GaugeVec{v}
+}
+
+// NewMeterVec creates a family of meters distinguished by labels
+// and registers it with the receiver.
+func (r *Registry) NewMeterVec(tyep interface{}, name string) *MeterVec {
+	v := r.registerVec(tyep, name, func() Metric { return newMeter(r.clock) })
+	if v == nil {
+		return nil
+	}
+	return &MeterVec{v}
+}
+
+// NewTimer creates a timer and registers it with the receiver.
+func (r *Registry) NewTimer(tyep interface{}, name string) *Timer {
+	m := newTimer(r.clock)
 	if r.register(tyep, name, m) {
 		return m
 	}
@@ -92,27 +390,38 @@ func (r *Registry) List() [][2]string {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	list := make([][2]string, len(r.metrics))
-	i := 0
+	list := make([][2]string, 0, len(r.metrics))
 	for name, metric := range r.metrics {
-		list[i][0] = name
-		var t string
-		switch metric.(type) {
-		case *Counter:
-			t = "counter"
-		case *Distribution:
-			t = "distribution"
-		case *Gauge:
-			t = "gauge"
-		case *Meter:
-			t = "meter"
+		if name, ok := r.localName(name); ok {
+			list = append(list, [2]string{name, metricType(metric)})
 		}
-		list[i][1] = t
-		i++
 	}
 	return list
 }
 
+// metricType returns the name of a metric's type, as listed by List.
+func metricType(m Metric) string {
+	switch m.(type) {
+	case *Counter:
+		return "counter"
+	case *Digest:
+		return "digest"
+	case *Distribution:
+		return "distribution"
+	case *Gauge:
+		return "gauge"
+	case *IntGauge:
+		return "intgauge"
+	case *FloatGauge:
+		return "floatgauge"
+	case *Meter:
+		return "meter"
+	case *Timer:
+		return "timer"
+	}
+	return ""
+}
+
 // ListMetrics returns a map with keys representing metric names
 // and values containing the corresponding metric.
 func (r *Registry) ListMetrics() map[string]Metric {
@@ -121,7 +430,9 @@ func (r *Registry) ListMetrics() map[string]Metric {
 
 	list := make(map[string]Metric)
 	for name, metric := range r.metrics {
-		list[name] = metric
+		if name, ok := r.localName(name); ok {
+			list[name] = metric
+		}
 	}
 	return list
 }
@@ -135,28 +446,106 @@ func (r *Registry) FindS(fullname string) Metric {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	ret := r.metrics[fullname]
+	ret := r.metrics[r.prefix+fullname]
 	return ret
 }
 
+// FindLabeled returns the members of a labelled metric family whose
+// labels satisfy all of the matchers, keyed by their full names.
+func (r *Registry) FindLabeled(tyep interface{}, name string,
+	matchers ...LabelMatcher) map[string]Metric {
+
+	typeName := realType(tyep)
+	return r.FindLabeledS(fmt.Sprintf("%s.%s", typeName, name), matchers...)
+}
+
+func (r *Registry) FindLabeledS(family string,
+	matchers ...LabelMatcher) map[string]Metric {
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	family = r.prefix + family
+	list := make(map[string]Metric)
+	for name, ln := range r.labeled {
+		if ln.family == family && matchLabels(ln.labels, matchers) {
+			list[name[len(r.prefix):]] = r.metrics[name]
+		}
+	}
+	return list
+}
+
+// LabelSet returns the family name and labels of the metric registered
+// under fullname. For metrics that are not part of a labelled family,
+// the family is fullname and the labels are nil.
+func (r *Registry) LabelSet(fullname string) (family string, labels Labels) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ln, ok := r.labeled[r.prefix+fullname]
+	if !ok {
+		return fullname, nil
+	}
+	return ln.family[len(r.prefix):], ln.labels.copy()
+}
+
 var DefaultRegistry *Registry
 
 func init() {
 	DefaultRegistry = NewRegistry("default")
 }
 
+// Sub returns a registry whose metrics are registered with the default
+// registry under names beginning with prefix.
+func Sub(prefix string) *Registry {
+	return DefaultRegistry.Sub(prefix)
+}
+
+// SetMetadata sets the metadata of a metric or labelled family in the
+// default registry.
+func SetMetadata(tyep interface{}, name string, md Metadata) {
+	DefaultRegistry.SetMetadata(tyep, name, md)
+}
+
 // NewCounter creates a counter and registers it with the
 // default registry.
 func NewCounter(tyep interface{}, name string) *Counter {
 	return DefaultRegistry.NewCounter(tyep, name)
 }
 
+// NewStripedCounter creates a striped counter and registers it with the
+// default registry.
+func NewStripedCounter(tyep interface{}, name string) *Counter {
+	return DefaultRegistry.NewStripedCounter(tyep, name)
+}
+
 // NewDistribution creates a distribution and registers it with the
 // default registry.
 func NewDistribution(tyep interface{}, name string) *Distribution {
 	return DefaultRegistry.NewDistribution(tyep, name)
 }
 
+// NewFloatDistribution creates a distribution of float64 values and
+// registers it with the default registry.
+func NewFloatDistribution(tyep interface{}, name string) *Distribution {
+	return DefaultRegistry.NewFloatDistribution(tyep, name)
+}
+
+// NewHDRDistribution creates an HDR distribution and registers it with
+// the default registry.
+func NewHDRDistribution(tyep interface{}, name string,
+	lowest, highest int64, digits int) *Distribution {
+
+	return DefaultRegistry.NewHDRDistribution(tyep, name,
+		lowest, highest, digits)
+}
+
+// NewDigest creates a digest and registers it with the
+// default registry.
+func NewDigest(tyep interface{}, name string, compression float64) *Digest {
+	return DefaultRegistry.NewDigest(tyep, name, compression)
+}
+
 // NewGauge creates a gauge and registers it with the
 // default registry.
 func NewGauge(tyep interface{}, name string) *Gauge {
@@ -168,3 +557,297 @@ func NewGauge(tyep interface{}, name string) *Gauge {
 func NewMeter(tyep interface{}, name string) *Meter {
 	return DefaultRegistry.NewMeter(tyep, name)
 }
+
+// NewTimer creates a timer and registers it with the
+// default registry.
+func NewTimer(tyep interface{}, name string) *Timer {
+	return DefaultRegistry.NewTimer(tyep, name)
+}
+
+// NewIntGauge creates an int64 gauge and registers it with the
+// default registry.
+func NewIntGauge(tyep interface{}, name string) *IntGauge {
+	return DefaultRegistry.NewIntGauge(tyep, name)
+}
+
+// NewFloatGauge creates a float64 gauge and registers it with the
+// default registry.
+func NewFloatGauge(tyep interface{}, name string) *FloatGauge {
+	return DefaultRegistry.NewFloatGauge(tyep, name)
+}
+
+// NewCounterVec creates a family of counters distinguished by labels
+// and registers it with the default registry.
+func NewCounterVec(tyep interface{}, name string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(tyep, name)
+}
+
+// NewDistributionVec creates a family of distributions distinguished
+// by labels and registers it with the default registry.
+func NewDistributionVec(tyep interface{}, name string) *DistributionVec {
+	return DefaultRegistry.NewDistributionVec(tyep, name)
+}
+
+// NewGaugeVec creates a family of gauges distinguished by labels
+// and registers it with the default registry.
+func NewGaugeVec(tyep interface{}, name string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(tyep, name)
+}
+
+// NewMeterVec creates a family of meters distinguished by labels
+// and registers it with the default registry.
+func NewMeterVec(tyep interface{}, name string) *MeterVec {
+	return DefaultRegistry.NewMeterVec(tyep, name)
+}
+
+// SetGaugeMaxAge sets the maximum age of the values of all gauges in
+// the default registry.
+func SetGaugeMaxAge(maxAge time.Duration) {
+	DefaultRegistry.SetGaugeMaxAge(maxAge)
+}
+
+// SetGaugeTimeout sets the update timeout of all gauges in the default
+// registry.
+func SetGaugeTimeout(timeout time.Duration) {
+	DefaultRegistry.SetGaugeTimeout(timeout)
+}
+
+// UpdateGauges updates every gauge in the default registry.
+func UpdateGauges() {
+	DefaultRegistry.UpdateGauges()
+}
+
+// StartGaugeUpdates starts updating every gauge in the default
+// registry at an interval.
+func StartGaugeUpdates(interval time.Duration) {
+	DefaultRegistry.StartGaugeUpdates(interval)
+}
+
+// StopGaugeUpdates stops the updates started by StartGaugeUpdates.
+func StopGaugeUpdates() {
+	DefaultRegistry.StopGaugeUpdates()
+}
+
+// Unregister removes a metric or labelled family from the default
+// registry.
+func Unregister(tyep interface{}, name string) bool {
+	return DefaultRegistry.Unregister(tyep, name)
+}
+
+// RegisterCounter registers a counter with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterCounter(tyep interface{}, name string) (*Counter, error) {
+	return DefaultRegistry.RegisterCounter(tyep, name)
+}
+
+// GetOrRegisterCounter returns the counter registered with the default
+// registry under a type and name, registering one if there is none.
+func GetOrRegisterCounter(tyep interface{}, name string) (*Counter, error) {
+	return DefaultRegistry.GetOrRegisterCounter(tyep, name)
+}
+
+// RegisterStripedCounter registers a striped counter with the default
+// registry, returning a RegistrationError if the name is in use.
+func RegisterStripedCounter(tyep interface{}, name string) (*Counter, error) {
+	return DefaultRegistry.RegisterStripedCounter(tyep, name)
+}
+
+// GetOrRegisterStripedCounter returns the counter registered with the
+// default registry under a type and name, registering a striped one if
+// there is none.
+func GetOrRegisterStripedCounter(tyep interface{},
+	name string) (*Counter, error) {
+
+	return DefaultRegistry.GetOrRegisterStripedCounter(tyep, name)
+}
+
+// RegisterDistribution registers a distribution with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	return DefaultRegistry.RegisterDistribution(tyep, name)
+}
+
+// GetOrRegisterDistribution returns the distribution registered with
+// the default registry under a type and name, registering one if there
+// is none.
+func GetOrRegisterDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	return DefaultRegistry.GetOrRegisterDistribution(tyep, name)
+}
+
+// RegisterFloatDistribution registers a distribution of float64 values
+// with the default registry, returning a RegistrationError if the name
+// is in use.
+func RegisterFloatDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	return DefaultRegistry.RegisterFloatDistribution(tyep, name)
+}
+
+// GetOrRegisterFloatDistribution returns the distribution registered
+// with the default registry under a type and name, registering one of
+// float64 values if there is none.
+func GetOrRegisterFloatDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	return DefaultRegistry.GetOrRegisterFloatDistribution(tyep, name)
+}
+
+// RegisterHDRDistribution registers an HDR distribution with the
+// default registry, returning a RegistrationError if the name is in
+// use.
+func RegisterHDRDistribution(tyep interface{}, name string,
+	lowest, highest int64, digits int) (*Distribution, error) {
+
+	return DefaultRegistry.RegisterHDRDistribution(tyep, name,
+		lowest, highest, digits)
+}
+
+// GetOrRegisterHDRDistribution returns the distribution registered
+// with the default registry under a type and name, registering an HDR
+// distribution if there is none.
+func GetOrRegisterHDRDistribution(tyep interface{}, name string,
+	lowest, highest int64, digits int) (*Distribution, error) {
+
+	return DefaultRegistry.GetOrRegisterHDRDistribution(tyep, name,
+		lowest, highest, digits)
+}
+
+// RegisterDigest registers a digest with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterDigest(tyep interface{},
+	name string, compression float64) (*Digest, error) {
+
+	return DefaultRegistry.RegisterDigest(tyep, name, compression)
+}
+
+// GetOrRegisterDigest returns the digest registered with the default
+// registry under a type and name, registering one if there is none.
+func GetOrRegisterDigest(tyep interface{},
+	name string, compression float64) (*Digest, error) {
+
+	return DefaultRegistry.GetOrRegisterDigest(tyep, name, compression)
+}
+
+// RegisterGauge registers a gauge with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterGauge(tyep interface{}, name string) (*Gauge, error) {
+	return DefaultRegistry.RegisterGauge(tyep, name)
+}
+
+// GetOrRegisterGauge returns the gauge registered with the default
+// registry under a type and name, registering one if there is none.
+func GetOrRegisterGauge(tyep interface{}, name string) (*Gauge, error) {
+	return DefaultRegistry.GetOrRegisterGauge(tyep, name)
+}
+
+// RegisterIntGauge registers an int64 gauge with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterIntGauge(tyep interface{}, name string) (*IntGauge, error) {
+	return DefaultRegistry.RegisterIntGauge(tyep, name)
+}
+
+// GetOrRegisterIntGauge returns the int64 gauge registered with the
+// default registry under a type and name, registering one if there is
+// none.
+func GetOrRegisterIntGauge(tyep interface{}, name string) (*IntGauge, error) {
+	return DefaultRegistry.GetOrRegisterIntGauge(tyep, name)
+}
+
+// RegisterFloatGauge registers a float64 gauge with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterFloatGauge(tyep interface{}, name string) (*FloatGauge, error) {
+	return DefaultRegistry.RegisterFloatGauge(tyep, name)
+}
+
+// GetOrRegisterFloatGauge returns the float64 gauge registered with the
+// default registry under a type and name, registering one if there is
+// none.
+func GetOrRegisterFloatGauge(tyep interface{},
+	name string) (*FloatGauge, error) {
+
+	return DefaultRegistry.GetOrRegisterFloatGauge(tyep, name)
+}
+
+// RegisterMeter registers a meter with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterMeter(tyep interface{}, name string) (*Meter, error) {
+	return DefaultRegistry.RegisterMeter(tyep, name)
+}
+
+// GetOrRegisterMeter returns the meter registered with the default
+// registry under a type and name, registering one if there is none.
+func GetOrRegisterMeter(tyep interface{}, name string) (*Meter, error) {
+	return DefaultRegistry.GetOrRegisterMeter(tyep, name)
+}
+
+// RegisterTimer registers a timer with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterTimer(tyep interface{}, name string) (*Timer, error) {
+	return DefaultRegistry.RegisterTimer(tyep, name)
+}
+
+// GetOrRegisterTimer returns the timer registered with the default
+// registry under a type and name, registering one if there is none.
+func GetOrRegisterTimer(tyep interface{}, name string) (*Timer, error) {
+	return DefaultRegistry.GetOrRegisterTimer(tyep, name)
+}
+
+// RegisterCounterVec registers a CounterVec with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterCounterVec(tyep interface{}, name string) (*CounterVec, error) {
+	return DefaultRegistry.RegisterCounterVec(tyep, name)
+}
+
+// GetOrRegisterCounterVec returns the CounterVec registered with the
+// default registry under a type and name, registering one if there is
+// none.
+func GetOrRegisterCounterVec(tyep interface{},
+	name string) (*CounterVec, error) {
+
+	return DefaultRegistry.GetOrRegisterCounterVec(tyep, name)
+}
+
+// RegisterDistributionVec registers a DistributionVec with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterDistributionVec(tyep interface{}, name string) (*DistributionVec, error) {
+	return DefaultRegistry.RegisterDistributionVec(tyep, name)
+}
+
+// GetOrRegisterDistributionVec returns the DistributionVec registered
+// with the default registry under a type and name, registering one if
+// there is none.
+func GetOrRegisterDistributionVec(tyep interface{},
+	name string) (*DistributionVec, error) {
+
+	return DefaultRegistry.GetOrRegisterDistributionVec(tyep, name)
+}
+
+// RegisterGaugeVec registers a GaugeVec with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterGaugeVec(tyep interface{}, name string) (*GaugeVec, error) {
+	return DefaultRegistry.RegisterGaugeVec(tyep, name)
+}
+
+// GetOrRegisterGaugeVec returns the GaugeVec registered with the
+// default registry under a type and name, registering one if there is
+// none.
+func GetOrRegisterGaugeVec(tyep interface{}, name string) (*GaugeVec, error) {
+	return DefaultRegistry.GetOrRegisterGaugeVec(tyep, name)
+}
+
+// RegisterMeterVec registers a MeterVec with the default registry,
+// returning a RegistrationError if the name is in use.
+func RegisterMeterVec(tyep interface{}, name string) (*MeterVec, error) {
+	return DefaultRegistry.RegisterMeterVec(tyep, name)
+}
+
+// GetOrRegisterMeterVec returns the MeterVec registered with the
+// default registry under a type and name, registering one if there is
+// none.
+func GetOrRegisterMeterVec(tyep interface{}, name string) (*MeterVec, error) {
+	return DefaultRegistry.GetOrRegisterMeterVec(tyep, name)
+}
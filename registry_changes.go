@@ -0,0 +1,525 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RegistrationError is returned when a metric cannot be registered
+// because its name is already in use.
+type RegistrationError struct {
+	// Name is the full name of the metric.
+	Name string
+	// Type is the type of the metric being registered, and Existing
+	// the type of the one registered under Name, as listed by List.
+	// Labelled families have the type of their members followed by
+	// vec, e.g. countervec.
+	Type     string
+	Existing string
+}
+
+func (e *RegistrationError) Error() string {
+	if e.Type == e.Existing {
+		return fmt.Sprintf("metrics: %s is already registered", e.Name)
+	}
+	return fmt.Sprintf("metrics: %s is registered as a %s, not a %s",
+		e.Name, e.Existing, e.Type)
+}
+
+// RegistryEvent describes the registration or unregistration of a
+// metric, including members of labelled families.
+type RegistryEvent struct {
+	// Name is the full name of the metric.
+	Name string
+	// Type is the type of the metric, as listed by List.
+	Type string
+	// Registered is true if the metric was registered, and false if
+	// it was unregistered.
+	Registered bool
+}
+
+// Watch calls f after each metric is registered or unregistered, in
+// the goroutine which made the change, until the returned function is
+// called. f must not register or unregister metrics itself.
+// A sub-registry's watchers see only its own metrics, and their names
+// without its prefix.
+func (r *Registry) Watch(f func(RegistryEvent)) (stop func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.watchers == nil {
+		r.watchers = make(map[uint64]func(RegistryEvent))
+	}
+	id := r.watcherID
+	r.watcherID++
+	r.watchers[id] = func(e RegistryEvent) {
+		var ok bool
+		if e.Name, ok = r.localName(e.Name); ok {
+			f(e)
+		}
+	}
+
+	return func() {
+		r.lock.Lock()
+		delete(r.watchers, id)
+		r.lock.Unlock()
+	}
+}
+
+// watchersLocked returns the functions registered with Watch.
+// The caller must hold the lock.
+func (r *Registry) watchersLocked() []func(RegistryEvent) {
+	if len(r.watchers) == 0 {
+		return nil
+	}
+	watchers := make([]func(RegistryEvent), 0, len(r.watchers))
+	for _, f := range r.watchers {
+		watchers = append(watchers, f)
+	}
+	return watchers
+}
+
+func notify(watchers []func(RegistryEvent), events ...RegistryEvent) {
+	for _, e := range events {
+		for _, f := range watchers {
+			f(e)
+		}
+	}
+}
+
+// Unregister removes the metric or labelled family registered under a
+// type and name, and returns whether it existed.
+func (r *Registry) Unregister(tyep interface{}, name string) bool {
+	return r.UnregisterS(fmt.Sprintf("%s.%s", realType(tyep), name))
+}
+
+// UnregisterS removes the metric registered under fullname, and
+// returns whether it existed. If fullname is a labelled family, all of
+// its members are removed, and members later created by the family are
+// no longer registered.
+func (r *Registry) UnregisterS(fullname string) bool {
+	r.lock.Lock()
+
+	fullname = r.prefix + fullname
+	var events []RegistryEvent
+	found := false
+	if m, exists := r.metrics[fullname]; exists {
+		found = true
+		delete(r.metrics, fullname)
+		delete(r.labeled, fullname)
+		delete(r.meta, fullname)
+		events = append(events, RegistryEvent{fullname, metricType(m), false})
+	} else if _, exists := r.vecs[fullname]; exists {
+		found = true
+		delete(r.vecs, fullname)
+		delete(r.meta, fullname)
+		for name, ln := range r.labeled {
+			if ln.family == fullname {
+				events = append(events,
+					RegistryEvent{name, metricType(r.metrics[name]), false})
+				delete(r.metrics, name)
+				delete(r.labeled, name)
+			}
+		}
+	}
+	watchers := r.watchersLocked()
+	r.lock.Unlock()
+
+	notify(watchers, events...)
+	return found
+}
+
+// RegisterCounter is NewCounter, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterCounter(tyep interface{},
+	name string) (*Counter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newCounter(r.clock), false)
+	c, _ := m.(*Counter)
+	return c, err
+}
+
+// GetOrRegisterCounter returns the Counter registered under a type and
+// name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterCounter(tyep interface{},
+	name string) (*Counter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newCounter(r.clock), true)
+	c, _ := m.(*Counter)
+	return c, err
+}
+
+// RegisterStripedCounter is NewStripedCounter, returning a
+// RegistrationError if the name is in use.
+func (r *Registry) RegisterStripedCounter(tyep interface{},
+	name string) (*Counter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newStripedCounter(r.clock), false)
+	c, _ := m.(*Counter)
+	return c, err
+}
+
+// GetOrRegisterStripedCounter returns the Counter registered under a
+// type and name, striped or not, registering a new striped one if
+// there is none. It returns a RegistrationError if a metric of another
+// type is registered under the name.
+func (r *Registry) GetOrRegisterStripedCounter(tyep interface{},
+	name string) (*Counter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newStripedCounter(r.clock), true)
+	c, _ := m.(*Counter)
+	return c, err
+}
+
+// RegisterDistribution is NewDistribution, returning a
+// RegistrationError if the name is in use.
+func (r *Registry) RegisterDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newDistribution(r.clock), false)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// GetOrRegisterDistribution returns the Distribution registered under
+// a type and name, registering a new one if there is none. It returns
+// a RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newDistribution(r.clock), true)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// RegisterFloatDistribution is NewFloatDistribution, returning a
+// RegistrationError if the name is in use.
+func (r *Registry) RegisterFloatDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newFloatDistribution(r.clock), false)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// GetOrRegisterFloatDistribution returns the Distribution registered
+// under a type and name, of float64 values or not, registering a new
+// one of float64 values if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterFloatDistribution(tyep interface{},
+	name string) (*Distribution, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newFloatDistribution(r.clock), true)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// errHDRParameters is returned when the parameters of an HDR
+// Distribution are invalid.
+var errHDRParameters = errors.New("metrics: invalid HDR distribution parameters")
+
+// RegisterHDRDistribution is NewHDRDistribution, returning a
+// RegistrationError if the name is in use, and an error if the
+// parameters are invalid.
+func (r *Registry) RegisterHDRDistribution(tyep interface{}, name string,
+	lowest, highest int64, digits int) (*Distribution, error) {
+
+	hdr := newHDRDistribution(r.clock, lowest, highest, digits)
+	if hdr == nil {
+		return nil, errHDRParameters
+	}
+	m, err := r.add(r.fullName(tyep, name), hdr, false)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// GetOrRegisterHDRDistribution returns the Distribution registered
+// under a type and name, whatever its kind, registering a new HDR
+// Distribution if there is none. It returns a RegistrationError if a
+// metric of another type is registered under the name, and an error if
+// the parameters are invalid.
+func (r *Registry) GetOrRegisterHDRDistribution(tyep interface{},
+	name string, lowest, highest int64, digits int) (*Distribution, error) {
+
+	hdr := newHDRDistribution(r.clock, lowest, highest, digits)
+	if hdr == nil {
+		return nil, errHDRParameters
+	}
+	m, err := r.add(r.fullName(tyep, name), hdr, true)
+	d, _ := m.(*Distribution)
+	return d, err
+}
+
+// RegisterDigest is NewDigest, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterDigest(tyep interface{}, name string,
+	compression float64) (*Digest, error) {
+
+	if compression == 0 {
+		compression = digestDefaultCompression
+	}
+	m, err := r.add(r.fullName(tyep, name),
+		newDigest(r.clock, compression), false)
+	d, _ := m.(*Digest)
+	return d, err
+}
+
+// GetOrRegisterDigest returns the Digest registered under a type and
+// name, registering a new one with the given compression if there is
+// none. It returns a RegistrationError if a metric of another type is
+// registered under the name.
+func (r *Registry) GetOrRegisterDigest(tyep interface{}, name string,
+	compression float64) (*Digest, error) {
+
+	if compression == 0 {
+		compression = digestDefaultCompression
+	}
+	m, err := r.add(r.fullName(tyep, name),
+		newDigest(r.clock, compression), true)
+	d, _ := m.(*Digest)
+	return d, err
+}
+
+// RegisterGauge is NewGauge, returning a RegistrationError if the name
+// is in use.
+func (r *Registry) RegisterGauge(tyep interface{},
+	name string) (*Gauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newGauge(r.clock), false)
+	g, _ := m.(*Gauge)
+	return g, err
+}
+
+// GetOrRegisterGauge returns the Gauge registered under a type and
+// name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterGauge(tyep interface{},
+	name string) (*Gauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newGauge(r.clock), true)
+	g, _ := m.(*Gauge)
+	return g, err
+}
+
+// RegisterIntGauge is NewIntGauge, returning a RegistrationError if
+// the name is in use.
+func (r *Registry) RegisterIntGauge(tyep interface{},
+	name string) (*IntGauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newIntGauge(r.clock), false)
+	g, _ := m.(*IntGauge)
+	return g, err
+}
+
+// GetOrRegisterIntGauge returns the IntGauge registered under a type
+// and name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterIntGauge(tyep interface{},
+	name string) (*IntGauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newIntGauge(r.clock), true)
+	g, _ := m.(*IntGauge)
+	return g, err
+}
+
+// RegisterFloatGauge is NewFloatGauge, returning a RegistrationError
+// if the name is in use.
+func (r *Registry) RegisterFloatGauge(tyep interface{},
+	name string) (*FloatGauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newFloatGauge(r.clock), false)
+	g, _ := m.(*FloatGauge)
+	return g, err
+}
+
+// GetOrRegisterFloatGauge returns the FloatGauge registered under a
+// type and name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterFloatGauge(tyep interface{},
+	name string) (*FloatGauge, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newFloatGauge(r.clock), true)
+	g, _ := m.(*FloatGauge)
+	return g, err
+}
+
+// RegisterMeter is NewMeter, returning a RegistrationError if the name
+// is in use.
+func (r *Registry) RegisterMeter(tyep interface{},
+	name string) (*Meter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newMeter(r.clock), false)
+	me, _ := m.(*Meter)
+	return me, err
+}
+
+// GetOrRegisterMeter returns the Meter registered under a type and
+// name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterMeter(tyep interface{},
+	name string) (*Meter, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newMeter(r.clock), true)
+	me, _ := m.(*Meter)
+	return me, err
+}
+
+// RegisterTimer is NewTimer, returning a RegistrationError if the name
+// is in use.
+func (r *Registry) RegisterTimer(tyep interface{},
+	name string) (*Timer, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newTimer(r.clock), false)
+	t, _ := m.(*Timer)
+	return t, err
+}
+
+// GetOrRegisterTimer returns the Timer registered under a type and
+// name, registering a new one if there is none. It returns a
+// RegistrationError if a metric of another type is registered under
+// the name.
+func (r *Registry) GetOrRegisterTimer(tyep interface{},
+	name string) (*Timer, error) {
+
+	m, err := r.add(r.fullName(tyep, name),
+		newTimer(r.clock), true)
+	t, _ := m.(*Timer)
+	return t, err
+}
+
+// RegisterCounterVec is NewCounterVec, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterCounterVec(tyep interface{},
+	name string) (*CounterVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newCounter(r.clock) }, false)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterVec{v}, nil
+}
+
+// GetOrRegisterCounterVec returns the CounterVec registered under a
+// type and name, registering a new one if there is none. It returns a
+// RegistrationError if a metric or family of another type is
+// registered under the name.
+func (r *Registry) GetOrRegisterCounterVec(tyep interface{},
+	name string) (*CounterVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newCounter(r.clock) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterVec{v}, nil
+}
+
+// RegisterDistributionVec is NewDistributionVec, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterDistributionVec(tyep interface{},
+	name string) (*DistributionVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newDistribution(r.clock) }, false)
+	if err != nil {
+		return nil, err
+	}
+	return &DistributionVec{v}, nil
+}
+
+// GetOrRegisterDistributionVec returns the DistributionVec registered
+// under a type and name, registering a new one if there is none. It
+// returns a RegistrationError if a metric or family of another type is
+// registered under the name.
+func (r *Registry) GetOrRegisterDistributionVec(tyep interface{},
+	name string) (*DistributionVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newDistribution(r.clock) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return &DistributionVec{v}, nil
+}
+
+// RegisterGaugeVec is NewGaugeVec, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterGaugeVec(tyep interface{},
+	name string) (*GaugeVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newGauge(r.clock) }, false)
+	if err != nil {
+		return nil, err
+	}
+	return &GaugeVec{v}, nil
+}
+
+// GetOrRegisterGaugeVec returns the GaugeVec registered under a type
+// and name, registering a new one if there is none. It returns a
+// RegistrationError if a metric or family of another type is
+// registered under the name.
+func (r *Registry) GetOrRegisterGaugeVec(tyep interface{},
+	name string) (*GaugeVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newGauge(r.clock) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return &GaugeVec{v}, nil
+}
+
+// RegisterMeterVec is NewMeterVec, returning a RegistrationError if the
+// name is in use.
+func (r *Registry) RegisterMeterVec(tyep interface{},
+	name string) (*MeterVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newMeter(r.clock) }, false)
+	if err != nil {
+		return nil, err
+	}
+	return &MeterVec{v}, nil
+}
+
+// GetOrRegisterMeterVec returns the MeterVec registered under a type
+// and name, registering a new one if there is none. It returns a
+// RegistrationError if a metric or family of another type is
+// registered under the name.
+func (r *Registry) GetOrRegisterMeterVec(tyep interface{},
+	name string) (*MeterVec, error) {
+
+	v, err := r.addVec(r.fullName(tyep, name),
+		func() Metric { return newMeter(r.clock) }, true)
+	if err != nil {
+		return nil, err
+	}
+	return &MeterVec{v}, nil
+}
@@ -0,0 +1,349 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestRegistryUnregister(t *testing.T) {
+	r := testRegistryInitialize()
+
+	if !r.Unregister(testRegistryType{}, "internal_type") {
+		t.Errorf("Unregister did not find a registered metric")
+	}
+	if r.Find(testRegistryType{}, "internal_type") != nil {
+		t.Errorf("Unregistered metric was found")
+	}
+	if r.Unregister(testRegistryType{}, "internal_type") {
+		t.Errorf("Unregister found an unregistered metric")
+	}
+
+	// the name can be reused for another type
+	if r.NewMeter(testRegistryType{}, "internal_type") == nil {
+		t.Errorf("Could not register a metric under an unregistered name")
+	}
+}
+
+func TestRegistryUnregisterVec(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	v := r.NewCounterVec(testRegistryType{}, "vec")
+	v.WithLabels(Labels{"a": "1"}).Inc(1)
+	v.WithLabels(Labels{"a": "2"}).Inc(1)
+
+	if !r.Unregister(testRegistryType{}, "vec") {
+		t.Errorf("Unregister did not find a registered family")
+	}
+	if n := len(r.ListMetrics()); n != 0 {
+		t.Errorf("Wrong number of metrics, got %d expected 0", n)
+	}
+
+	// members of an unregistered family are not registered
+	if c := v.WithLabels(Labels{"a": "3"}); c == nil {
+		t.Errorf("WithLabels returned nil for an unregistered family")
+	}
+	if n := len(r.ListMetrics()); n != 0 {
+		t.Errorf("Wrong number of metrics, got %d expected 0", n)
+	}
+}
+
+func TestRegistryGetOrRegister(t *testing.T) {
+	r := NewRegistry("testRegistry")
+
+	c1, err := r.GetOrRegisterCounter(testRegistryType{}, "counter")
+	if c1 == nil || err != nil {
+		t.Fatalf("GetOrRegisterCounter failed: %v", err)
+	}
+	c2, err := r.GetOrRegisterCounter(testRegistryType{}, "counter")
+	if c2 != c1 || err != nil {
+		t.Errorf("GetOrRegisterCounter did not return the existing Counter")
+	}
+
+	m, err := r.GetOrRegisterMeter(testRegistryType{}, "counter")
+	if m != nil {
+		t.Errorf("GetOrRegisterMeter returned a Meter for a Counter's name")
+	}
+	e, ok := err.(*RegistrationError)
+	if !ok {
+		t.Fatalf("Wrong error, got %v expected a RegistrationError", err)
+	}
+	expected := RegistrationError{
+		Name:     "metrics.testRegistryType.counter",
+		Type:     "meter",
+		Existing: "counter",
+	}
+	if *e != expected {
+		t.Errorf("Wrong error, got %+v expected %+v", *e, expected)
+	}
+
+	v1, err := r.GetOrRegisterGaugeVec(testRegistryType{}, "vec")
+	if v1 == nil || err != nil {
+		t.Fatalf("GetOrRegisterGaugeVec failed: %v", err)
+	}
+	v2, _ := r.GetOrRegisterGaugeVec(testRegistryType{}, "vec")
+	if v2 == nil || v2.metricVec != v1.metricVec {
+		t.Errorf("GetOrRegisterGaugeVec did not return the existing family")
+	}
+	if _, err := r.GetOrRegisterMeterVec(testRegistryType{}, "vec"); err == nil {
+		t.Errorf("GetOrRegisterMeterVec returned a GaugeVec's name")
+	}
+	if _, err := r.GetOrRegisterGauge(testRegistryType{}, "vec"); err == nil {
+		t.Errorf("GetOrRegisterGauge returned a GaugeVec's name")
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry("testRegistry")
+
+	if d, err := r.RegisterDistribution(testRegistryType{}, "d"); d == nil || err != nil {
+		t.Fatalf("RegisterDistribution failed: %v", err)
+	}
+	d, err := r.RegisterDistribution(testRegistryType{}, "d")
+	if d != nil || err == nil {
+		t.Errorf("RegisterDistribution registered the same name twice")
+	}
+	expected := "metrics: metrics.testRegistryType.d is already registered"
+	if err != nil && err.Error() != expected {
+		t.Errorf("Wrong error, got %q expected %q", err.Error(), expected)
+	}
+
+	_, err = r.RegisterTimer(testRegistryType{}, "d")
+	expected = "metrics: metrics.testRegistryType.d is registered as a " +
+		"distribution, not a timer"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Wrong error, got %v expected %q", err, expected)
+	}
+}
+
+func TestRegistryWatch(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	var events []RegistryEvent
+	stop := r.Watch(func(e RegistryEvent) {
+		events = append(events, e)
+	})
+
+	r.NewCounter(testRegistryType{}, "counter")
+	r.NewCounter(testRegistryType{}, "counter")
+	v := r.NewMeterVec(testRegistryType{}, "vec")
+	v.WithLabels(Labels{"a": "1"})
+	v.WithLabels(Labels{"a": "1"})
+	r.Unregister(testRegistryType{}, "vec")
+	r.Unregister(testRegistryType{}, "counter")
+	stop()
+	r.NewCounter(testRegistryType{}, "counter")
+
+	expected := []RegistryEvent{
+		{"metrics.testRegistryType.counter", "counter", true},
+		{`metrics.testRegistryType.vec{a="1"}`, "meter", true},
+		{`metrics.testRegistryType.vec{a="1"}`, "meter", false},
+		{"metrics.testRegistryType.counter", "counter", false},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Wrong events, got %v expected %v", events, expected)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("Wrong event %d, got %v expected %v",
+				i, events[i], expected[i])
+		}
+	}
+}
+
+// testRegistrations registers each kind of metric, with Register and
+// GetOrRegister, returning whether a metric was returned. Kinds of
+// the same type may be returned for each other by GetOrRegister.
+var testRegistrations = []struct {
+	kind          string
+	typ           string
+	register      func(r *Registry, name string) (bool, error)
+	getOrRegister func(r *Registry, name string) (bool, error)
+}{
+	{"counter", "counter",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterCounter(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterCounter(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"striped counter", "counter",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterStripedCounter(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterStripedCounter(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"distribution", "distribution",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterDistribution(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterDistribution(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"float distribution", "distribution",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterFloatDistribution(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterFloatDistribution(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"HDR distribution", "distribution",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterHDRDistribution(testRegistryType{}, name,
+				1, 1000, 2)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterHDRDistribution(testRegistryType{}, name,
+				1, 1000, 2)
+			return m != nil, err
+		}},
+	{"digest", "digest",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterDigest(testRegistryType{}, name, 0)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterDigest(testRegistryType{}, name, 0)
+			return m != nil, err
+		}},
+	{"gauge", "gauge",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterGauge(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterGauge(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"int gauge", "intgauge",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterIntGauge(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterIntGauge(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"float gauge", "floatgauge",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterFloatGauge(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterFloatGauge(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"meter", "meter",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterMeter(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterMeter(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"timer", "timer",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterTimer(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterTimer(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"counter vec", "countervec",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterCounterVec(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterCounterVec(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"distribution vec", "distributionvec",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterDistributionVec(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterDistributionVec(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"gauge vec", "gaugevec",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterGaugeVec(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterGaugeVec(testRegistryType{}, name)
+			return m != nil, err
+		}},
+	{"meter vec", "metervec",
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.RegisterMeterVec(testRegistryType{}, name)
+			return m != nil, err
+		},
+		func(r *Registry, name string) (bool, error) {
+			m, err := r.GetOrRegisterMeterVec(testRegistryType{}, name)
+			return m != nil, err
+		}},
+}
+
+func TestRegistryRegisterConflicts(t *testing.T) {
+	for _, existing := range testRegistrations {
+		for _, other := range testRegistrations {
+			r := NewRegistry("testRegistry")
+			if ok, err := existing.register(r, "m"); !ok || err != nil {
+				t.Fatalf("Register of a %s failed: %v", existing.kind, err)
+			}
+
+			ok, err := other.register(r, "m")
+			if ok || err == nil {
+				t.Errorf("Register of a %s succeeded over a %s",
+					other.kind, existing.kind)
+			}
+			if _, isRegErr := err.(*RegistrationError); !isRegErr {
+				t.Errorf("Wrong error registering a %s over a %s, got %v "+
+					"expected a RegistrationError", other.kind, existing.kind, err)
+			}
+
+			ok, err = other.getOrRegister(r, "m")
+			if existing.typ == other.typ {
+				if !ok || err != nil {
+					t.Errorf("GetOrRegister of a %s did not return the %s: %v",
+						other.kind, existing.kind, err)
+				}
+				continue
+			}
+			if ok {
+				t.Errorf("GetOrRegister of a %s returned a %s",
+					other.kind, existing.kind)
+			}
+			e, isRegErr := err.(*RegistrationError)
+			if !isRegErr || e.Type != other.typ || e.Existing != existing.typ {
+				t.Errorf("Wrong error getting a %s over a %s, got %v",
+					other.kind, existing.kind, err)
+			}
+		}
+	}
+}
+
+func TestRegistryRegisterHDRParameters(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	if d, err := r.RegisterHDRDistribution(testRegistryType{}, "hdr",
+		0, 1000, 2); d != nil || err == nil {
+
+		t.Errorf("RegisterHDRDistribution accepted a lowest value of 0")
+	}
+	if d, err := r.GetOrRegisterHDRDistribution(testRegistryType{}, "hdr",
+		1, 1000, 9); d != nil || err == nil {
+
+		t.Errorf("GetOrRegisterHDRDistribution accepted 9 digits")
+	}
+}
@@ -60,3 +60,113 @@ func TestRegistryFindS(t *testing.T) {
 			"should have a metric")
 	}
 }
+
+func TestRegistrySub(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	a := r.Sub("a")
+	b := r.Sub("b")
+	ab := a.Sub("b")
+
+	ca := a.NewCounter(testRegistryType{}, "counter")
+	cb := b.NewCounter(testRegistryType{}, "counter")
+	cab := ab.NewCounter(testRegistryType{}, "counter")
+	if ca == nil || cb == nil || cab == nil || ca == cb {
+		t.Fatalf("Could not register the same name in different sub-registries")
+	}
+	if a.Sub("b").NewCounter(testRegistryType{}, "counter") != nil {
+		t.Errorf("Registered the same name twice under the same prefix")
+	}
+	if ab.Prefix() != "a/b/" {
+		t.Errorf("Wrong prefix, got %q expected %q", ab.Prefix(), "a/b/")
+	}
+
+	if r.FindS("a/metrics.testRegistryType.counter") != ca {
+		t.Errorf("Parent did not find a sub-registry's metric")
+	}
+	if r.FindS("a/b/metrics.testRegistryType.counter") != cab {
+		t.Errorf("Parent did not find a nested sub-registry's metric")
+	}
+	if a.Find(testRegistryType{}, "counter") != ca {
+		t.Errorf("Sub-registry did not find its metric")
+	}
+	if a.FindS("b/metrics.testRegistryType.counter") != cab {
+		t.Errorf("Sub-registry did not find its child's metric")
+	}
+	if b.FindS("a/metrics.testRegistryType.counter") != nil {
+		t.Errorf("Sub-registry found a sibling's metric")
+	}
+
+	if n := len(r.List()); n != 3 {
+		t.Errorf("Wrong number of metrics in parent, got %d expected 3", n)
+	}
+	m := a.ListMetrics()
+	if len(m) != 2 || m["metrics.testRegistryType.counter"] != ca ||
+		m["b/metrics.testRegistryType.counter"] != cab {
+		t.Errorf("Wrong sub-registry metrics, got %v", m)
+	}
+}
+
+func TestRegistrySubPrefix(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	r.NewCounter(testRegistryType{}, "counter")
+
+	// a prefix named like a package does not see the parent's metrics
+	if m := r.Sub("metrics").ListMetrics(); len(m) != 0 {
+		t.Errorf("Sub-registry listed its parent's metrics: %v", m)
+	}
+
+	for _, prefix := range []string{"", "a/b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Sub accepted the invalid prefix %q", prefix)
+				}
+			}()
+			r.Sub(prefix)
+		}()
+	}
+}
+
+func TestRegistrySubVec(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	s := r.Sub("pool")
+	v := s.NewCounterVec(testRegistryType{}, "vec")
+	c := v.WithLabels(Labels{"a": "1"})
+
+	if v.Name() != "metrics.testRegistryType.vec" {
+		t.Errorf("Wrong name, got %s expected metrics.testRegistryType.vec",
+			v.Name())
+	}
+	name := `metrics.testRegistryType.vec{a="1"}`
+	if found := v.Find(); len(found) != 1 || found[name] != c {
+		t.Errorf("Wrong members, got %v", found)
+	}
+	if r.FindS("pool/"+name) != c {
+		t.Errorf("Parent did not find a sub-registry's labelled metric")
+	}
+	family, labels := s.LabelSet(name)
+	if family != "metrics.testRegistryType.vec" || labels["a"] != "1" {
+		t.Errorf("Wrong label set, got %s %v", family, labels)
+	}
+	family, _ = r.LabelSet("pool/" + name)
+	if family != "pool/metrics.testRegistryType.vec" {
+		t.Errorf("Wrong family, got %s expected "+
+			"pool/metrics.testRegistryType.vec", family)
+	}
+
+	var events []RegistryEvent
+	stop := s.Watch(func(e RegistryEvent) { events = append(events, e) })
+	r.NewCounter(testRegistryType{}, "counter")
+	v.WithLabels(Labels{"a": "2"})
+	stop()
+	if len(events) != 1 || events[0].Name != `metrics.testRegistryType.vec{a="2"}` {
+		t.Errorf("Wrong events, got %v", events)
+	}
+
+	if !s.Unregister(testRegistryType{}, "vec") {
+		t.Errorf("Sub-registry could not unregister its family")
+	}
+	if n := len(r.List()); n != 1 {
+		t.Errorf("Wrong number of metrics, got %d expected 1", n)
+	}
+}
@@ -0,0 +1,254 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"metrics"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	graphiteDefaultInterval   = time.Minute
+	graphiteDefaultBufferSize = 100000
+	graphiteDefaultMinBackoff = time.Second
+	graphiteDefaultMaxBackoff = 5 * time.Minute
+	graphiteDefaultTimeout    = 10 * time.Second
+)
+
+// ErrGraphiteBackoff is returned by Flush while waiting to reconnect
+// after a failure. The points are kept in the buffer.
+var ErrGraphiteBackoff = errors.New("reporter: waiting to reconnect to Carbon")
+
+// GraphiteOptions configures a Graphite reporter. The zero value is
+// usable.
+type GraphiteOptions struct {
+	// Prefix is prepended, followed by a dot, to every metric path.
+	Prefix string
+
+	// Interval is the time between flushes. The default is 1 minute.
+	Interval time.Duration
+
+	// BufferSize is the largest number of lines kept while Carbon
+	// cannot be reached. The oldest lines are discarded first.
+	// The default is 100000.
+	BufferSize int
+
+	// MinBackoff and MaxBackoff bound the time waited before
+	// reconnecting after a failure, which doubles with each consecutive
+	// failure. The defaults are 1 second and 5 minutes.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout limits the time taken to connect and to send the
+	// buffered lines. The default is 10 seconds.
+	Timeout time.Duration
+
+	// Tagged sends labels as Graphite tags, e.g. requests;code=200.
+	// Otherwise, label values are appended to metric paths.
+	Tagged bool
+
+	// ErrorLog logs errors while flushing in the background. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// Graphite periodically pushes the metrics in a Registry to Carbon,
+// using the plaintext protocol over TCP. Points are named as
+// described in the package documentation.
+//
+// While Carbon cannot be reached, lines are kept in a bounded buffer
+// and sent, with their original timestamps, once it reconnects.
+type Graphite struct {
+	registry *metrics.Registry
+	addr     string
+	opts     GraphiteOptions
+	conn     net.Conn
+	buffer   []string
+	dropped  int64
+	backoff  time.Duration
+	retry    time.Time
+	loop     *loop
+	lock     sync.Mutex
+}
+
+// NewGraphite creates a Graphite reporter for a registry, sending to
+// the Carbon server at addr, and starts flushing at the configured
+// interval. It connects when first flushed.
+func NewGraphite(r *metrics.Registry, addr string,
+	opts GraphiteOptions) *Graphite {
+
+	if opts.Interval == 0 {
+		opts.Interval = graphiteDefaultInterval
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = graphiteDefaultBufferSize
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = graphiteDefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = graphiteDefaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = graphiteDefaultTimeout
+	}
+
+	g := &Graphite{
+		registry: r,
+		addr:     addr,
+		opts:     opts,
+	}
+	g.loop = startLoop(opts.Interval, func(context.Context) {
+		if err := g.Flush(); err != nil && err != ErrGraphiteBackoff {
+			logf(g.opts.ErrorLog, "reporter: Graphite flush failed: %v", err)
+		}
+	})
+	return g
+}
+
+// graphiteReplacer replaces characters not allowed in Graphite paths.
+var graphiteReplacer = strings.NewReplacer(
+	" ", "_", "\t", "_", "\n", "_", ";", "_", "=", "_", "~", "_",
+	"(", "_", ")", "_", "{", "_", "}", "_", "[", "_", "]", "_",
+	"*", "_", "?", "_", ",", "_", "!", "_", "^", "_",
+)
+
+func (g *Graphite) path(p point) string {
+	path := dottedName(p.name)
+	if g.opts.Prefix != "" {
+		path = g.opts.Prefix + "." + path
+	}
+	if !g.opts.Tagged {
+		return graphiteReplacer.Replace(path + labelSuffix(p.labels))
+	}
+
+	path = graphiteReplacer.Replace(path)
+	for _, name := range p.labels.Names() {
+		path += ";" + graphiteReplacer.Replace(name) + "=" +
+			graphiteReplacer.Replace(p.labels[name])
+	}
+	return path
+}
+
+func (g *Graphite) line(p point, timestamp int64) string {
+	return g.path(p) + " " + strconv.FormatFloat(p.value, 'f', -1, 64) +
+		" " + strconv.FormatInt(timestamp, 10)
+}
+
+// Flush buffers the current values of all metrics in the registry,
+// and sends the buffer to Carbon, reconnecting if necessary. If Carbon
+// cannot be reached, the buffer is kept for the next Flush.
+func (g *Graphite) Flush() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := time.Now()
+	for _, p := range registryPoints(g.registry) {
+		g.buffer = append(g.buffer, g.line(p, now.Unix()))
+	}
+	if over := len(g.buffer) - g.opts.BufferSize; over > 0 {
+		g.buffer = append(g.buffer[:0], g.buffer[over:]...)
+		g.dropped += int64(over)
+	}
+
+	return g.send(now)
+}
+
+func (g *Graphite) send(now time.Time) error {
+	if len(g.buffer) == 0 {
+		return nil
+	}
+
+	if g.conn == nil {
+		if now.Before(g.retry) {
+			return ErrGraphiteBackoff
+		}
+		conn, err := net.DialTimeout("tcp", g.addr, g.opts.Timeout)
+		if err != nil {
+			g.fail(now)
+			return err
+		}
+		g.conn = conn
+	}
+
+	var b bytes.Buffer
+	for _, line := range g.buffer {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	g.conn.SetWriteDeadline(now.Add(g.opts.Timeout))
+	if _, err := g.conn.Write(b.Bytes()); err != nil {
+		// lines may have been partly sent, but resending a point with
+		// the same timestamp overwrites it
+		g.conn.Close()
+		g.conn = nil
+		g.fail(now)
+		return err
+	}
+
+	g.buffer = g.buffer[:0]
+	g.backoff = 0
+	return nil
+}
+
+// fail delays the next connection attempt, doubling the delay with
+// each consecutive failure.
+func (g *Graphite) fail(now time.Time) {
+	switch {
+	case g.backoff == 0:
+		g.backoff = g.opts.MinBackoff
+	case g.backoff < g.opts.MaxBackoff:
+		g.backoff *= 2
+		if g.backoff > g.opts.MaxBackoff {
+			g.backoff = g.opts.MaxBackoff
+		}
+	}
+	g.retry = now.Add(g.backoff)
+}
+
+// Buffered returns the number of lines waiting to be sent.
+func (g *Graphite) Buffered() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return len(g.buffer)
+}
+
+// Dropped returns the number of lines discarded because the buffer
+// was full.
+func (g *Graphite) Dropped() int64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.dropped
+}
+
+// Stop stops flushing, sends the metrics a final time, and closes the
+// connection. Lines that could not be sent are discarded.
+func (g *Graphite) Stop() error {
+	g.loop.Stop()
+
+	g.lock.Lock()
+	// make a final attempt even while backing off
+	g.retry = time.Time{}
+	g.lock.Unlock()
+
+	err := g.Flush()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.conn != nil {
+		if cerr := g.conn.Close(); err == nil {
+			err = cerr
+		}
+		g.conn = nil
+	}
+	return err
+}
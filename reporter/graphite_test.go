@@ -0,0 +1,221 @@
+package reporter
+
+import (
+	"bufio"
+	"metrics"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCarbon is a Carbon server which records the lines received.
+type testCarbon struct {
+	listener net.Listener
+	lines    []string
+	lock     sync.Mutex
+}
+
+func testCarbonListen(t *testing.T, addr string) *testCarbon {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	c := &testCarbon{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					c.lock.Lock()
+					c.lines = append(c.lines, s.Text())
+					c.lock.Unlock()
+				}
+			}()
+		}
+	}()
+	return c
+}
+
+// received waits until n lines have been received, or a short time
+// has passed, and returns the lines without their timestamps.
+func (c *testCarbon) received(n int) []string {
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.lock.Lock()
+		got := len(c.lines)
+		c.lock.Unlock()
+		if got >= n || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lines := make([]string, len(c.lines))
+	for i, l := range c.lines {
+		lines[i] = l[:strings.LastIndex(l, " ")]
+	}
+	return lines
+}
+
+func TestGraphiteFlush(t *testing.T) {
+	c := testCarbonListen(t, "127.0.0.1:0")
+	defer c.listener.Close()
+
+	r := testRegistryInit()
+	g := NewGraphite(r, c.listener.Addr().String(), GraphiteOptions{
+		Prefix:   "app",
+		Interval: time.Hour,
+	})
+	defer g.Stop()
+
+	if err := g.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := c.received(len(registryPoints(r)))
+	testContainsLines(t, lines, []string{
+		"app.reporter.testType.counter 5",
+		"app.reporter.testType.gauge -1.5",
+		"app.reporter.testType.requests.200 2",
+		"app.reporter.testType.distribution.count 1",
+		"app.reporter.testType.distribution.p50 7",
+		"app.reporter.testType.distribution.max 7",
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, l := range c.lines {
+		fields := strings.Fields(l)
+		if len(fields) != 3 {
+			t.Errorf("Wrong line %q, expected path, value and timestamp", l)
+		}
+	}
+	if g.Buffered() != 0 {
+		t.Errorf("Wrong buffered lines, got %d expected 0", g.Buffered())
+	}
+}
+
+func TestGraphiteTagged(t *testing.T) {
+	c := testCarbonListen(t, "127.0.0.1:0")
+	defer c.listener.Close()
+
+	r := metrics.NewRegistry("test")
+	v := r.NewCounterVec(testType{}, "requests")
+	v.WithLabels(metrics.Labels{"code": "200", "path": "/a b"}).Inc(2)
+
+	g := NewGraphite(r, c.listener.Addr().String(), GraphiteOptions{
+		Interval: time.Hour,
+		Tagged:   true,
+	})
+	defer g.Stop()
+
+	if err := g.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	testContainsLines(t, c.received(1), []string{
+		"reporter.testType.requests;code=200;path=/a_b 2",
+	})
+}
+
+func TestGraphiteSub(t *testing.T) {
+	c := testCarbonListen(t, "127.0.0.1:0")
+	defer c.listener.Close()
+
+	r := metrics.NewRegistry("test")
+	v := r.Sub("tenant").NewCounterVec(testType{}, "requests")
+	v.WithLabels(metrics.Labels{"path": "/a.b"}).Inc(2)
+
+	g := NewGraphite(r, c.listener.Addr().String(), GraphiteOptions{
+		Interval: time.Hour,
+	})
+	defer g.Stop()
+
+	if err := g.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	testContainsLines(t, c.received(1), []string{
+		"tenant.reporter.testType.requests._a_b 2",
+	})
+}
+
+func TestGraphiteReconnect(t *testing.T) {
+	// find a free port, with nothing listening on it
+	c := testCarbonListen(t, "127.0.0.1:0")
+	addr := c.listener.Addr().String()
+	c.listener.Close()
+
+	r := metrics.NewRegistry("test")
+	counter := r.NewCounter(testType{}, "counter")
+	counter.Inc(1)
+
+	g := NewGraphite(r, addr, GraphiteOptions{
+		Interval:   time.Hour,
+		BufferSize: 3,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	})
+	defer g.Stop()
+
+	for i := 0; i < 4; i++ {
+		if err := g.Flush(); err == nil {
+			t.Fatalf("Flush succeeded with no server")
+		}
+		counter.Inc(1)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if g.Buffered() != 3 {
+		t.Errorf("Wrong buffered lines, got %d expected 3", g.Buffered())
+	}
+	if g.Dropped() != 1 {
+		t.Errorf("Wrong dropped lines, got %d expected 1", g.Dropped())
+	}
+
+	c = testCarbonListen(t, addr)
+	defer c.listener.Close()
+
+	if err := g.Flush(); err != nil {
+		t.Fatalf("Flush failed after reconnecting: %v", err)
+	}
+	lines := c.received(3)
+	expected := []string{"reporter.testType.counter 3", "reporter.testType.counter 4", "reporter.testType.counter 5"}
+	if strings.Join(lines, ",") != strings.Join(expected, ",") {
+		t.Errorf("Wrong lines, got %q expected %q", lines, expected)
+	}
+	if g.Buffered() != 0 {
+		t.Errorf("Wrong buffered lines, got %d expected 0", g.Buffered())
+	}
+}
+
+func TestGraphiteBackoff(t *testing.T) {
+	c := testCarbonListen(t, "127.0.0.1:0")
+	addr := c.listener.Addr().String()
+	c.listener.Close()
+
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "counter")
+
+	g := NewGraphite(r, addr, GraphiteOptions{
+		Interval:   time.Hour,
+		MinBackoff: time.Hour,
+	})
+	defer g.Stop()
+
+	if err := g.Flush(); err == nil || err == ErrGraphiteBackoff {
+		t.Errorf("Wrong first error, got %v expected a connection error", err)
+	}
+	if err := g.Flush(); err != ErrGraphiteBackoff {
+		t.Errorf("Wrong second error, got %v expected %v",
+			err, ErrGraphiteBackoff)
+	}
+	if g.Buffered() != 2 {
+		t.Errorf("Wrong buffered lines, got %d expected 2", g.Buffered())
+	}
+}
@@ -0,0 +1,280 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"metrics"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	influxDefaultInterval  = 10 * time.Second
+	influxDefaultBatchSize = 5000
+	influxDefaultTimeout   = 10 * time.Second
+)
+
+// influxLines returns a line of InfluxDB line protocol for each metric
+// in a registry, sorted. The measurement is the metric's name, and the
+// fields are its points, named by their suffixes as described in the
+// package documentation, or value if they have none. The registry's
+// name is added as the registry tag, followed by the metric's labels
+// and tags.
+func influxLines(r *metrics.Registry, tags map[string]string,
+	t time.Time) []string {
+
+	var lines []string
+	timestamp := strconv.FormatInt(t.UnixNano(), 10)
+	for fullname, metric := range r.ListMetrics() {
+		name, labels := r.LabelSet(fullname)
+		points := metricPoints(name, labels, metric)
+		if len(points) == 0 {
+			continue
+		}
+
+		all := make(metrics.Labels, len(tags)+len(labels)+1)
+		for k, v := range tags {
+			all[k] = v
+		}
+		all["registry"] = r.Name()
+		for k, v := range labels {
+			all[k] = v
+		}
+
+		var line bytes.Buffer
+		line.WriteString(influxMeasurementEscaper.Replace(name))
+		for _, k := range all.Names() {
+			if all[k] == "" {
+				// empty tag values are not allowed
+				continue
+			}
+			line.WriteString("," + influxKeyEscaper.Replace(k) + "=" +
+				influxKeyEscaper.Replace(all[k]))
+		}
+
+		sep := " "
+		for _, p := range points {
+			if math.IsNaN(p.value) || math.IsInf(p.value, 0) {
+				continue
+			}
+			field := strings.TrimPrefix(strings.TrimPrefix(p.name, name), ".")
+			if field == "" {
+				field = "value"
+			}
+			line.WriteString(sep + influxKeyEscaper.Replace(field) + "=" +
+				influxValue(p))
+			sep = ","
+		}
+		if sep == " " {
+			continue
+		}
+
+		line.WriteString(" " + timestamp)
+		lines = append(lines, line.String())
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(
+		",", `\,`, " ", `\ `, "\n", `\n`,
+	)
+	influxKeyEscaper = strings.NewReplacer(
+		",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`,
+	)
+)
+
+// influxValue formats counters as integers, and other points as floats.
+func influxValue(p point) string {
+	if p.kind == counterPoint && p.value == math.Trunc(p.value) &&
+		math.Abs(p.value) < 1<<63 {
+		return strconv.FormatInt(int64(p.value), 10) + "i"
+	}
+	return strconv.FormatFloat(p.value, 'g', -1, 64)
+}
+
+// WriteInflux writes the current values of all metrics in a registry
+// to w in InfluxDB line protocol, with nanosecond timestamps.
+// There is a line for each metric, whose measurement is the metric's
+// name and whose fields are named by the suffixes of its points, as
+// described in the package documentation, or value for a point with
+// no suffix. Each line is tagged with the registry's name as registry,
+// the metric's labels, and tags.
+func WriteInflux(w io.Writer, r *metrics.Registry,
+	tags map[string]string) error {
+
+	for _, line := range influxLines(r, tags, time.Now()) {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InfluxOptions configures an InfluxDB reporter. The zero value is
+// usable.
+type InfluxOptions struct {
+	// Database is the database written to. It may be empty if the
+	// server has a default, e.g. Telegraf's InfluxDB listener.
+	Database string
+
+	// RetentionPolicy is the retention policy written to. If empty,
+	// the database's default is used.
+	RetentionPolicy string
+
+	// Username and Password authenticate the writes, if set.
+	Username string
+	Password string
+
+	// Tags are added to every line.
+	Tags map[string]string
+
+	// Interval is the time between flushes. The default is 10 seconds.
+	Interval time.Duration
+
+	// BatchSize is the largest number of lines in a request.
+	// The default is 5000.
+	BatchSize int
+
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout limits the time taken by each request, so that a server
+	// which does not respond cannot hold up flushes. The default is 10
+	// seconds.
+	Timeout time.Duration
+
+	// ErrorLog logs errors while flushing in the background. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// Influx periodically pushes the metrics in a Registry to the /write
+// endpoint of an InfluxDB server, or of anything accepting its
+// protocol, in the form written by WriteInflux.
+type Influx struct {
+	registry *metrics.Registry
+	url      string
+	opts     InfluxOptions
+	loop     *loop
+}
+
+// NewInflux creates an InfluxDB reporter for a registry, writing to
+// the server at serverURL, e.g. http://localhost:8086, and starts
+// flushing at the configured interval. It returns an error if
+// serverURL is invalid.
+func NewInflux(r *metrics.Registry, serverURL string,
+	opts InfluxOptions) (*Influx, error) {
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("reporter: invalid InfluxDB URL %q", serverURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+	q := u.Query()
+	q.Set("precision", "ns")
+	if opts.Database != "" {
+		q.Set("db", opts.Database)
+	}
+	if opts.RetentionPolicy != "" {
+		q.Set("rp", opts.RetentionPolicy)
+	}
+	u.RawQuery = q.Encode()
+
+	if opts.Interval == 0 {
+		opts.Interval = influxDefaultInterval
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = influxDefaultBatchSize
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = influxDefaultTimeout
+	}
+
+	x := &Influx{
+		registry: r,
+		url:      u.String(),
+		opts:     opts,
+	}
+	x.loop = startLoop(opts.Interval, func(ctx context.Context) {
+		if err := x.flush(ctx); err != nil {
+			logf(x.opts.ErrorLog, "reporter: InfluxDB flush failed: %v", err)
+		}
+	})
+	return x, nil
+}
+
+// Flush sends the current values of all metrics in the registry, in
+// batches of at most BatchSize lines. It stops at the first failed
+// batch. It may be called concurrently with the background flushes.
+func (x *Influx) Flush() error {
+	return x.flush(context.Background())
+}
+
+// flush is Flush, with requests canceled when ctx is done.
+func (x *Influx) flush(ctx context.Context) error {
+	lines := influxLines(x.registry, x.opts.Tags, time.Now())
+	for len(lines) > 0 {
+		n := len(lines)
+		if n > x.opts.BatchSize {
+			n = x.opts.BatchSize
+		}
+		if err := x.post(ctx, lines[:n]); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+func (x *Influx) post(ctx context.Context, lines []string) error {
+	ctx, cancel := context.WithTimeout(ctx, x.opts.Timeout)
+	defer cancel()
+
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, "POST", x.url,
+		strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if x.opts.Username != "" || x.opts.Password != "" {
+		req.SetBasicAuth(x.opts.Username, x.opts.Password)
+	}
+
+	resp, err := x.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("reporter: InfluxDB write failed: %s: %s",
+			resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Stop stops flushing, canceling a flush in progress, and sends the
+// metrics a final time.
+func (x *Influx) Stop() error {
+	x.loop.Stop()
+	return x.Flush()
+}
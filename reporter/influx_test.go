@@ -0,0 +1,207 @@
+package reporter
+
+import (
+	"bytes"
+	"io"
+	"metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testInfluxFields returns the lines without their timestamps.
+func testInfluxFields(lines []string) []string {
+	fields := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l != "" {
+			fields = append(fields, l[:strings.LastIndex(l, " ")])
+		}
+	}
+	return fields
+}
+
+func TestWriteInflux(t *testing.T) {
+	r := testRegistryInit()
+	m := r.NewMeter(testType{}, "meter")
+	m.Set(3)
+
+	var b bytes.Buffer
+	if err := WriteInflux(&b, r, map[string]string{"host": "a b"}); err != nil {
+		t.Fatalf("WriteInflux failed: %v", err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	testContainsLines(t, testInfluxFields(lines), []string{
+		"reporter.testType.counter,host=a\\ b,registry=test value=5i",
+		"reporter.testType.gauge,host=a\\ b,registry=test value=-1.5",
+		"reporter.testType.requests,code=200,host=a\\ b,registry=test value=2i",
+	})
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "reporter.testType.distribution,") {
+			for _, f := range []string{"count=1,", "mean=7,", "p50=7,", "max=7 "} {
+				if !strings.Contains(l, f) {
+					t.Errorf("Field %q is missing from %q", f, l)
+				}
+			}
+		}
+		if strings.HasPrefix(l, "reporter.testType.meter,") {
+			for _, f := range []string{" value=3,", "value.1m=", "rate.1m="} {
+				if !strings.Contains(l, f) {
+					t.Errorf("Field %q is missing from %q", f, l)
+				}
+			}
+		}
+	}
+}
+
+func TestInfluxFlush(t *testing.T) {
+	var requests []string
+	var query string
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			lock.Lock()
+			defer lock.Unlock()
+			if req.URL.Path != "/write" {
+				t.Errorf("Wrong path, got %s expected /write", req.URL.Path)
+			}
+			query = req.URL.RawQuery
+			requests = append(requests, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	defer server.Close()
+
+	r := testRegistryInit()
+	x, err := NewInflux(r, server.URL, InfluxOptions{
+		Database:  "db",
+		Interval:  time.Hour,
+		BatchSize: 3,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer x.Stop()
+
+	if err := x.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	// counter, distribution, gauge and requests
+	if len(requests) != 2 {
+		t.Fatalf("Wrong number of requests, got %d expected 2", len(requests))
+	}
+	if n := strings.Count(requests[0], "\n"); n != 3 {
+		t.Errorf("Wrong lines in first batch, got %d expected 3", n)
+	}
+	if n := strings.Count(requests[1], "\n"); n != 1 {
+		t.Errorf("Wrong lines in second batch, got %d expected 1", n)
+	}
+	if query != "db=db&precision=ns" {
+		t.Errorf("Wrong query, got %q expected %q", query, "db=db&precision=ns")
+	}
+}
+
+func TestInfluxError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "database not found", http.StatusNotFound)
+		}))
+	defer server.Close()
+
+	x, err := NewInflux(metrics.NewRegistry("test"), server.URL,
+		InfluxOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer x.Stop()
+
+	// an empty registry sends nothing
+	if err := x.Flush(); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+
+	x.registry.NewCounter(testType{}, "counter")
+	err = x.Flush()
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("Wrong error, got %v expected the server's message", err)
+	}
+
+	if _, err := NewInflux(x.registry, "localhost:8086", InfluxOptions{}); err == nil {
+		t.Errorf("NewInflux accepted a URL with no scheme")
+	}
+}
+
+// testHangingServer returns a server whose first request hangs until
+// it is canceled by the client.
+func testHangingServer() (*httptest.Server, chan bool) {
+	var requests int32
+	started := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			// the server notices a canceled request once its body is read
+			io.Copy(io.Discard, req.Body)
+			if atomic.AddInt32(&requests, 1) == 1 {
+				close(started)
+				<-req.Context().Done()
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	return server, started
+}
+
+func TestInfluxTimeout(t *testing.T) {
+	server, _ := testHangingServer()
+	defer server.Close()
+
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "counter")
+	x, err := NewInflux(r, server.URL, InfluxOptions{
+		Interval: time.Hour,
+		Timeout:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer x.Stop()
+
+	start := time.Now()
+	if err := x.Flush(); err == nil {
+		t.Errorf("Flush succeeded with a request which timed out")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Wrong flush duration, got %v expected about 50ms", d)
+	}
+}
+
+func TestInfluxStopCancels(t *testing.T) {
+	server, started := testHangingServer()
+	defer server.Close()
+
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "counter")
+	x, err := NewInflux(r, server.URL, InfluxOptions{
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	<-started
+
+	// Stop cancels the background flush, then flushes a final time
+	start := time.Now()
+	if err := x.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Wrong stop duration, got %v expected no wait", d)
+	}
+}
@@ -0,0 +1,468 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"metrics"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	otlpDefaultInterval = 10 * time.Second
+	otlpDefaultTimeout  = 10 * time.Second
+)
+
+// OTLP aggregation temporality of cumulative sums.
+const otlpCumulative = 2
+
+// The OTLP/JSON encoding of metric data. 64-bit integers are encoded
+// as strings.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Unit        string       `json:"unit,omitempty"`
+	Sum         *otlpSum     `json:"sum,omitempty"`
+	Gauge       *otlpGauge   `json:"gauge,omitempty"`
+	Summary     *otlpSummary `json:"summary,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt,omitempty"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes        []otlpAttribute     `json:"attributes,omitempty"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string              `json:"timeUnixNano"`
+	Count             string              `json:"count"`
+	Sum               float64             `json:"sum"`
+	QuantileValues    []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpQuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func otlpAttributes(l map[string]string) []otlpAttribute {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]otlpAttribute, 0, len(l))
+	for _, name := range names {
+		attrs = append(attrs, otlpAttribute{name, otlpAttributeValue{l[name]}})
+	}
+	return attrs
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpUnit returns the UCUM unit of a Timer's durations.
+func otlpUnit(unit time.Duration) string {
+	switch unit {
+	case time.Nanosecond:
+		return "ns"
+	case time.Microsecond:
+		return "us"
+	case time.Millisecond:
+		return "ms"
+	case time.Second:
+		return "s"
+	case time.Minute:
+		return "min"
+	case time.Hour:
+		return "h"
+	}
+	return ""
+}
+
+// otlpBuilder collects the data points of a registry's metrics, with
+// the members of a labelled family as data points of a single metric.
+type otlpBuilder struct {
+	start   time.Time
+	now     time.Time
+	metrics map[string]*otlpMetric
+}
+
+func (b *otlpBuilder) metric(name, unit string) *otlpMetric {
+	m, ok := b.metrics[name]
+	if !ok {
+		m = &otlpMetric{Name: name, Unit: unit}
+		b.metrics[name] = m
+	}
+	return m
+}
+
+// addPoint adds a point. The data points of counters start at start,
+// or when the reporter was created if it is empty.
+func (b *otlpBuilder) addPoint(p point, start string) {
+	// JSON cannot encode NaNs and infinities
+	if !isFinite(p.value) {
+		return
+	}
+
+	m := b.metric(p.name, "")
+	dp := otlpNumberDataPoint{
+		Attributes:   otlpAttributes(p.labels),
+		TimeUnixNano: otlpTime(b.now),
+	}
+
+	if p.kind == counterPoint {
+		// counters can be decremented, so are not monotonic
+		if m.Sum == nil {
+			m.Sum = &otlpSum{AggregationTemporality: otlpCumulative}
+		}
+		dp.StartTimeUnixNano = start
+		if start == "" {
+			dp.StartTimeUnixNano = otlpTime(b.start)
+		}
+		dp.AsInt = strconv.FormatInt(int64(p.value), 10)
+		m.Sum.DataPoints = append(m.Sum.DataPoints, dp)
+		return
+	}
+
+	if m.Gauge == nil {
+		m.Gauge = &otlpGauge{}
+	}
+	v := p.value
+	dp.AsDouble = &v
+	m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+}
+
+// addSummary adds a summary of the values since start.
+func (b *otlpBuilder) addSummary(name, unit string, l metrics.Labels,
+	start time.Time, count uint64, mean float64,
+	quantiles, percentiles []float64) {
+
+	if !isFinite(mean) {
+		return
+	}
+	m := b.metric(name, unit)
+	if m.Summary == nil {
+		m.Summary = &otlpSummary{}
+	}
+	dp := otlpSummaryDataPoint{
+		Attributes:        otlpAttributes(l),
+		StartTimeUnixNano: otlpTime(start),
+		TimeUnixNano:      otlpTime(b.now),
+		Count:             strconv.FormatUint(count, 10),
+		Sum:               mean * float64(count),
+	}
+	for i, q := range quantiles {
+		if !isFinite(percentiles[i]) {
+			continue
+		}
+		dp.QuantileValues = append(dp.QuantileValues,
+			otlpQuantileValue{q, percentiles[i]})
+	}
+	m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+}
+
+// addDistribution adds a summary of the values within a Distribution's
+// window, which starts at the beginning of the window, or when the
+// reporter was created if that is later or the window is unlimited.
+func (b *otlpBuilder) addDistribution(name, unit string, l metrics.Labels,
+	s metrics.DistributionSnapshot, scale float64) {
+
+	quantiles := make([]float64, len(s.Percentiles))
+	percentiles := make([]float64, len(s.Percentiles))
+	for i, p := range s.Percentiles {
+		quantiles[i] = p.Quantile
+		percentiles[i] = p.Value / scale
+	}
+	start := b.start
+	if s.Window > 0 && b.now.Add(-s.Window).After(start) {
+		start = b.now.Add(-s.Window)
+	}
+	b.addSummary(name, unit, l, start, s.Count, s.Mean/scale, quantiles,
+		percentiles)
+}
+
+// add converts a metric. Counters are cumulative Sums from their start
+// times. Distributions and the durations of Timers are Summaries of the
+// values within their window, starting at the beginning of the window,
+// and Digests are Summaries starting when the reporter was created.
+// Other points are Gauges, named as described in the package
+// documentation.
+func (b *otlpBuilder) add(name string, l metrics.Labels, me metrics.Metric) {
+	switch m := me.(type) {
+	case *metrics.Counter:
+		b.addPoint(point{name, l, float64(m.Snapshot().Value), counterPoint},
+			otlpTime(m.Start()))
+
+	case *metrics.Distribution:
+		b.addDistribution(name, "", l, m.Snapshot(), 1)
+
+	case *metrics.Digest:
+		s := m.Snapshot()
+		b.addSummary(name, "", l, b.start, uint64(s.Count), s.Mean,
+			s.Quantiles, s.Percentiles)
+
+	case *metrics.Timer:
+		s := m.Snapshot()
+		unit := s.Unit
+		if unit == 0 {
+			unit = defaultTimerUnit
+		}
+		for _, p := range meterPoints(name, name+".count", l, s.Meter,
+			counterPoint) {
+			b.addPoint(p, "")
+		}
+		b.addDistribution(name, otlpUnit(unit), l, s.Distribution,
+			float64(unit))
+
+	default:
+		for _, p := range metricPoints(name, l, me) {
+			b.addPoint(p, "")
+		}
+	}
+}
+
+// otlpMetrics converts a registry's metrics, sorted by name.
+func otlpMetrics(r *metrics.Registry, start, now time.Time) []*otlpMetric {
+	b := &otlpBuilder{
+		start:   start,
+		now:     now,
+		metrics: make(map[string]*otlpMetric),
+	}
+	for fullname, metric := range r.ListMetrics() {
+		name, labels := r.LabelSet(fullname)
+		b.add(name, labels, metric)
+	}
+
+	ms := make([]*otlpMetric, 0, len(b.metrics))
+	for _, m := range b.metrics {
+		// metadata describes the metric named after the family, not
+		// the points derived from it such as a Meter's rates
+		md := r.Metadata(m.Name)
+		m.Description = md.Description
+		if m.Unit == "" {
+			m.Unit = md.Unit
+		}
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	return ms
+}
+
+// OTLPOptions configures an OTLP reporter. The zero value is usable.
+type OTLPOptions struct {
+	// ServiceName is the service.name attribute of the resource.
+	// The default is the registry's name.
+	ServiceName string
+
+	// Resource holds further attributes of the resource, for example
+	// service.version or host.name.
+	Resource map[string]string
+
+	// Headers are added to every request, for example to authenticate.
+	Headers map[string]string
+
+	// Interval is the time between flushes. The default is 10 seconds.
+	Interval time.Duration
+
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout limits the time taken by each request, so that a
+	// collector which does not respond cannot hold up flushes. The
+	// default is 10 seconds.
+	Timeout time.Duration
+
+	// ErrorLog logs errors while flushing in the background. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// OTLP periodically pushes the metrics in a Registry to an
+// OpenTelemetry collector, using the JSON encoding of OTLP/HTTP.
+//
+// Counters are sent as non-monotonic cumulative Sums, starting at
+// their start times, when they were created or last reset, so that
+// their sums stay correct when the reporter is restarted. The counts of
+// Meters and Timers, which have no start times, start when the reporter
+// was created. NaNs and infinities, which OTLP/JSON cannot encode, are
+// skipped. Distributions are sent as Summaries of the values within
+// their window, starting at the beginning of the window, and Digests
+// as Summaries of their values, with quantiles for their percentiles.
+// A Timer is sent as a Summary of its
+// durations, with its unit, alongside the Sum and Gauges of its Meter.
+// Other points are sent as Gauges. The members of a labelled family
+// are data points of one metric, with their labels as attributes.
+type OTLP struct {
+	registry *metrics.Registry
+	url      string
+	opts     OTLPOptions
+	start    time.Time
+	loop     *loop
+}
+
+// NewOTLP creates an OTLP reporter for a registry, sending to the
+// collector at endpoint, and starts flushing at the configured
+// interval. If endpoint has no path, e.g. http://localhost:4318,
+// metrics are sent to /v1/metrics. It returns an error if endpoint
+// is invalid.
+func NewOTLP(r *metrics.Registry, endpoint string,
+	opts OTLPOptions) (*OTLP, error) {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("reporter: invalid OTLP endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+
+	if opts.ServiceName == "" {
+		opts.ServiceName = r.Name()
+	}
+	if opts.Interval == 0 {
+		opts.Interval = otlpDefaultInterval
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = otlpDefaultTimeout
+	}
+
+	o := &OTLP{
+		registry: r,
+		url:      u.String(),
+		opts:     opts,
+		start:    time.Now(),
+	}
+	o.loop = startLoop(opts.Interval, func(ctx context.Context) {
+		if err := o.flush(ctx); err != nil {
+			logf(o.opts.ErrorLog, "reporter: OTLP flush failed: %v", err)
+		}
+	})
+	return o, nil
+}
+
+func (o *OTLP) request(now time.Time) otlpRequest {
+	resource := make(map[string]string, len(o.opts.Resource)+1)
+	for k, v := range o.opts.Resource {
+		resource[k] = v
+	}
+	resource["service.name"] = o.opts.ServiceName
+
+	return otlpRequest{[]otlpResourceMetrics{{
+		Resource: otlpResource{otlpAttributes(resource)},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{"metrics"},
+			Metrics: otlpMetrics(o.registry, o.start, now),
+		}},
+	}}}
+}
+
+// Flush sends the current values of all metrics in the registry. It
+// may be called concurrently with the background flushes.
+func (o *OTLP) Flush() error {
+	return o.flush(context.Background())
+}
+
+// flush is Flush, with the request canceled when ctx is done.
+func (o *OTLP) flush(ctx context.Context) error {
+	body, err := json.Marshal(o.request(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", o.url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("reporter: OTLP export failed: %s: %s",
+			resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Stop stops flushing, canceling a flush in progress, and sends the
+// metrics a final time.
+func (o *OTLP) Stop() error {
+	o.loop.Stop()
+	return o.Flush()
+}
@@ -0,0 +1,277 @@
+package reporter
+
+import (
+	"encoding/json"
+	"math"
+	"metrics"
+	"metrics/metricstest"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCollector is an OTLP/HTTP collector which records the requests
+// received.
+type testCollector struct {
+	server   *httptest.Server
+	requests []otlpRequest
+	headers  []http.Header
+	lock     sync.Mutex
+}
+
+func testCollectorInit(t *testing.T) *testCollector {
+	c := &testCollector{}
+	c.server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/v1/metrics" {
+				http.NotFound(w, req)
+				return
+			}
+			var r otlpRequest
+			if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+				t.Errorf("Could not decode request: %v", err)
+			}
+			c.lock.Lock()
+			c.requests = append(c.requests, r)
+			c.headers = append(c.headers, req.Header)
+			c.lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}))
+	return c
+}
+
+func testOTLPMetrics(r otlpRequest) map[string]*otlpMetric {
+	ms := make(map[string]*otlpMetric)
+	for _, m := range r.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		ms[m.Name] = m
+	}
+	return ms
+}
+
+func TestOTLPFlush(t *testing.T) {
+	c := testCollectorInit(t)
+	defer c.server.Close()
+
+	r := testRegistryInit()
+	v := r.NewCounterVec(testType{}, "responses")
+	v.WithLabels(metrics.Labels{"code": "200"}).Inc(2)
+	v.WithLabels(metrics.Labels{"code": "500"}).Inc(1)
+	r.SetMetadata(testType{}, "responses", metrics.Metadata{
+		Description: "Responses sent", Unit: "{response}"})
+	timer := r.NewTimer(testType{}, "timer")
+	timer.Update(20 * time.Millisecond)
+
+	o, err := NewOTLP(r, c.server.URL, OTLPOptions{
+		Resource: map[string]string{"host.name": "a"},
+		Headers:  map[string]string{"Authorization": "Bearer x"},
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer o.Stop()
+
+	if err := o.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.requests) != 1 {
+		t.Fatalf("Wrong number of requests, got %d expected 1", len(c.requests))
+	}
+	if h := c.headers[0].Get("Authorization"); h != "Bearer x" {
+		t.Errorf("Wrong Authorization header, got %q expected %q", h, "Bearer x")
+	}
+
+	attrs := c.requests[0].ResourceMetrics[0].Resource.Attributes
+	expectedAttrs := []otlpAttribute{
+		{"host.name", otlpAttributeValue{"a"}},
+		{"service.name", otlpAttributeValue{"test"}},
+	}
+	if len(attrs) != 2 || attrs[0] != expectedAttrs[0] || attrs[1] != expectedAttrs[1] {
+		t.Errorf("Wrong resource attributes, got %v expected %v",
+			attrs, expectedAttrs)
+	}
+
+	ms := testOTLPMetrics(c.requests[0])
+
+	counter := ms["reporter.testType.counter"]
+	if counter == nil || counter.Sum == nil || counter.Sum.IsMonotonic ||
+		counter.Sum.AggregationTemporality != otlpCumulative {
+		t.Fatalf("Wrong counter, got %+v expected a cumulative Sum", counter)
+	}
+	if v := counter.Sum.DataPoints[0].AsInt; v != "5" {
+		t.Errorf("Wrong counter value, got %s expected 5", v)
+	}
+
+	responses := ms["reporter.testType.responses"]
+	if responses == nil || responses.Sum == nil ||
+		len(responses.Sum.DataPoints) != 2 {
+		t.Fatalf("Wrong responses, got %+v expected a Sum with 2 points",
+			responses)
+	}
+	if responses.Description != "Responses sent" ||
+		responses.Unit != "{response}" {
+		t.Errorf("Wrong metadata, got %q %q expected %q %q",
+			responses.Description, responses.Unit,
+			"Responses sent", "{response}")
+	}
+	for _, dp := range responses.Sum.DataPoints {
+		if len(dp.Attributes) != 1 || dp.Attributes[0].Key != "code" {
+			t.Errorf("Wrong attributes, got %v expected code", dp.Attributes)
+		}
+	}
+
+	gauge := ms["reporter.testType.gauge"]
+	if gauge == nil || gauge.Gauge == nil ||
+		*gauge.Gauge.DataPoints[0].AsDouble != -1.5 {
+		t.Errorf("Wrong gauge, got %+v expected a Gauge of -1.5", gauge)
+	}
+
+	dist := ms["reporter.testType.distribution"]
+	if dist == nil || dist.Summary == nil {
+		t.Fatalf("Wrong distribution, got %+v expected a Summary", dist)
+	}
+	dp := dist.Summary.DataPoints[0]
+	if dp.Count != "1" || dp.Sum != 7 ||
+		len(dp.QuantileValues) != len(metrics.DistributionPercentiles) {
+		t.Errorf("Wrong distribution point, got %+v", dp)
+	}
+
+	tm := ms["reporter.testType.timer"]
+	if tm == nil || tm.Summary == nil || tm.Unit != "ms" ||
+		tm.Summary.DataPoints[0].Sum != 20 {
+		t.Errorf("Wrong timer, got %+v expected a Summary of 20ms", tm)
+	}
+	if tc := ms["reporter.testType.timer.count"]; tc == nil || tc.Sum == nil {
+		t.Errorf("Wrong timer count, got %+v expected a Sum", tc)
+	}
+	if tr := ms["reporter.testType.timer.rate.1m"]; tr == nil || tr.Gauge == nil {
+		t.Errorf("Wrong timer rate, got %+v expected a Gauge", tr)
+	}
+}
+
+func TestOTLPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "bad data", http.StatusBadRequest)
+		}))
+	defer server.Close()
+
+	o, err := NewOTLP(metrics.NewRegistry("test"), server.URL,
+		OTLPOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer o.Stop()
+
+	if err := o.Flush(); err == nil {
+		t.Errorf("Flush succeeded with a failed request")
+	}
+	if _, err := NewOTLP(o.registry, "localhost:4318", OTLPOptions{}); err == nil {
+		t.Errorf("NewOTLP accepted an endpoint with no scheme")
+	}
+}
+
+func TestOTLPTimeout(t *testing.T) {
+	server, _ := testHangingServer()
+	defer server.Close()
+
+	o, err := NewOTLP(metrics.NewRegistry("test"), server.URL, OTLPOptions{
+		Interval: time.Hour,
+		Timeout:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer o.Stop()
+
+	start := time.Now()
+	if err := o.Flush(); err == nil {
+		t.Errorf("Flush succeeded with a request which timed out")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Wrong flush duration, got %v expected about 50ms", d)
+	}
+}
+
+func TestOTLPNonFinite(t *testing.T) {
+	c := testCollectorInit(t)
+	defer c.server.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := metrics.NewRegistryClock("test", metricstest.NewClock(start))
+	r.NewFloatGauge(testType{}, "nan").Set(math.NaN())
+	r.NewFloatGauge(testType{}, "inf").Set(math.Inf(1))
+	r.NewFloatGauge(testType{}, "finite").Set(2.5)
+	r.NewCounter(testType{}, "counter").Inc(3)
+
+	o, err := NewOTLP(r, c.server.URL, OTLPOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer o.Stop()
+
+	if err := o.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ms := testOTLPMetrics(c.requests[0])
+	for _, name := range []string{"nan", "inf"} {
+		if m := ms["reporter.testType."+name]; m != nil {
+			t.Errorf("Non-finite gauge %s was sent: %+v", name, m)
+		}
+	}
+	if m := ms["reporter.testType.finite"]; m == nil || m.Gauge == nil {
+		t.Errorf("Wrong finite gauge, got %+v expected a Gauge", m)
+	}
+
+	// a counter's points start at its own start time, not the reporter's
+	counter := ms["reporter.testType.counter"]
+	if counter == nil || counter.Sum == nil {
+		t.Fatalf("Wrong counter, got %+v expected a Sum", counter)
+	}
+	if s := counter.Sum.DataPoints[0].StartTimeUnixNano; s != otlpTime(start) {
+		t.Errorf("Wrong counter start time, got %s expected %s",
+			s, otlpTime(start))
+	}
+}
+
+func TestOTLPSummaryStart(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	r.NewDistribution(testType{}, "window").SetWindow(time.Minute)
+	r.NewDistribution(testType{}, "long").SetWindow(time.Hour)
+	r.NewDistribution(testType{}, "unlimited").SetWindow(0)
+	r.NewDigest(testType{}, "digest", 100)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(10 * time.Minute)
+	ms := make(map[string]*otlpMetric)
+	for _, m := range otlpMetrics(r, start, now) {
+		ms[m.Name] = m
+	}
+
+	// the count and sum of a Distribution cover only its window
+	for name, expected := range map[string]time.Time{
+		"window":    now.Add(-time.Minute),
+		"long":      start,
+		"unlimited": start,
+		"digest":    start,
+	} {
+		m := ms["reporter.testType."+name]
+		if m == nil || m.Summary == nil {
+			t.Errorf("Wrong %s, got %+v expected a Summary", name, m)
+			continue
+		}
+		if s := m.Summary.DataPoints[0].StartTimeUnixNano; s != otlpTime(expected) {
+			t.Errorf("Wrong start time of %s, got %s expected %s",
+				name, s, otlpTime(expected))
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Reporter periodically pushes the metrics in a metrics.Registry
+// to external monitoring systems.
+//
+// Each metric is flattened into one or more numeric points, named by
+// the metric's name followed by dot-separated suffixes:
+//
+//	Counter: the value, with no suffix.
+//	Gauge: the value, if it is numeric.
+//	IntGauge and FloatGauge: the value, with no suffix.
+//	Meter: the value, .value.1m, .value.5m and .value.15m for its
+//	averages, and .rate, .rate.1m, .rate.5m and .rate.15m for the
+//	instantaneous and averaged rate of change.
+//	Distribution: .count, .mean, .stddev, and .min, .p25, .p50, .p75,
+//	.p95, .p99, .p999 and .max for DistributionPercentiles, or the
+//	Distribution's own percentiles.
+//	Digest: .count, .mean and the same percentiles as a Distribution.
+//	Timer: .count and the rates of its Meter, and .mean, .stddev and
+//	the percentiles of its Distribution, in the Timer's unit, or in
+//	milliseconds if it has none.
+//
+// Members of a labelled family are named by the family's name, and
+// keep their labels, which each reporter encodes in its own way.
+// Graphite and StatsD replace the / after the prefix of a sub-registry
+// by a dot, so that it is a level of their dotted paths.
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pointKind describes how a point's value changes over time.
+type pointKind int
+
+const (
+	// a gauge is an instantaneous value
+	gaugePoint pointKind = iota
+	// a counter is a running total, which only goes down when reset
+	counterPoint
+)
+
+// point is a single numeric value of a metric.
+type point struct {
+	name   string
+	labels metrics.Labels
+	value  float64
+	kind   pointKind
+}
+
+// isFinite reports whether v is neither a NaN nor an infinity, which
+// most formats cannot encode.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+const defaultTimerUnit = time.Millisecond
+
+// percentileName names a percentile, e.g. p50 for 0.5 and p999 for
+// 0.999.
+func percentileName(p float64) string {
+	switch p {
+	case 0:
+		return "min"
+	case 1:
+		return "max"
+	}
+	s := strconv.FormatFloat(p*100, 'f', -1, 64)
+	return "p" + strings.Replace(s, ".", "", 1)
+}
+
+// durationName formats a time constant, e.g. 1m or 30s.
+func durationName(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return strings.Replace(d.String(), ".", "_", -1)
+}
+
+func derivativeName(order int) string {
+	switch order {
+	case 0:
+		return "value"
+	case 1:
+		return "rate"
+	}
+	return fmt.Sprintf("deriv%d", order)
+}
+
+// meterPoints names the Meter's value valueName, and its
+// derivatives by suffixes of name.
+func meterPoints(name, valueName string, l metrics.Labels,
+	s metrics.MeterSnapshot, kind pointKind) []point {
+
+	points := []point{{valueName, l, float64(s.Value), kind}}
+	for order, d := range s.Derivatives {
+		prefix := name + "." + derivativeName(order)
+		// the instantaneous value is already included
+		if order != 0 {
+			points = append(points, point{prefix, l, d[0], gaugePoint})
+		}
+		for i, tc := range s.TimeConstants {
+			points = append(points, point{prefix + "." + durationName(tc),
+				l, d[i+1], gaugePoint})
+		}
+	}
+	return points
+}
+
+func distributionPoints(name string, l metrics.Labels,
+	s metrics.DistributionSnapshot, unit float64) []point {
+
+	points := []point{
+		{name + ".count", l, float64(s.Count), gaugePoint},
+		{name + ".mean", l, s.Mean / unit, gaugePoint},
+		{name + ".stddev", l, s.StandardDeviation / unit, gaugePoint},
+	}
+	for _, p := range s.Percentiles {
+		points = append(points, point{name + "." + percentileName(p.Quantile),
+			l, p.Value / unit, gaugePoint})
+	}
+	return points
+}
+
+// metricPoints flattens a metric into points, as described in the
+// package documentation. It returns nil for gauges that are not numeric
+// or whose values are NaNs or infinities, which the formats of StatsD
+// and Graphite cannot encode.
+func metricPoints(name string, l metrics.Labels, me metrics.Metric) []point {
+	switch m := me.(type) {
+	case *metrics.Counter:
+		return []point{{name, l, float64(m.Snapshot().Value), counterPoint}}
+
+	case *metrics.Gauge:
+		s := m.Snapshot()
+		if s.Value == nil {
+			return nil
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(s.Value.String()), 64)
+		if err != nil || !isFinite(v) {
+			return nil
+		}
+		return []point{{name, l, v, gaugePoint}}
+
+	case *metrics.IntGauge:
+		return []point{{name, l, float64(m.Value()), gaugePoint}}
+
+	case *metrics.FloatGauge:
+		v := m.Value()
+		if !isFinite(v) {
+			return nil
+		}
+		return []point{{name, l, v, gaugePoint}}
+
+	case *metrics.Meter:
+		return meterPoints(name, name, l, m.Snapshot(), gaugePoint)
+
+	case *metrics.Distribution:
+		return distributionPoints(name, l, m.Snapshot(), 1)
+
+	case *metrics.Digest:
+		s := m.Snapshot()
+		points := []point{
+			{name + ".count", l, s.Count, gaugePoint},
+			{name + ".mean", l, s.Mean, gaugePoint},
+		}
+		for i, q := range s.Quantiles {
+			points = append(points, point{name + "." + percentileName(q),
+				l, s.Percentiles[i], gaugePoint})
+		}
+		return points
+
+	case *metrics.Timer:
+		s := m.Snapshot()
+		unit := s.Unit
+		if unit == 0 {
+			unit = defaultTimerUnit
+		}
+		points := meterPoints(name, name+".count", l, s.Meter, counterPoint)
+		// the distribution's count is the number of events in its window
+		dist := distributionPoints(name, l, s.Distribution, float64(unit))
+		return append(points, dist[1:]...)
+	}
+	return nil
+}
+
+// registryPoints flattens every metric in a registry into points,
+// sorted by name.
+func registryPoints(r *metrics.Registry) []point {
+	var points []point
+	for fullname, metric := range r.ListMetrics() {
+		name, labels := r.LabelSet(fullname)
+		points = append(points, metricPoints(name, labels, metric)...)
+	}
+	sort.Sort(pointsByName(points))
+	return points
+}
+
+// labelSuffix returns the values of a point's labels, ordered by label
+// name, as dot-separated path components, for systems without labels.
+func labelSuffix(l metrics.Labels) string {
+	var suffix string
+	for _, name := range l.Names() {
+		suffix += "." + labelPathReplacer.Replace(l[name])
+	}
+	return suffix
+}
+
+// labelPathReplacer replaces the separators of dotted paths, and of
+// the directories in which Carbon stores them, in label values.
+var labelPathReplacer = strings.NewReplacer(".", "_", "/", "_")
+
+// dottedName replaces the slashes which end the prefixes of
+// sub-registries in a metric's name by dots, so that they are levels
+// of dotted paths like the rest of the name.
+func dottedName(name string) string {
+	return strings.Replace(name, "/", ".", -1)
+}
+
+type pointsByName []point
+
+func (p pointsByName) Len() int      { return len(p) }
+func (p pointsByName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p pointsByName) Less(i, j int) bool {
+	if p[i].name != p[j].name {
+		return p[i].name < p[j].name
+	}
+	return p[i].labels.String() < p[j].labels.String()
+}
+
+// loop calls a function at an interval in its own goroutine,
+// until it is stopped. The function is given a context which is
+// canceled when the loop is stopped.
+type loop struct {
+	stop   chan bool
+	done   chan bool
+	cancel context.CancelFunc
+}
+
+func startLoop(interval time.Duration, f func(ctx context.Context)) *loop {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &loop{
+		stop:   make(chan bool),
+		done:   make(chan bool),
+		cancel: cancel,
+	}
+	go func() {
+		defer close(l.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				f(ctx)
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// Stop stops the loop, cancels a call in progress, and waits for it to
+// return.
+func (l *loop) Stop() {
+	l.cancel()
+	close(l.stop)
+	<-l.done
+}
+
+func logf(logger *log.Logger, format string, args ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
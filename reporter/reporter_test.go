@@ -0,0 +1,26 @@
+package reporter
+
+import (
+	"math"
+	"metrics"
+	"testing"
+)
+
+func TestMetricPointsNonFinite(t *testing.T) {
+	r := metrics.NewRegistry("test")
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		g := r.NewFloatGauge(testType{}, "float")
+		g.Set(v)
+		if points := metricPoints("float", nil, g); points != nil {
+			t.Errorf("Wrong points for %v, got %v expected none", v, points)
+		}
+		r.Unregister(testType{}, "float")
+	}
+
+	g := r.NewGauge(testType{}, "gauge")
+	g.SetFunction(func() metrics.Gaugable { return testGaugable(math.NaN()) })
+	g.Update()
+	if points := metricPoints("gauge", nil, g); points != nil {
+		t.Errorf("Wrong points for a NaN gauge, got %v expected none", points)
+	}
+}
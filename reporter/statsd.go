@@ -0,0 +1,209 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"metrics"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statsDDefaultInterval      = 10 * time.Second
+	statsDDefaultMaxPacketSize = 1432
+)
+
+// StatsDOptions configures a StatsD reporter. The zero value is usable.
+type StatsDOptions struct {
+	// Prefix is prepended, followed by a dot, to every metric name.
+	Prefix string
+
+	// Interval is the time between flushes. The default is 10 seconds.
+	Interval time.Duration
+
+	// MaxPacketSize is the largest UDP payload sent, in bytes. Lines
+	// are batched into packets up to this size. The default of 1432
+	// bytes fits in the MTU of most networks.
+	MaxPacketSize int
+
+	// DogStatsD enables the DogStatsD tag extension. Tags and the
+	// labels of labelled metrics are sent as tags. Otherwise, Tags
+	// are ignored, and label values are appended to metric names.
+	DogStatsD bool
+
+	// Tags are added to every metric if DogStatsD is set.
+	Tags map[string]string
+
+	// ErrorLog logs errors while flushing in the background. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// StatsD periodically pushes the metrics in a Registry to a StatsD
+// server over UDP. Counters are sent as StatsD counters, with the
+// change since the previous flush, and all other points are sent as
+// StatsD gauges. Points are named as described in the package
+// documentation.
+type StatsD struct {
+	registry *metrics.Registry
+	conn     net.Conn
+	opts     StatsDOptions
+	previous map[string]float64
+	loop     *loop
+	lock     sync.Mutex
+}
+
+// NewStatsD creates a StatsD reporter for a registry, sending to the
+// server at addr, and starts flushing at the configured interval.
+func NewStatsD(r *metrics.Registry, addr string,
+	opts StatsDOptions) (*StatsD, error) {
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = statsDDefaultInterval
+	}
+	if opts.MaxPacketSize == 0 {
+		opts.MaxPacketSize = statsDDefaultMaxPacketSize
+	}
+
+	s := &StatsD{
+		registry: r,
+		conn:     conn,
+		opts:     opts,
+		previous: make(map[string]float64),
+	}
+	s.loop = startLoop(opts.Interval, func(context.Context) {
+		if err := s.Flush(); err != nil {
+			logf(s.opts.ErrorLog, "reporter: StatsD flush failed: %v", err)
+		}
+	})
+	return s, nil
+}
+
+// statsDReplacer replaces characters with special meaning in the
+// StatsD protocol.
+var statsDReplacer = strings.NewReplacer(
+	":", "_", "|", "_", "@", "_", "#", "_", ",", "_",
+	" ", "_", "\n", "_",
+)
+
+func (s *StatsD) name(p point) string {
+	name := dottedName(p.name)
+	if s.opts.Prefix != "" {
+		name = s.opts.Prefix + "." + name
+	}
+	if !s.opts.DogStatsD {
+		name += labelSuffix(p.labels)
+	}
+	return statsDReplacer.Replace(name)
+}
+
+func (s *StatsD) tags(p point) string {
+	if !s.opts.DogStatsD || len(s.opts.Tags)+len(p.labels) == 0 {
+		return ""
+	}
+
+	tags := make(metrics.Labels, len(s.opts.Tags)+len(p.labels))
+	for name, value := range s.opts.Tags {
+		tags[name] = value
+	}
+	for name, value := range p.labels {
+		tags[name] = value
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for _, name := range tags.Names() {
+		pairs = append(pairs, statsDReplacer.Replace(name)+":"+
+			statsDReplacer.Replace(tags[name]))
+	}
+	return "|#" + strings.Join(pairs, ",")
+}
+
+// lines returns the StatsD lines for a point. Counters are sent as
+// the change since the previous flush, and are omitted if unchanged.
+// Their values are recorded in current, which replaces the previous
+// values after the flush, so that removed metrics are forgotten.
+func (s *StatsD) lines(p point, current map[string]float64) []string {
+	name, tags := s.name(p), s.tags(p)
+	value := p.value
+
+	if p.kind == counterPoint {
+		key := name + tags
+		prev, ok := s.previous[key]
+		current[key] = value
+		if ok {
+			value -= prev
+			if value == 0 {
+				return nil
+			}
+		}
+		return []string{name + ":" + formatStatsD(value) + "|c" + tags}
+	}
+
+	line := name + ":" + formatStatsD(value) + "|g" + tags
+	if value < 0 {
+		// a signed gauge value is a change to the gauge, so set it
+		// to zero first
+		return []string{name + ":0|g" + tags, line}
+	}
+	return []string{line}
+}
+
+func formatStatsD(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Flush sends the current values of all metrics in the registry.
+func (s *StatsD) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var packet bytes.Buffer
+	var err error
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, werr := s.conn.Write(packet.Bytes()); werr != nil && err == nil {
+			err = werr
+		}
+		packet.Reset()
+	}
+
+	current := make(map[string]float64, len(s.previous))
+	for _, p := range registryPoints(s.registry) {
+		for _, line := range s.lines(p, current) {
+			if packet.Len() != 0 &&
+				packet.Len()+1+len(line) > s.opts.MaxPacketSize {
+				send()
+			}
+			if packet.Len() != 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	send()
+	s.previous = current
+
+	return err
+}
+
+// Stop stops flushing, sends the metrics a final time, and closes the
+// connection.
+func (s *StatsD) Stop() error {
+	s.loop.Stop()
+	err := s.Flush()
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package reporter
+
+import (
+	"fmt"
+	"metrics"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testGaugable float64
+
+func (g testGaugable) String() string {
+	return fmt.Sprint(float64(g))
+}
+
+type testType struct{}
+
+func testRegistryInit() *metrics.Registry {
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "counter").Inc(5)
+	g := r.NewGauge(testType{}, "gauge")
+	g.SetFunction(func() metrics.Gaugable { return testGaugable(-1.5) })
+	g.Update()
+	v := r.NewCounterVec(testType{}, "requests")
+	v.WithLabels(metrics.Labels{"code": "200"}).Inc(2)
+	r.NewDistribution(testType{}, "distribution").Add(7)
+	return r
+}
+
+func testStatsDListen(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	return conn
+}
+
+// testStatsDRead returns the packets received until none arrive
+// for a short time.
+func testStatsDRead(conn net.PacketConn) []string {
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func testContainsLines(t *testing.T, lines []string, expected []string) {
+	set := make(map[string]bool)
+	for _, l := range lines {
+		set[l] = true
+	}
+	for _, e := range expected {
+		if !set[e] {
+			t.Errorf("Line %q was not sent, got %q", e, lines)
+		}
+	}
+}
+
+func TestStatsDFlush(t *testing.T) {
+	conn := testStatsDListen(t)
+	defer conn.Close()
+
+	r := testRegistryInit()
+	s, err := NewStatsD(r, conn.LocalAddr().String(), StatsDOptions{
+		Prefix:   "app",
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer s.Stop()
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := strings.Split(strings.Join(testStatsDRead(conn), "\n"), "\n")
+	testContainsLines(t, lines, []string{
+		"app.reporter.testType.counter:5|c",
+		"app.reporter.testType.gauge:0|g",
+		"app.reporter.testType.gauge:-1.5|g",
+		"app.reporter.testType.requests.200:2|c",
+		"app.reporter.testType.distribution.count:1|g",
+		"app.reporter.testType.distribution.p50:7|g",
+	})
+
+	r.FindS("reporter.testType.counter").(*metrics.Counter).Inc(3)
+	s.Flush()
+	lines = strings.Split(strings.Join(testStatsDRead(conn), "\n"), "\n")
+	testContainsLines(t, lines, []string{"app.reporter.testType.counter:3|c"})
+	for _, l := range lines {
+		if strings.HasPrefix(l, "app.reporter.testType.requests") {
+			t.Errorf("Unchanged counter was sent: %q", l)
+		}
+	}
+}
+
+func TestStatsDDogStatsD(t *testing.T) {
+	conn := testStatsDListen(t)
+	defer conn.Close()
+
+	s, err := NewStatsD(testRegistryInit(), conn.LocalAddr().String(),
+		StatsDOptions{
+			Interval:  time.Hour,
+			DogStatsD: true,
+			Tags:      map[string]string{"host": "a:b"},
+		})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer s.Stop()
+
+	s.Flush()
+	lines := strings.Split(strings.Join(testStatsDRead(conn), "\n"), "\n")
+	testContainsLines(t, lines, []string{
+		"reporter.testType.counter:5|c|#host:a_b",
+		"reporter.testType.requests:2|c|#code:200,host:a_b",
+	})
+}
+
+func TestStatsDSub(t *testing.T) {
+	conn := testStatsDListen(t)
+	defer conn.Close()
+
+	r := metrics.NewRegistry("test")
+	r.Sub("tenant").NewCounter(testType{}, "counter").Inc(5)
+	s, err := NewStatsD(r, conn.LocalAddr().String(),
+		StatsDOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer s.Stop()
+
+	s.Flush()
+	lines := strings.Split(strings.Join(testStatsDRead(conn), "\n"), "\n")
+	testContainsLines(t, lines, []string{"tenant.reporter.testType.counter:5|c"})
+}
+
+func TestStatsDMaxPacketSize(t *testing.T) {
+	conn := testStatsDListen(t)
+	defer conn.Close()
+
+	s, err := NewStatsD(testRegistryInit(), conn.LocalAddr().String(),
+		StatsDOptions{Interval: time.Hour, MaxPacketSize: 100})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer s.Stop()
+
+	s.Flush()
+	packets := testStatsDRead(conn)
+	if len(packets) < 2 {
+		t.Errorf("Lines were not split into packets, got %d packets",
+			len(packets))
+	}
+	for _, p := range packets {
+		if len(p) > 100 {
+			t.Errorf("Packet of %d bytes exceeds maximum size", len(p))
+		}
+	}
+}
+
+func TestStatsDUnregistered(t *testing.T) {
+	conn := testStatsDListen(t)
+	defer conn.Close()
+
+	r := metrics.NewRegistry("test")
+	r.NewCounter(testType{}, "kept").Inc(1)
+	r.NewCounter(testType{}, "removed").Inc(5)
+	s, err := NewStatsD(r, conn.LocalAddr().String(),
+		StatsDOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Could not create reporter: %v", err)
+	}
+	defer s.Stop()
+
+	s.Flush()
+	testStatsDRead(conn)
+
+	// the previous value of a removed counter is forgotten, so that it
+	// is sent in full if registered again
+	r.Unregister(testType{}, "removed")
+	s.Flush()
+	if len(s.previous) != 1 {
+		t.Errorf("Wrong previous values, got %v expected only the kept counter",
+			s.previous)
+	}
+	r.NewCounter(testType{}, "removed").Inc(2)
+	s.Flush()
+	lines := strings.Split(strings.Join(testStatsDRead(conn), "\n"), "\n")
+	testContainsLines(t, lines, []string{"reporter.testType.removed:2|c"})
+}
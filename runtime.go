@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// runtimePauseWindow is the window of the GC pause Distribution.
+const runtimePauseWindow = 10 * time.Minute
+
+// RuntimeCollector registers metrics describing the Go runtime and
+// the process, and updates them from runtime.MemStats and, on Linux,
+// /proc/self. Its metrics are named after the RuntimeCollector type,
+// for example metrics.RuntimeCollector.goroutines:
+//
+//	goroutines, heap_alloc, heap_sys, heap_idle, heap_inuse,
+//	heap_released, heap_objects, stack_inuse, sys, next_gc: IntGauges
+//	gc, mallocs, frees, total_alloc, cgo_calls: Meters of the running
+//	totals, whose rates are per second
+//	gc_pause: a Distribution of GC pauses, in nanoseconds
+//	threads, rss, open_fds, cpu_user, cpu_system: IntGauges and Meters
+//	of the process, where cpu_user and cpu_system are in milliseconds
+//
+// The process metrics, threads, rss, open_fds, cpu_user and cpu_system,
+// are only registered on Linux.
+type RuntimeCollector struct {
+	gauges   []runtimeGauge
+	gc       *Meter
+	mallocs  *Meter
+	frees    *Meter
+	alloc    *Meter
+	cgoCalls *Meter
+	pauses   *Distribution
+
+	process *runtimeProcess
+	// names are those of the registered metrics
+	names []string
+
+	memStats    runtime.MemStats
+	goroutines  int
+	lastNumGC   uint32
+	initialized bool
+
+	stop chan bool
+	done chan bool
+	lock sync.Mutex
+}
+
+// runtimeGauge is an IntGauge set by a RuntimeCollector's Update.
+// Its value is read while the RuntimeCollector is locked, so it is
+// not set as the IntGauge's function.
+type runtimeGauge struct {
+	gauge *IntGauge
+	value func() int64
+}
+
+// NewRuntimeCollector registers the runtime metrics with a registry,
+// updates them, and starts updating them at an interval, unless
+// interval is 0. It returns nil if any of the metrics already exist,
+// in which case none of them is left registered.
+func NewRuntimeCollector(r *Registry,
+	interval time.Duration) *RuntimeCollector {
+
+	c := &RuntimeCollector{}
+	tyep := c
+	if !c.register(r, tyep) {
+		for _, name := range c.names {
+			r.Unregister(tyep, name)
+		}
+		return nil
+	}
+
+	c.Update()
+
+	if interval > 0 {
+		c.stop = make(chan bool)
+		c.done = make(chan bool)
+		go c.run(interval)
+	}
+	return c
+}
+
+// register registers the metrics with a registry, adding their names
+// to c.names. It returns false at the first metric which exists.
+func (c *RuntimeCollector) register(r *Registry, tyep interface{}) bool {
+	newMeter := func(name string) *Meter {
+		m := r.NewMeter(tyep, name)
+		if m != nil {
+			c.names = append(c.names, name)
+		}
+		return m
+	}
+
+	values := map[string]func() int64{
+		"goroutines":    func() int64 { return int64(c.goroutines) },
+		"heap_alloc":    func() int64 { return int64(c.memStats.HeapAlloc) },
+		"heap_sys":      func() int64 { return int64(c.memStats.HeapSys) },
+		"heap_idle":     func() int64 { return int64(c.memStats.HeapIdle) },
+		"heap_inuse":    func() int64 { return int64(c.memStats.HeapInuse) },
+		"heap_released": func() int64 { return int64(c.memStats.HeapReleased) },
+		"heap_objects":  func() int64 { return int64(c.memStats.HeapObjects) },
+		"stack_inuse":   func() int64 { return int64(c.memStats.StackInuse) },
+		"sys":           func() int64 { return int64(c.memStats.Sys) },
+		"next_gc":       func() int64 { return int64(c.memStats.NextGC) },
+	}
+	if !c.newGauges(r, tyep, values) {
+		return false
+	}
+
+	c.gc = newMeter("gc")
+	c.mallocs = newMeter("mallocs")
+	c.frees = newMeter("frees")
+	c.alloc = newMeter("total_alloc")
+	c.cgoCalls = newMeter("cgo_calls")
+	if c.gc == nil || c.mallocs == nil || c.frees == nil ||
+		c.alloc == nil || c.cgoCalls == nil {
+		return false
+	}
+	if c.pauses = r.NewDistribution(tyep, "gc_pause"); c.pauses == nil {
+		return false
+	}
+	c.names = append(c.names, "gc_pause")
+	c.pauses.SetWindow(runtimePauseWindow)
+
+	if runtimeProcessSupported {
+		c.process = &runtimeProcess{}
+		if !c.newGauges(r, tyep, map[string]func() int64{
+			"threads":  func() int64 { return c.process.threads },
+			"rss":      func() int64 { return c.process.rss },
+			"open_fds": func() int64 { return c.process.openFDs },
+		}) {
+			return false
+		}
+		c.process.cpuUser = newMeter("cpu_user")
+		c.process.cpuSystem = newMeter("cpu_system")
+		if c.process.cpuUser == nil || c.process.cpuSystem == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *RuntimeCollector) newGauges(r *Registry, tyep interface{},
+	values map[string]func() int64) bool {
+
+	for name, value := range values {
+		g := r.NewIntGauge(tyep, name)
+		if g == nil {
+			return false
+		}
+		c.gauges = append(c.gauges, runtimeGauge{g, value})
+		c.names = append(c.names, name)
+	}
+	return true
+}
+
+func (c *RuntimeCollector) run(interval time.Duration) {
+	defer close(c.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.Update()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Update reads the runtime and process statistics and updates the
+// metrics. runtime.ReadMemStats briefly stops the world.
+func (c *RuntimeCollector) Update() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	runtime.ReadMemStats(&c.memStats)
+	c.goroutines = runtime.NumGoroutine()
+
+	c.gc.Set(int64(c.memStats.NumGC))
+	c.mallocs.Set(int64(c.memStats.Mallocs))
+	c.frees.Set(int64(c.memStats.Frees))
+	c.alloc.Set(int64(c.memStats.TotalAlloc))
+	c.cgoCalls.Set(runtime.NumCgoCall())
+	c.addPauses()
+
+	if c.process != nil {
+		c.process.update()
+	}
+	for _, g := range c.gauges {
+		g.gauge.Set(g.value())
+	}
+}
+
+// addPauses adds the pauses of the GCs since the previous Update.
+// Only the most recent len(PauseNs) pauses are kept by the runtime.
+func (c *RuntimeCollector) addPauses() {
+	numGC := c.memStats.NumGC
+	n := numGC - c.lastNumGC
+	if !c.initialized {
+		c.initialized = true
+		n = numGC
+	}
+	if n > uint32(len(c.memStats.PauseNs)) {
+		n = uint32(len(c.memStats.PauseNs))
+	}
+	for i := numGC - n; i < numGC; i++ {
+		c.pauses.Add(int64(c.memStats.PauseNs[i%uint32(len(c.memStats.PauseNs))]))
+	}
+	c.lastNumGC = numGC
+}
+
+// Stop stops updating the metrics, which remain registered.
+func (c *RuntimeCollector) Stop() {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+}
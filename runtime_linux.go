@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+const runtimeProcessSupported = true
+
+// Length of a clock tick in /proc/self/stat. This is USER_HZ, which
+// is 100 on all common architectures.
+const runtimeClockTick = 10 // milliseconds
+
+// runtimeProcess reads the statistics of the process from /proc/self.
+type runtimeProcess struct {
+	threads   int64
+	rss       int64
+	openFDs   int64
+	cpuUser   *Meter
+	cpuSystem *Meter
+}
+
+// update reads the statistics. Those which cannot be read are left
+// unchanged.
+func (p *runtimeProcess) update() {
+	if stat, err := os.ReadFile("/proc/self/stat"); err == nil {
+		p.updateStat(stat)
+	}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		// less the descriptor used to read the directory
+		p.openFDs = int64(len(fds)) - 1
+	}
+}
+
+// updateStat parses the contents of /proc/self/stat. See proc(5).
+func (p *runtimeProcess) updateStat(stat []byte) {
+	// the command name, the second field, may contain spaces, but is
+	// enclosed in parentheses
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return
+	}
+	// fields from the third, state, onwards
+	fields := bytes.Fields(stat[i+1:])
+	field := func(n int) int64 {
+		if n-3 >= len(fields) {
+			return 0
+		}
+		v, _ := strconv.ParseInt(string(fields[n-3]), 10, 64)
+		return v
+	}
+
+	p.cpuUser.Set(field(14) * runtimeClockTick)
+	p.cpuSystem.Set(field(15) * runtimeClockTick)
+	p.threads = field(20)
+	p.rss = field(24) * int64(os.Getpagesize())
+}
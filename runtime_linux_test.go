@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRuntimeProcessStat(t *testing.T) {
+	r := NewRegistry("test")
+	p := &runtimeProcess{}
+	p.cpuUser = r.NewMeter(p, "user")
+	p.cpuSystem = r.NewMeter(p, "system")
+	stat := "42 (a (b) c) S 1 2 3 4 5 6 7 8 9 10 150 25 0 0 20 0 7 0 " +
+		"100 2000 30 18446744073709551615"
+	p.updateStat([]byte(stat))
+
+	if v := p.cpuUser.Snapshot().Value; v != 1500 {
+		t.Errorf("Wrong user CPU time, got %d expected 1500", v)
+	}
+	if v := p.cpuSystem.Snapshot().Value; v != 250 {
+		t.Errorf("Wrong system CPU time, got %d expected 250", v)
+	}
+	if p.threads != 7 {
+		t.Errorf("Wrong threads, got %d expected 7", p.threads)
+	}
+	if expected := 30 * int64(os.Getpagesize()); p.rss != expected {
+		t.Errorf("Wrong RSS, got %d expected %d", p.rss, expected)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package metrics
+
+const runtimeProcessSupported = false
+
+type runtimeProcess struct {
+	threads   int64
+	rss       int64
+	openFDs   int64
+	cpuUser   *Meter
+	cpuSystem *Meter
+}
+
+func (p *runtimeProcess) update() {}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+)
+
+func testRuntimeGauge(t *testing.T, r *Registry, name string) int64 {
+	m := r.FindS("metrics.RuntimeCollector." + name)
+	g, ok := m.(*IntGauge)
+	if !ok {
+		t.Fatalf("IntGauge %s was not registered", name)
+	}
+	return g.Value()
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	r := NewRegistry("test")
+	c := NewRuntimeCollector(r, 0)
+	if c == nil {
+		t.Fatalf("NewRuntimeCollector returned nil")
+	}
+	defer c.Stop()
+
+	runtime.GC()
+	c.Update()
+
+	for _, name := range []string{"goroutines", "heap_alloc", "sys"} {
+		if v := testRuntimeGauge(t, r, name); v <= 0 {
+			t.Errorf("Wrong value of %s, got %d expected > 0", name, v)
+		}
+	}
+
+	gc := r.FindS("metrics.RuntimeCollector.gc").(*Meter)
+	if v := gc.Snapshot().Value; v < 1 {
+		t.Errorf("Wrong GC count, got %d expected >= 1", v)
+	}
+	pauses := r.FindS("metrics.RuntimeCollector.gc_pause").(*Distribution)
+	if s := pauses.Snapshot(); s.Count < 1 {
+		t.Errorf("Wrong GC pause count, got %d expected >= 1", s.Count)
+	}
+
+	if runtimeProcessSupported {
+		for _, name := range []string{"threads", "rss", "open_fds"} {
+			if v := testRuntimeGauge(t, r, name); v <= 0 {
+				t.Errorf("Wrong value of %s, got %d expected > 0", name, v)
+			}
+		}
+	}
+
+	if NewRuntimeCollector(r, 0) != nil {
+		t.Errorf("NewRuntimeCollector registered the same metrics twice")
+	}
+}
+
+func TestRuntimeCollectorConflict(t *testing.T) {
+	r := NewRegistry("test")
+	r.NewCounter(&RuntimeCollector{}, "gc_pause")
+	if NewRuntimeCollector(r, 0) != nil {
+		t.Fatalf("NewRuntimeCollector registered an existing name")
+	}
+	if names := r.List(); len(names) != 1 {
+		t.Errorf("Failed NewRuntimeCollector left metrics: %v", names)
+	}
+
+	r.Unregister(&RuntimeCollector{}, "gc_pause")
+	c := NewRuntimeCollector(r, 0)
+	if c == nil {
+		t.Fatalf("NewRuntimeCollector failed after a failed attempt")
+	}
+	c.Stop()
+}
+
+func TestRuntimeCollectorPauses(t *testing.T) {
+	r := NewRegistry("test")
+	c := NewRuntimeCollector(r, 0)
+	pauses := r.FindS("metrics.RuntimeCollector.gc_pause").(*Distribution)
+	before := pauses.Snapshot().Count
+
+	runtime.GC()
+	runtime.GC()
+	c.Update()
+	if count := pauses.Snapshot().Count; count != before+2 {
+		t.Errorf("Wrong GC pause count, got %d expected %d", count, before+2)
+	}
+}
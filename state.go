@@ -0,0 +1,418 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"metrics/rbtree"
+	"metrics/statistics"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// stateVersion is the version of the format written by SaveState.
+const stateVersion = 1
+
+// registryState is the format written by SaveState: a JSON object
+// holding the version, the time the state was saved, and the state of
+// each metric, keyed by its full name.
+type registryState struct {
+	Version int
+	Saved   time.Time
+	Metrics map[string]metricState
+}
+
+// metricState is the state of a metric, as saved by its saveState,
+// with its type, and its family and labels if it is a member of a
+// labelled family, so that the member can be created on restore.
+type metricState struct {
+	Type   string
+	Family string `json:",omitempty"`
+	Labels Labels `json:",omitempty"`
+	State  json.RawMessage
+}
+
+// persistentMetric is implemented by the metrics whose state is saved
+// by SaveState.
+type persistentMetric interface {
+	// saveState returns the metric's state, to be encoded as JSON,
+	// or nil if it is not saved.
+	saveState() interface{}
+	// restoreState replaces the metric's state by the one encoded.
+	restoreState(data []byte) error
+}
+
+// stateSaving holds the state of the periodic saving started by
+// StartStateSaving.
+type stateSaving struct {
+	path string
+	stop chan bool
+	done chan bool
+}
+
+// A Counter's start time is saved, so that exporters of cumulative
+// sums see its value continue.
+type counterState struct {
+	Value int64
+	Start time.Time
+}
+
+func (c *Counter) saveState() interface{} {
+	return counterState{c.Snapshot().Value, c.Start()}
+}
+
+func (c *Counter) restoreState(data []byte) error {
+	var s counterState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	c.Set(s.Value)
+	if !s.Start.IsZero() {
+		atomic.StoreInt64(&c.start, s.Start.UnixNano())
+	}
+	return nil
+}
+
+// A Meter's state is its snapshot, which holds all of its Rate.
+func (m *Meter) saveState() interface{} {
+	return m.Snapshot()
+}
+
+func (m *Meter) restoreState(data []byte) error {
+	var s MeterSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.r.Restore(s.Value, s.LastUpdated, s.TimeConstants, s.Derivatives) {
+		return fmt.Errorf("metrics: invalid meter derivatives")
+	}
+	return nil
+}
+
+// distributionState holds a Distribution's sample, each value with
+// the time it was added, relative to TimeBase.
+type distributionState struct {
+	TimeBase       time.Time
+	PopulationSize float64
+	Samples        []sampleState
+}
+
+type sampleState struct {
+	Time  time.Duration
+	Value float64
+}
+
+// An HDR Distribution's histograms are not saved.
+func (d *Distribution) saveState() interface{} {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if d.hdr != nil {
+		return nil
+	}
+
+	s := distributionState{
+		TimeBase:       d.timeBase,
+		PopulationSize: d.populationSize,
+		Samples:        make([]sampleState, 0, d.size()),
+	}
+	for n := d.times.FindByRank(0); n != nil; n = d.times.Next(n) {
+		v := n.Value().(statistics.SampleElement).FloatValue()
+		// JSON cannot encode infinities
+		if !math.IsInf(v, 0) {
+			s.Samples = append(s.Samples,
+				sampleState{time.Duration(n.Key()), v})
+		}
+	}
+	return s
+}
+
+func (d *Distribution) restoreState(data []byte) error {
+	var s distributionState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.hdr != nil {
+		return nil
+	}
+
+	if d.s.Float() {
+		d.s = statistics.NewFloatSample()
+	} else {
+		d.s = statistics.NewSample()
+	}
+	d.times = rbtree.New()
+	d.timeBase = s.TimeBase
+	for _, sample := range s.Samples {
+		if se := d.s.AddFloat(sample.Value); se.Valid() {
+			d.times.Insert(int64(sample.Time), se)
+		}
+	}
+
+	// keep the most recent values if the maximum size is smaller
+	for d.size() > d.maxSampleSize {
+		d.remove(d.times.FindByRank(0))
+	}
+	d.populationSize = math.Max(s.PopulationSize, float64(d.size()))
+	d.prune(d.clock.Now())
+	return nil
+}
+
+type digestState struct {
+	Sketch      []byte
+	LastUpdated time.Time
+}
+
+func (d *Digest) saveState() interface{} {
+	sketch, err := d.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return digestState{sketch, d.lastUpdated}
+}
+
+func (d *Digest) restoreState(data []byte) error {
+	var s digestState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.t.UnmarshalBinary(s.Sketch); err != nil {
+		return err
+	}
+	d.lastUpdated = s.LastUpdated
+	return nil
+}
+
+type timerState struct {
+	Meter        json.RawMessage
+	Distribution json.RawMessage
+}
+
+func (t *Timer) saveState() interface{} {
+	meter, err := json.Marshal(t.Meter().saveState())
+	if err != nil {
+		return nil
+	}
+	dist, err := json.Marshal(t.Distribution().saveState())
+	if err != nil {
+		return nil
+	}
+	return timerState{meter, dist}
+}
+
+func (t *Timer) restoreState(data []byte) error {
+	var s timerState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if err := t.Meter().restoreState(s.Meter); err != nil {
+		return err
+	}
+	if string(s.Distribution) == "null" {
+		return nil
+	}
+	return t.Distribution().restoreState(s.Distribution)
+}
+
+// SaveState writes the state of the receiver's Counters, Meters,
+// Distributions, Digests and Timers to w, so that it can be restored
+// by RestoreState after the process restarts. It includes the averages
+// and rates of Meters and the time of their last update, and the
+// samples of Distributions, with the times they were added, and the
+// size of their populations. Gauges, whose values are computed anew,
+// and the histograms of HDR Distributions are not saved.
+//
+// The state is a versioned JSON object. Metrics are keyed by their
+// full names in the receiver.
+func (r *Registry) SaveState(w io.Writer) error {
+	s := registryState{
+		Version: stateVersion,
+		Saved:   r.clock.Now(),
+		Metrics: make(map[string]metricState),
+	}
+	for name, m := range r.ListMetrics() {
+		p, ok := m.(persistentMetric)
+		if !ok {
+			continue
+		}
+		state := p.saveState()
+		if state == nil {
+			continue
+		}
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		ms := metricState{Type: metricType(m), State: data}
+		if family, labels := r.LabelSet(name); labels != nil {
+			ms.Family, ms.Labels = family, labels
+		}
+		s.Metrics[name] = ms
+	}
+
+	return json.NewEncoder(w).Encode(s)
+}
+
+// RestoreState reads a state written by SaveState, and restores it
+// into the metrics registered under the same names with the same
+// types, replacing their values. Members of labelled families that are
+// registered are created. Metrics which are not registered, or are of
+// another type, are skipped, so RestoreState is usually called after
+// a program has registered its metrics, before it updates them.
+//
+// It returns an error if the state cannot be read or has an
+// unsupported version, in which case no metric is changed, or if the
+// state of a metric is invalid, in which case the following metrics
+// are not restored.
+func (r *Registry) RestoreState(rd io.Reader) error {
+	var s registryState
+	if err := json.NewDecoder(rd).Decode(&s); err != nil {
+		return err
+	}
+	if s.Version != stateVersion {
+		return fmt.Errorf("metrics: unsupported state version %d", s.Version)
+	}
+
+	for name, ms := range s.Metrics {
+		m := r.FindS(name)
+		if m == nil && ms.Family != "" {
+			r.lock.RLock()
+			v := r.vecs[r.prefix+ms.Family]
+			r.lock.RUnlock()
+			if v != nil {
+				m = v.withLabels(ms.Labels)
+			}
+		}
+		p, ok := m.(persistentMetric)
+		if !ok || metricType(m) != ms.Type {
+			continue
+		}
+		if err := p.restoreState(ms.State); err != nil {
+			return fmt.Errorf("metrics: restoring %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// SaveStateFile writes the receiver's state, as by SaveState, to the
+// file at path. The file is replaced atomically, by writing the state
+// to a temporary file in the same directory and renaming it, so that
+// a crash while saving leaves the previous state.
+func (r *Registry) SaveStateFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	err = r.SaveState(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// RestoreStateFile restores the receiver's state, as by RestoreState,
+// from the file at path. It returns nil if the file does not exist, as
+// when a program first starts.
+func (r *Registry) RestoreStateFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.RestoreState(f)
+}
+
+// StartStateSaving starts saving the receiver's state to the file at
+// path, as by SaveStateFile, at an interval, in the background,
+// replacing any previous schedule. If a save fails, onError, unless it
+// is nil, is called with its error, and the save is tried again at the
+// next interval.
+func (r *Registry) StartStateSaving(path string, interval time.Duration,
+	onError func(error)) {
+
+	r.stopStateSaving()
+
+	stop, done := make(chan bool), make(chan bool)
+	r.lock.Lock()
+	r.saving = stateSaving{path, stop, done}
+	r.lock.Unlock()
+
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := r.SaveStateFile(path); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopStateSaving stops the saving started by StartStateSaving, waiting
+// for a save in progress, and saves the state a final time, so that a
+// program shutting down keeps its latest state. It returns the error of
+// the final save, or nil if the state was not being saved.
+func (r *Registry) StopStateSaving() error {
+	path := r.stopStateSaving()
+	if path == "" {
+		return nil
+	}
+	return r.SaveStateFile(path)
+}
+
+// stopStateSaving stops the saving started by StartStateSaving, and
+// returns the path it was saving to, or "" if it was not started.
+func (r *Registry) stopStateSaving() string {
+	r.lock.Lock()
+	saving := r.saving
+	r.saving = stateSaving{}
+	r.lock.Unlock()
+
+	if saving.stop == nil {
+		return ""
+	}
+	close(saving.stop)
+	<-saving.done
+	return saving.path
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"metrics/metricstest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStateRegistry registers one metric of each saved type, with a
+// labelled family.
+func testStateRegistry(c *metricstest.Clock) *Registry {
+	r := NewRegistryClock("testRegistry", c)
+	r.NewCounter(testRegistryType{}, "counter")
+	r.NewMeter(testRegistryType{}, "meter")
+	r.NewDistribution(testRegistryType{}, "distribution")
+	r.NewFloatDistribution(testRegistryType{}, "float")
+	r.NewDigest(testRegistryType{}, "digest", 0)
+	r.NewTimer(testRegistryType{}, "timer")
+	r.NewCounterVec(testRegistryType{}, "vec")
+	r.NewIntGauge(testRegistryType{}, "gauge")
+	return r
+}
+
+func testStateSnapshots(r *Registry) map[string]string {
+	snapshots := make(map[string]string)
+	for name, m := range r.ListMetrics() {
+		var s interface{}
+		switch m := m.(type) {
+		case *Counter:
+			s = m.Snapshot()
+		case *Meter:
+			s = m.Snapshot()
+		case *Distribution:
+			s = m.Snapshot()
+		case *Digest:
+			s = m.Snapshot()
+		case *Timer:
+			s = m.Snapshot()
+		case *IntGauge:
+			s = m.Snapshot()
+		}
+		snapshots[name] = fmt.Sprintf("%+v", s)
+	}
+	return snapshots
+}
+
+func TestRegistryState(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := metricstest.NewClock(base)
+	r := testStateRegistry(c)
+	for i := int64(1); i <= 10; i++ {
+		r.Find(testRegistryType{}, "counter").(*Counter).Inc(i)
+		r.Find(testRegistryType{}, "meter").(*Meter).Inc(i)
+		r.Find(testRegistryType{}, "distribution").(*Distribution).Add(i)
+		r.Find(testRegistryType{}, "float").(*Distribution).AddFloat(float64(i) / 4)
+		r.Find(testRegistryType{}, "digest").(*Digest).Add(float64(i))
+		r.Find(testRegistryType{}, "timer").(*Timer).Update(time.Duration(i))
+		c.Add(time.Second)
+	}
+	v, _ := r.GetOrRegisterCounterVec(testRegistryType{}, "vec")
+	v.WithLabels(Labels{"a": "1"}).Inc(3)
+	r.Find(testRegistryType{}, "gauge").(*IntGauge).Set(7)
+
+	var buf bytes.Buffer
+	if err := r.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	restored := testStateRegistry(c)
+	if err := restored.RestoreState(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+
+	expected := testStateSnapshots(r)
+	got := testStateSnapshots(restored)
+	// gauges are not saved
+	expected["metrics.testRegistryType.gauge"] = fmt.Sprintf("%+v",
+		IntGaugeSnapshot{})
+	for name, s := range expected {
+		if got[name] != s {
+			t.Errorf("Wrong restored %s, got %s expected %s", name, got[name], s)
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Wrong restored metrics, got %v expected %v", got, expected)
+	}
+}
+
+func TestRegistryStateVersion(t *testing.T) {
+	r := NewRegistry("testRegistry")
+	c := r.NewCounter(testRegistryType{}, "counter")
+	c.Set(1)
+
+	state := `{"Version":2,"Metrics":{"metrics.testRegistryType.counter":` +
+		`{"Type":"counter","State":{"Value":5}}}}`
+	if err := r.RestoreState(strings.NewReader(state)); err == nil {
+		t.Errorf("RestoreState accepted an unsupported version")
+	}
+	if v := c.Snapshot().Value; v != 1 {
+		t.Errorf("Wrong value after a failed restore, got %d expected 1", v)
+	}
+}
+
+func TestRegistryStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.state")
+	r := NewRegistry("testRegistry")
+	c := r.NewCounter(testRegistryType{}, "counter")
+	if err := r.RestoreStateFile(path); err != nil {
+		t.Errorf("RestoreStateFile failed on a missing file: %v", err)
+	}
+
+	c.Set(42)
+	if err := r.SaveStateFile(path); err != nil {
+		t.Fatalf("SaveStateFile failed: %v", err)
+	}
+	c.Set(0)
+	if err := r.RestoreStateFile(path); err != nil {
+		t.Fatalf("RestoreStateFile failed: %v", err)
+	}
+	if v := c.Snapshot().Value; v != 42 {
+		t.Errorf("Wrong restored value, got %d expected 42", v)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	if len(files) != 1 {
+		t.Errorf("SaveStateFile left temporary files: %v", files)
+	}
+}
+
+func TestRegistryStateSaving(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry("testRegistry")
+	c := r.NewCounter(testRegistryType{}, "counter")
+	if err := r.StopStateSaving(); err != nil {
+		t.Errorf("StopStateSaving failed when not saving: %v", err)
+	}
+
+	// saves to a missing directory fail
+	errs := make(chan error, 1)
+	missing := filepath.Join(dir, "missing", "metrics.state")
+	r.StartStateSaving(missing, time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err := <-errs; err == nil {
+		t.Errorf("Failed save reported a nil error")
+	}
+	if err := r.StopStateSaving(); err == nil {
+		t.Errorf("StopStateSaving succeeded with a failed final save")
+	}
+
+	// the final save keeps the latest state
+	path := filepath.Join(dir, "metrics.state")
+	r.StartStateSaving(path, time.Hour, nil)
+	c.Set(42)
+	if err := r.StopStateSaving(); err != nil {
+		t.Fatalf("StopStateSaving failed: %v", err)
+	}
+	c.Set(0)
+	if err := r.RestoreStateFile(path); err != nil {
+		t.Fatalf("RestoreStateFile failed: %v", err)
+	}
+	if v := c.Snapshot().Value; v != 42 {
+		t.Errorf("Wrong restored value, got %d expected 42", v)
+	}
+}
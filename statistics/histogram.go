@@ -0,0 +1,338 @@
+package statistics
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Histogram is a High Dynamic Range (HDR) histogram. It records
+// values between 0 and a configurable highest trackable value into
+// buckets whose width is bounded relative to the values they contain,
+// so that every recorded value and every percentile is accurate to a
+// configurable number of significant decimal digits.
+// Recording a value is O(1) and does not allocate.
+//
+// See http://hdrhistogram.org for a description of the layout.
+type Histogram struct {
+	lowest  int64
+	highest int64
+	digits  int
+
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+
+	counts     []uint64
+	totalCount uint64
+	min        int64
+	max        int64
+}
+
+// NewHistogram creates a Histogram able to distinguish values as
+// small as lowest (at least 1) and record values up to highest (at
+// least twice lowest), accurate to digits (1 to 5) significant digits.
+// It returns nil if the parameters are out of range.
+func NewHistogram(lowest, highest int64, digits int) *Histogram {
+	if lowest < 1 || highest < 2*lowest || digits < 1 || digits > 5 {
+		return nil
+	}
+
+	h := &Histogram{
+		lowest:  lowest,
+		highest: highest,
+		digits:  digits,
+	}
+
+	singleUnitResolution := 2 * math.Pow10(digits)
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(singleUnitResolution)))
+	h.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	h.unitMagnitude = uint(bits.Len64(uint64(lowest)) - 1)
+	h.subBucketCount = 1 << subBucketCountMagnitude
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = int64(h.subBucketCount-1) << h.unitMagnitude
+
+	// find the number of buckets needed to cover highest
+	smallestUntrackable := uint64(h.subBucketCount) << h.unitMagnitude
+	bucketCount := 1
+	for smallestUntrackable <= uint64(highest) {
+		smallestUntrackable <<= 1
+		bucketCount++
+	}
+
+	h.counts = make([]uint64, (bucketCount+1)*h.subBucketHalfCount)
+	h.Reset()
+	return h
+}
+
+// Reset removes all recorded values.
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.totalCount = 0
+	h.min = math.MaxInt64
+	h.max = 0
+}
+
+// Lowest returns the lowest discernible value.
+func (h *Histogram) Lowest() int64 {
+	return h.lowest
+}
+
+// Highest returns the highest trackable value.
+func (h *Histogram) Highest() int64 {
+	return h.highest
+}
+
+// Digits returns the number of significant digits.
+func (h *Histogram) Digits() int {
+	return h.digits
+}
+
+func (h *Histogram) bucketIndex(v int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	return pow2Ceiling - int(h.unitMagnitude) -
+		int(h.subBucketHalfCountMagnitude+1)
+}
+
+func (h *Histogram) subBucketIndex(v int64, bucket int) int {
+	return int(v >> (uint(bucket) + h.unitMagnitude))
+}
+
+func (h *Histogram) countsIndex(bucket, subBucket int) int {
+	bucketBase := (bucket + 1) << h.subBucketHalfCountMagnitude
+	return bucketBase + subBucket - h.subBucketHalfCount
+}
+
+func (h *Histogram) countsIndexFor(v int64) int {
+	bucket := h.bucketIndex(v)
+	return h.countsIndex(bucket, h.subBucketIndex(v, bucket))
+}
+
+func (h *Histogram) valueFromIndex(bucket, subBucket int) int64 {
+	return int64(subBucket) << (uint(bucket) + h.unitMagnitude)
+}
+
+func (h *Histogram) valueFromCountsIndex(i int) int64 {
+	bucket := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucket := (i & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return h.valueFromIndex(bucket, subBucket)
+}
+
+func (h *Histogram) equivalentRange(v int64) int64 {
+	bucket := h.bucketIndex(v)
+	subBucket := h.subBucketIndex(v, bucket)
+	if subBucket >= h.subBucketCount {
+		bucket++
+	}
+	return 1 << (h.unitMagnitude + uint(bucket))
+}
+
+func (h *Histogram) lowestEquivalent(v int64) int64 {
+	bucket := h.bucketIndex(v)
+	return h.valueFromIndex(bucket, h.subBucketIndex(v, bucket))
+}
+
+func (h *Histogram) highestEquivalent(v int64) int64 {
+	return h.lowestEquivalent(v) + h.equivalentRange(v) - 1
+}
+
+func (h *Histogram) medianEquivalent(v int64) int64 {
+	return h.lowestEquivalent(v) + h.equivalentRange(v)>>1
+}
+
+// Record adds a value to the Histogram. Values outside of the
+// trackable range are clamped to 0 or the highest trackable value.
+func (h *Histogram) Record(v int64) {
+	h.RecordN(v, 1)
+}
+
+// RecordN adds n occurences of a value to the Histogram.
+func (h *Histogram) RecordN(v int64, n uint64) {
+	if n == 0 {
+		return
+	}
+	if v < 0 {
+		v = 0
+	}
+	if v > h.highest {
+		v = h.highest
+	}
+
+	h.add(v, n)
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Merge adds all values recorded in o to the receiver. The two
+// Histograms need not have the same parameters, but values are
+// only as accurate as the less precise of them.
+func (h *Histogram) Merge(o *Histogram) {
+	for i, n := range o.counts {
+		if n != 0 {
+			v := o.valueFromCountsIndex(i)
+			if v > h.highest {
+				v = h.highest
+			}
+			h.add(v, n)
+		}
+	}
+	if o.totalCount != 0 {
+		// bucket values are rounded down, so keep the exact extremes
+		if o.min < h.min {
+			h.min = o.min
+		}
+		if o.max > h.max {
+			h.max = o.max
+		}
+	}
+}
+
+func (h *Histogram) add(v int64, n uint64) {
+	h.counts[h.countsIndexFor(v)] += n
+	h.totalCount += n
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.totalCount
+}
+
+// Min returns the smallest recorded value, or 0 if there are none.
+func (h *Histogram) Min() int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return h.min
+}
+
+// Max returns the largest recorded value, or 0 if there are none.
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// moment returns the kth central moment (or the mean if k is 1)
+// computed from the midpoints of each bucket.
+func (h *Histogram) moment(k int, mean float64) float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	var sum float64
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		x := float64(h.medianEquivalent(h.valueFromCountsIndex(i)))
+		if k == 1 {
+			sum += x * float64(n)
+		} else {
+			sum += math.Pow(x-mean, float64(k)) * float64(n)
+		}
+	}
+	return sum / float64(h.totalCount)
+}
+
+func (h *Histogram) Mean() float64 {
+	return toFinite(h.moment(1, 0))
+}
+
+func (h *Histogram) Variance() float64 {
+	n := float64(h.totalCount)
+	return toFinite(h.moment(2, h.moment(1, 0)) * n / (n - 1))
+}
+
+func (h *Histogram) StandardDeviation() float64 {
+	return toFinite(math.Sqrt(h.Variance()))
+}
+
+func (h *Histogram) Skewness() float64 {
+	mean := h.moment(1, 0)
+	v := h.moment(3, mean) / math.Pow(h.moment(2, mean), 1.5)
+	return toFinite(v)
+}
+
+func (h *Histogram) Kurtosis() float64 {
+	mean := h.moment(1, 0)
+	m2 := h.moment(2, mean)
+	return toFinite(h.moment(4, mean) / m2 / m2)
+}
+
+// Percentile returns the value below which a fraction p of the
+// recorded values fall, to the Histogram's precision. 0.0 is the
+// minimum and 1.0 is the maximum.
+func (h *Histogram) Percentile(p float64) int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	if p <= 0.0 {
+		return h.min
+	}
+	if p >= 1.0 {
+		return h.max
+	}
+
+	rank := uint64(math.Floor(p*float64(h.totalCount) + 0.5))
+	if rank == 0 {
+		rank = 1
+	}
+	return h.valueAtRank(rank)
+}
+
+func (h *Histogram) valueAtRank(rank uint64) int64 {
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+		if total >= rank {
+			v := h.highestEquivalent(h.valueFromCountsIndex(i))
+			if v > h.max {
+				return h.max
+			}
+			if v < h.min {
+				return h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Values returns n values spread evenly over the recorded values,
+// in ascending order, which may be used in place of a sample of
+// the recorded values.
+func (h *Histogram) Values(n uint64) []int64 {
+	vals := make([]int64, 0, n)
+	if h.totalCount == 0 {
+		return vals
+	}
+
+	var total uint64
+	i := 0
+	for j := uint64(0); j < n; j++ {
+		// rank of the midpoint of the jth of n equal parts
+		rank := uint64(float64(2*j+1)*float64(h.totalCount)/float64(2*n)) + 1
+		for ; i < len(h.counts); i++ {
+			if total+h.counts[i] >= rank {
+				break
+			}
+			total += h.counts[i]
+		}
+		v := h.medianEquivalent(h.valueFromCountsIndex(i))
+		if v > h.max {
+			v = h.max
+		}
+		if v < h.min {
+			v = h.min
+		}
+		vals = append(vals, v)
+	}
+	return vals
+}
@@ -0,0 +1,115 @@
+package statistics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func testHistogramInit() *Histogram {
+	h := NewHistogram(1, 3600*1000*1000, 3)
+	for i := int64(1); i <= 10000; i++ {
+		h.Record(i * 1000)
+	}
+	return h
+}
+
+func TestNewHistogramInvalid(t *testing.T) {
+	params := [][3]int64{
+		{0, 100, 3},
+		{10, 15, 3},
+		{1, 100, 0},
+		{1, 100, 6},
+	}
+	for _, p := range params {
+		if NewHistogram(p[0], p[1], int(p[2])) != nil {
+			t.Errorf("Histogram with parameters %v should not be created", p)
+		}
+	}
+}
+
+func TestHistogramCount(t *testing.T) {
+	h := testHistogramInit()
+	testCompare(t, "histogram Count", h.Count(), uint64(10000))
+	testCompare(t, "histogram Min", h.Min(), int64(1000))
+	testCompare(t, "histogram Max", h.Max(), int64(10000000))
+}
+
+func testHistogramWithin(t *testing.T, name string, v, expected float64) {
+	// 3 significant digits
+	if math.Abs(v-expected) > math.Abs(expected)*1e-3 {
+		t.Errorf("%s is %v, should be within 0.1%% of %v.", name, v, expected)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := testHistogramInit()
+	for _, p := range []float64{0.01, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999} {
+		testHistogramWithin(t, fmt.Sprintf("histogram Percentile(%v)", p),
+			float64(h.Percentile(p)), p*1e7)
+	}
+	testCompare(t, "histogram Percentile(0)", h.Percentile(0), int64(1000))
+	testCompare(t, "histogram Percentile(1)", h.Percentile(1), int64(10000000))
+}
+
+func TestHistogramMoments(t *testing.T) {
+	h := testHistogramInit()
+	testHistogramWithin(t, "histogram Mean", h.Mean(), 5000500)
+	// standard deviation of a discrete uniform distribution
+	testHistogramWithin(t, "histogram StandardDeviation",
+		h.StandardDeviation(), 1000*math.Sqrt((1e8-1)/12*10000/9999))
+	if math.Abs(h.Skewness()) > 1e-3 {
+		t.Errorf("histogram Skewness is %v, should be 0.", h.Skewness())
+	}
+	testHistogramWithin(t, "histogram Kurtosis", h.Kurtosis(), 1.8)
+}
+
+func TestHistogramClamp(t *testing.T) {
+	h := NewHistogram(1, 1000, 2)
+	h.Record(-5)
+	h.Record(5000)
+	testCompare(t, "clamped histogram Min", h.Min(), int64(0))
+	testCompare(t, "clamped histogram Max", h.Max(), int64(1000))
+}
+
+func TestHistogramMerge(t *testing.T) {
+	h := testHistogramInit()
+	o := NewHistogram(1, 3600*1000*1000, 3)
+	for i := int64(10001); i <= 20000; i++ {
+		o.Record(i * 1000)
+	}
+	h.Merge(o)
+
+	testCompare(t, "merged histogram Count", h.Count(), uint64(20000))
+	testCompare(t, "merged histogram Max", h.Max(), int64(20000000))
+	testHistogramWithin(t, "merged histogram Percentile(0.5)",
+		float64(h.Percentile(0.5)), 1e7)
+}
+
+func TestHistogramValues(t *testing.T) {
+	h := testHistogramInit()
+	vals := h.Values(10)
+	if len(vals) != 10 {
+		t.Fatalf("histogram Values returned %d values, expected %d",
+			len(vals), 10)
+	}
+	for i, v := range vals {
+		testHistogramWithin(t, fmt.Sprintf("histogram Values[%d]", i),
+			float64(v), float64(i)*1e6+501000)
+	}
+}
+
+func TestHistogramReset(t *testing.T) {
+	h := testHistogramInit()
+	h.Reset()
+	testCompare(t, "reset histogram Count", h.Count(), uint64(0))
+	testCompare(t, "reset histogram Percentile(0.5)", h.Percentile(0.5),
+		int64(0))
+}
+
+func BenchmarkHistogramRecord(b *testing.B) {
+	h := NewHistogram(1, 3600*1000*1000, 3)
+	for i := 0; i < b.N; i++ {
+		h.Record(int64(i))
+	}
+}
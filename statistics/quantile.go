@@ -0,0 +1,233 @@
+package statistics
+
+import (
+	"math"
+)
+
+// QuantileMethod is a method of estimating a quantile of the
+// population from which a Sample is drawn.
+type QuantileMethod int
+
+const (
+	// NearestRank is the value whose rank is nearest to p(n-1), as
+	// returned by Percentile but without rounding float64 values. It is
+	// always a value in the Sample.
+	NearestRank QuantileMethod = iota
+
+	// HyndmanFan1 to HyndmanFan9 are the nine definitions of Hyndman
+	// and Fan, "Sample Quantiles in Statistical Packages" (1996), also
+	// known as R's quantile types. Types 1 to 3 are discontinuous:
+	// 1 is the inverse of the empirical distribution function, 2 is the
+	// same with averaging at discontinuities, and 3 is the observation
+	// nearest to np. Types 4 to 9 interpolate linearly between adjacent
+	// values, differing in where each value is placed: 7 is the default
+	// of R and most spreadsheets, and 8 is recommended by Hyndman and
+	// Fan as approximately median-unbiased.
+	HyndmanFan1
+	HyndmanFan2
+	HyndmanFan3
+	HyndmanFan4
+	HyndmanFan5
+	HyndmanFan6
+	HyndmanFan7
+	HyndmanFan8
+	HyndmanFan9
+
+	// HarrellDavis is the Harrell-Davis estimator, a weighted mean of
+	// all values in the Sample, whose weights follow a beta
+	// distribution. It is smoother than the other methods, especially
+	// for small samples, but takes time proportional to the size of the
+	// Sample.
+	HarrellDavis
+)
+
+// quantileFuzz absorbs rounding errors in np, so that the
+// discontinuous methods are not off by one value, as in R.
+const quantileFuzz = 4 * 2.220446049250313e-16
+
+// hyndmanFanAlphaBeta holds the parameters alpha and beta of the
+// continuous Hyndman-Fan methods, from type 4 to type 9.
+var hyndmanFanAlphaBeta = [6][2]float64{
+	{0, 1},
+	{0.5, 0.5},
+	{0, 0},
+	{1, 1},
+	{1.0 / 3, 1.0 / 3},
+	{3.0 / 8, 3.0 / 8},
+}
+
+// Quantile estimates the quantile p, between 0 and 1, of the
+// population from which the Sample is drawn, using a method.
+// It returns 0 if the Sample is empty.
+func (s *Sample) Quantile(p float64, method QuantileMethod) float64 {
+	if s.Count() == 0 {
+		return 0
+	}
+	if !(p >= 0.0) {
+		p = 0.0
+	}
+	if p > 1.0 {
+		p = 1.0
+	}
+
+	switch {
+	case method == HarrellDavis:
+		return s.harrellDavis(p)
+	case method >= HyndmanFan1 && method <= HyndmanFan3:
+		return s.hyndmanFanDiscontinuous(p, method)
+	case method >= HyndmanFan4 && method <= HyndmanFan9:
+		ab := hyndmanFanAlphaBeta[method-HyndmanFan4]
+		return s.hyndmanFanContinuous(p, ab[0], ab[1])
+	}
+	return s.orderStatistic(math.Floor(p*float64(s.Count()-1)+0.5) + 1)
+}
+
+// orderStatistic returns the jth smallest value, from 1 to Count,
+// clamping j to that range.
+func (s *Sample) orderStatistic(j float64) float64 {
+	n := float64(s.Count())
+	if j < 1 {
+		j = 1
+	}
+	if j > n {
+		j = n
+	}
+	return s.value(s.values.FindByRank(uint64(j) - 1).Key())
+}
+
+func (s *Sample) hyndmanFanDiscontinuous(p float64,
+	method QuantileMethod) float64 {
+
+	m := 0.0
+	if method == HyndmanFan3 {
+		m = -0.5
+	}
+	np := float64(s.Count())*p + m
+	j := math.Floor(np + quantileFuzz)
+	g := np - j
+	if math.Abs(g) <= quantileFuzz {
+		g = 0
+	}
+
+	gamma := 1.0
+	switch {
+	case g > 0:
+	case method == HyndmanFan1:
+		gamma = 0
+	case method == HyndmanFan2:
+		gamma = 0.5
+	case method == HyndmanFan3 && math.Mod(j, 2) == 0:
+		gamma = 0
+	}
+	return (1-gamma)*s.orderStatistic(j) + gamma*s.orderStatistic(j+1)
+}
+
+func (s *Sample) hyndmanFanContinuous(p, alpha, beta float64) float64 {
+	n := float64(s.Count())
+	h := n*p + alpha + p*(1-alpha-beta)
+	j := math.Floor(h + quantileFuzz)
+	g := h - j
+	if math.Abs(g) <= quantileFuzz {
+		g = 0
+	}
+
+	lo := s.orderStatistic(j)
+	if g == 0 {
+		return lo
+	}
+	return lo + g*(s.orderStatistic(j+1)-lo)
+}
+
+func (s *Sample) harrellDavis(p float64) float64 {
+	n := s.Count()
+	if n == 1 || p == 0 {
+		return s.orderStatistic(1)
+	}
+	if p == 1 {
+		return s.orderStatistic(float64(n))
+	}
+
+	a := p * float64(n+1)
+	b := (1 - p) * float64(n+1)
+	var sum, prev float64
+	i := uint64(0)
+	for node := s.values.FindByRank(0); node != nil; node = s.values.Next(node) {
+		i++
+		cdf := regularizedIncompleteBeta(float64(i)/float64(n), a, b)
+		sum += (cdf - prev) * s.value(node.Key())
+		prev = cdf
+	}
+	return sum
+}
+
+// regularizedIncompleteBeta returns I_x(a, b), the cumulative
+// distribution function of the beta distribution with parameters a
+// and b, evaluated with a continued fraction.
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log1p(-x))
+
+	// the continued fraction converges quickly on this side of the mean
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the
+// incomplete beta function by the modified Lentz method.
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 1000
+		epsilon       = 1e-15
+		tiny          = 1e-300
+	)
+
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= maxIterations; m++ {
+		// the even step
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// the odd step
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
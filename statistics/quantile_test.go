@@ -0,0 +1,108 @@
+package statistics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func testQuantileSample(values ...int64) *Sample {
+	s := NewSample()
+	for _, v := range values {
+		s.Add(v)
+	}
+	return s
+}
+
+// Reference values for 1, 2, ..., 10 under the Hyndman-Fan
+// definitions, which are the types of R's quantile function.
+var testQuantileReference = []struct {
+	p        float64
+	expected [9]float64
+}{
+	{0.25, [9]float64{3, 3, 2, 2.5, 3, 2.75, 3.25, 35.0 / 12, 2.9375}},
+	{0.5, [9]float64{5, 5.5, 5, 5, 5.5, 5.5, 5.5, 5.5, 5.5}},
+	{0.1, [9]float64{1, 1.5, 1, 1, 1.5, 1.1, 1.9, 1.3666666666666667,
+		1.4}},
+	{0, [9]float64{1, 1, 1, 1, 1, 1, 1, 1, 1}},
+	{1, [9]float64{10, 10, 10, 10, 10, 10, 10, 10, 10}},
+}
+
+func TestSampleQuantileHyndmanFan(t *testing.T) {
+	s := testQuantileSample(7, 3, 10, 1, 5, 2, 9, 4, 8, 6)
+	for _, r := range testQuantileReference {
+		for i, expected := range r.expected {
+			method := HyndmanFan1 + QuantileMethod(i)
+			got := s.Quantile(r.p, method)
+			if math.Abs(got-expected) > 1e-12 {
+				t.Errorf("Wrong type %d quantile %v, got %v expected %v",
+					i+1, r.p, got, expected)
+			}
+		}
+	}
+}
+
+// Deciles of testSampleSet from Python's statistics.quantiles, whose
+// inclusive method is type 7 and exclusive method is type 6.
+var testQuantileDeciles = [][2][]float64{
+	{{-7792564.6, -4585686.8, -3393979.4, -1628605.8, 239133.0,
+		1296518.8, 4179269.3, 6315000.4, 8768059.3}},
+	{{-8333729.4, -5585280.2, -3583602.6, -1643424.2, 239133.0,
+		1336821.2, 4194065.7, 6914134.6, 8852837.7}},
+}
+
+func TestSampleQuantileDeciles(t *testing.T) {
+	s := testSampleInit()
+	for i, method := range []QuantileMethod{HyndmanFan7, HyndmanFan6} {
+		for j, expected := range testQuantileDeciles[i][0] {
+			p := float64(j+1) / 10
+			testCompare(t, fmt.Sprintf("type %d quantile %v", 7-i, p),
+				math.Round(s.Quantile(p, method)*10)/10, expected)
+		}
+	}
+}
+
+// Harrell-Davis reference values were computed by integrating the beta
+// density numerically.
+func TestSampleQuantileHarrellDavis(t *testing.T) {
+	s := testQuantileSample(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	tests := [][2]float64{
+		{0.25, 2.998686946607126},
+		{0.5, 5.5},
+		{0.9, 9.435115176660437},
+		{0, 1},
+		{1, 10},
+	}
+	for _, test := range tests {
+		got := s.Quantile(test[0], HarrellDavis)
+		if math.Abs(got/test[1]-1) > 1e-9 {
+			t.Errorf("Wrong Harrell-Davis quantile %v, got %v expected %v",
+				test[0], got, test[1])
+		}
+	}
+
+	got := testSampleInit().Quantile(0.5, HarrellDavis)
+	if math.Abs(got/82608.45290789565-1) > 1e-6 {
+		t.Errorf("Wrong Harrell-Davis median, got %v expected %v",
+			got, 82608.45290789565)
+	}
+}
+
+func TestSampleQuantileSpecialCases(t *testing.T) {
+	if q := NewSample().Quantile(0.5, HyndmanFan7); q != 0 {
+		t.Errorf("Wrong quantile of an empty sample, got %v expected 0", q)
+	}
+	s := testQuantileSample(4)
+	for _, method := range []QuantileMethod{NearestRank, HyndmanFan1,
+		HyndmanFan7, HarrellDavis} {
+		if q := s.Quantile(0.3, method); q != 4 {
+			t.Errorf("Wrong quantile of one value, got %v expected 4", q)
+		}
+	}
+
+	s = testSampleInit()
+	for _, v := range testSamplePercentiles {
+		testCompare(t, fmt.Sprint("nearest rank quantile ", v[0]),
+			s.Quantile(v[0], NearestRank), v[1])
+	}
+}
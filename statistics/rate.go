@@ -53,8 +53,13 @@ func (r *Rate) LastUpdated() time.Time {
 	return r.lastUpdated
 }
 
+// Set sets the value at time t. If t is not after the last update, as
+// for updates at the same time or out of order, only the value
+// changes; the change is accounted for in the derivatives at the next
+// update.
 func (r *Rate) Set(v int64, t time.Time) {
 	if !t.After(r.lastUpdated) {
+		r.value = v
 		return
 	}
 
@@ -81,6 +86,34 @@ func (r *Rate) Set(v int64, t time.Time) {
 	r.lastUpdated = t
 }
 
+// Restore sets the value, the time of the last update, the time
+// constants and the derivatives, as returned by Value, LastUpdated,
+// TimeConstants and Derivatives, so that a Rate can be saved and
+// resumed. It returns false, leaving the Rate unchanged, unless each
+// order of derivatives has an instantaneous value and one average per
+// time constant.
+func (r *Rate) Restore(v int64, lastUpdated time.Time,
+	tcs []time.Duration, derivatives [][]float64) bool {
+
+	if len(derivatives) == 0 {
+		return false
+	}
+	for _, d := range derivatives {
+		if len(d) != len(tcs)+1 {
+			return false
+		}
+	}
+
+	r.allocate(uint64(len(derivatives)-1), uint64(len(tcs)))
+	copy(r.timeConstants, tcs)
+	for order, d := range derivatives {
+		copy(r.derivatives[order], d)
+	}
+	r.value = v
+	r.lastUpdated = lastUpdated
+	return true
+}
+
 func (r *Rate) TimeConstants() []time.Duration {
 	m := make([]time.Duration, len(r.timeConstants))
 	copy(m, r.timeConstants)
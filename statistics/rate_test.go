@@ -62,3 +62,45 @@ func TestSetDerivativeOrder(t *testing.T) {
 func TestSetTimeConstants(t *testing.T) {
 	//TODO
 }
+
+func TestRateSetSameTime(t *testing.T) {
+	r := NewRate(1, []time.Duration{time.Minute})
+	timeBase := time.Time{}.Add(time.Second)
+	r.Set(1, timeBase)
+	r.Set(3, timeBase)
+	testCompare(t, "rate Value at same time", r.Value(), int64(3))
+	r.Set(4, timeBase.Add(-time.Millisecond))
+	testCompare(t, "rate Value out of order", r.Value(), int64(4))
+
+	r.Set(5, timeBase.Add(time.Second))
+	testCompare(t, "rate of change after same time update",
+		r.Derivatives()[1][0], 4.0)
+}
+
+func TestRateRestore(t *testing.T) {
+	saved := testRateInit()
+	r := NewRate(1, []time.Duration{time.Minute})
+	if r.Restore(saved.Value(), saved.LastUpdated(), []time.Duration{time.Minute},
+		saved.Derivatives()) {
+
+		t.Errorf("Restore accepted derivatives of the wrong shape")
+	}
+	if !r.Restore(saved.Value(), saved.LastUpdated(), saved.TimeConstants(),
+		saved.Derivatives()) {
+
+		t.Fatalf("Restore rejected a Rate's state")
+	}
+
+	next := saved.LastUpdated().Add(time.Second)
+	saved.Set(7384400, next)
+	r.Set(7384400, next)
+	testCompare(t, "restored rate Value", r.Value(), saved.Value())
+	for i, order := range r.Derivatives() {
+		for j, val := range order {
+			testCompare(t,
+				fmt.Sprintf("restored rate Derivatives order %d time constant %d", i, j),
+				val, saved.Derivatives()[i][j],
+			)
+		}
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"metrics/rbtree"
 )
 
+// Sample keeps a set of values, in order, and their moments. A Sample
+// created by NewFloatSample holds float64 values, keyed in its tree by
+// rbtree.FloatKey, and the others int64 values.
 type Sample struct {
 	values *rbtree.Tree
+	float  bool
 
 	mean           float64
 	secondCMtimesN float64
@@ -15,7 +19,8 @@ type Sample struct {
 }
 
 type SampleElement struct {
-	node *rbtree.Node
+	node  *rbtree.Node
+	float bool
 }
 
 func NewSample() *Sample {
@@ -24,14 +29,63 @@ func NewSample() *Sample {
 	}
 }
 
+// NewFloatSample returns a Sample of float64 values.
+func NewFloatSample() *Sample {
+	return &Sample{
+		values: rbtree.New(),
+		float:  true,
+	}
+}
+
+// Float returns whether the Sample holds float64 values.
+func (s *Sample) Float() bool {
+	return s.float
+}
+
+// value returns the value represented by a key in the tree.
+func (s *Sample) value(key int64) float64 {
+	if s.float {
+		return rbtree.KeyFloat(key)
+	}
+	return float64(key)
+}
+
 func (s *Sample) Count() uint64 {
 	return s.values.Size()
 }
 
+// Add adds a value to the Sample. A float64 Sample adds it as a
+// float64.
 func (s *Sample) Add(v int64) SampleElement {
-	node := s.values.Insert(v, nil)
+	if s.float {
+		return s.AddFloat(float64(v))
+	}
+	return s.add(v, float64(v))
+}
+
+// AddFloat adds a value to the Sample. An int64 Sample adds it rounded
+// to the nearest integer. NaNs cannot be ordered, so are not added, and
+// the returned SampleElement is not valid.
+func (s *Sample) AddFloat(v float64) SampleElement {
+	if math.IsNaN(v) {
+		return SampleElement{}
+	}
+	if s.float {
+		return s.add(rbtree.FloatKey(v), v)
+	}
+	r := int64(math.Round(v))
+	return s.add(r, float64(r))
+}
+
+// Valid returns whether a SampleElement holds a value, which is false
+// for NaNs given to AddFloat.
+func (se SampleElement) Valid() bool {
+	return se.node != nil
+}
+
+func (s *Sample) add(key int64, x float64) SampleElement {
+	node := s.values.Insert(key, nil)
 
-	x := float64(v)
 	n := float64(s.Count())
 
 	// http://en.wikipedia.org/wiki/Algorithms_for_calculating_variance
@@ -51,7 +105,7 @@ func (s *Sample) Add(v int64) SampleElement {
 
 	s.secondCMtimesN += delta * deltaOverN * (n - 1.0)
 
-	return SampleElement{node}
+	return SampleElement{node, s.float}
 }
 
 func (s *Sample) Remove(se SampleElement) {
@@ -66,7 +120,7 @@ func (s *Sample) Remove(se SampleElement) {
 	}
 
 	n := float64(s.Count()) // n is at least 2
-	x := float64(se.node.Key())
+	x := se.FloatValue()
 
 	delta := n / (n - 1.0) * (x - s.mean)
 	deltaOverN := delta / n
@@ -110,6 +164,8 @@ func (s *Sample) Kurtosis() float64 {
 	return toFinite(v)
 }
 
+// Percentile returns the value whose rank is nearest to p(n-1), rounded
+// to the nearest integer if the Sample holds float64 values.
 func (s *Sample) Percentile(p float64) int64 {
 	if p < 0.0 {
 		p = 0.0
@@ -125,16 +181,36 @@ func (s *Sample) Percentile(p float64) int64 {
 	if node == nil {
 		return 0
 	}
+	if s.float {
+		return int64(math.Round(rbtree.KeyFloat(node.Key())))
+	}
 	return node.Key()
 }
 
+// Value returns the element's value, rounded to the nearest integer if
+// it is a float64.
 func (se SampleElement) Value() int64 {
+	if se.float {
+		return int64(math.Round(rbtree.KeyFloat(se.node.Key())))
+	}
 	return se.node.Key()
 }
 
+// FloatValue returns the element's value as a float64.
+func (se SampleElement) FloatValue() float64 {
+	if se.float {
+		return rbtree.KeyFloat(se.node.Key())
+	}
+	return float64(se.node.Key())
+}
+
 func toFinite(v float64) float64 {
-	if math.IsNaN(v) || math.IsInf(v, 0) {
+	if !isFinite(v) {
 		return 0
 	}
 	return v
 }
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -2,6 +2,7 @@ package statistics
 
 import (
 	"fmt"
+	"math"
 	"testing"
 )
 
@@ -24,7 +25,7 @@ func testSampleAdd(s *Sample) {
 }
 
 func testSampleRemove(s *Sample) {
-	s.Remove(SampleElement{s.values.Find(sampleToRemove)})
+	s.Remove(SampleElement{node: s.values.Find(sampleToRemove)})
 }
 
 func testWithChanges(t *testing.T,
@@ -119,3 +120,36 @@ func TestSampleRemoveLastTwo(t *testing.T) {
 			s.mean, s.secondCMtimesN, s.thirdCMtimesN, s.fourthCMtimesN, s.Count())
 	}
 }
+
+func TestFloatSample(t *testing.T) {
+	s := NewFloatSample()
+	values := []float64{0.5, -0.25, 2.75, 1.125}
+	elements := make([]SampleElement, len(values))
+	for i, v := range values {
+		elements[i] = s.AddFloat(v)
+	}
+	if s.AddFloat(math.NaN()).Valid() || s.Count() != 4 {
+		t.Errorf("NaN was added to a float sample")
+	}
+
+	testCompare(t, "float sample Mean", s.Mean(), 1.03125)
+	testCompare(t, "float sample Variance", s.Variance(), 1.62890625)
+	if q := s.Quantile(0.5, HyndmanFan7); q != 0.8125 {
+		t.Errorf("Wrong float sample median, got %v expected 0.8125", q)
+	}
+	if q := s.Quantile(1, NearestRank); q != 2.75 {
+		t.Errorf("Wrong float sample maximum, got %v expected 2.75", q)
+	}
+	if p := s.Percentile(1); p != 3 {
+		t.Errorf("Wrong rounded float sample maximum, got %d expected 3", p)
+	}
+	if v := elements[2].FloatValue(); v != 2.75 {
+		t.Errorf("Wrong element value, got %v expected 2.75", v)
+	}
+
+	s.Remove(elements[2])
+	testCompare(t, "float sample Mean", s.Mean(), 0.4583333333333333)
+	if q := s.Quantile(1, NearestRank); q != 1.125 {
+		t.Errorf("Wrong float sample maximum, got %v expected 1.125", q)
+	}
+}
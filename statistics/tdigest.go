@@ -0,0 +1,296 @@
+package statistics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// TDigest is a t-digest, a sketch of a data set from which quantiles
+// can be estimated. It is accurate near the extreme quantiles, uses
+// space bounded by its compression parameter regardless of the number
+// of values added, and can be merged with other TDigests, so that
+// sketches from many processes can be combined into one.
+//
+// See Dunning and Ertl, "Computing Extremely Accurate Quantiles
+// Using t-Digests".
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	unmerged    []centroid
+	count       float64
+	sum         float64
+	min         float64
+	max         float64
+}
+
+type centroid struct {
+	mean  float64
+	count float64
+}
+
+type centroidsByMean []centroid
+
+func (c centroidsByMean) Len() int           { return len(c) }
+func (c centroidsByMean) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c centroidsByMean) Less(i, j int) bool { return c[i].mean < c[j].mean }
+
+// tdigestVersion is the first byte of the binary form of a TDigest.
+const tdigestVersion = 1
+
+var ErrTDigestFormat = errors.New("statistics: invalid TDigest encoding")
+
+// NewTDigest creates an empty TDigest. Higher compressions are more
+// accurate but use more space; 100 is typical. The digest keeps
+// at most about compression centroids.
+func NewTDigest(compression float64) *TDigest {
+	if compression < 1 {
+		compression = 1
+	}
+	t := &TDigest{compression: compression}
+	t.Reset()
+	return t
+}
+
+// Reset removes all values from the TDigest.
+func (t *TDigest) Reset() {
+	t.centroids = t.centroids[:0]
+	t.unmerged = t.unmerged[:0]
+	t.count = 0
+	t.sum = 0
+	t.min = math.Inf(1)
+	t.max = math.Inf(-1)
+}
+
+func (t *TDigest) Compression() float64 {
+	return t.compression
+}
+
+// Add adds a value to the TDigest.
+func (t *TDigest) Add(v float64) {
+	t.AddWeighted(v, 1)
+}
+
+// AddWeighted adds a value with the given weight to the TDigest.
+// Values and weights which are not finite, and weights which are not
+// positive, are ignored, as they cannot be encoded.
+func (t *TDigest) AddWeighted(v float64, weight float64) {
+	if !isFinite(v) || !isFinite(weight) || weight <= 0 {
+		return
+	}
+	t.unmerged = append(t.unmerged, centroid{v, weight})
+	t.count += weight
+	t.sum += v * weight
+	if v < t.min {
+		t.min = v
+	}
+	if v > t.max {
+		t.max = v
+	}
+	if len(t.unmerged) >= int(5*t.compression) {
+		t.compress()
+	}
+}
+
+// Merge adds all values in o to the receiver.
+func (t *TDigest) Merge(o *TDigest) {
+	if o.count == 0 {
+		return
+	}
+	t.unmerged = append(t.unmerged, o.centroids...)
+	t.unmerged = append(t.unmerged, o.unmerged...)
+	t.count += o.count
+	t.sum += o.sum
+	if o.min < t.min {
+		t.min = o.min
+	}
+	if o.max > t.max {
+		t.max = o.max
+	}
+	t.compress()
+}
+
+// compress merges unmerged values into the centroids. Adjacent
+// centroids are combined as long as the quantiles at either edge of the
+// result differ by at most 1 under the scale function
+// k(q) = compression / (2 * pi) * asin(2q - 1), which keeps centroids
+// small near the extreme quantiles and bounds their number.
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.unmerged...)
+	sort.Sort(centroidsByMean(all))
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	cur := all[0]
+	var soFar float64
+	limit := t.count * t.kInverse(t.k(0)+1)
+	for _, c := range all[1:] {
+		if soFar+cur.count+c.count <= limit {
+			cur.mean += (c.mean - cur.mean) * c.count / (cur.count + c.count)
+			cur.count += c.count
+		} else {
+			merged = append(merged, cur)
+			soFar += cur.count
+			cur = c
+			limit = t.count * t.kInverse(t.k(soFar/t.count)+1)
+		}
+	}
+	merged = append(merged, cur)
+
+	t.centroids = merged
+	t.unmerged = t.unmerged[:0]
+}
+
+func (t *TDigest) k(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (t *TDigest) kInverse(k float64) float64 {
+	x := k * 2 * math.Pi / t.compression
+	x = math.Max(-math.Pi/2, math.Min(math.Pi/2, x))
+	return (math.Sin(x) + 1) / 2
+}
+
+// Count returns the total weight of the values added.
+func (t *TDigest) Count() float64 {
+	return t.count
+}
+
+func (t *TDigest) Mean() float64 {
+	return toFinite(t.sum / t.count)
+}
+
+func (t *TDigest) Min() float64 {
+	return toFinite(t.min)
+}
+
+func (t *TDigest) Max() float64 {
+	return toFinite(t.max)
+}
+
+// Centroids returns the number of centroids in the TDigest.
+func (t *TDigest) Centroids() int {
+	t.compress()
+	return len(t.centroids)
+}
+
+// Quantile estimates the value below which a fraction q of the
+// values fall. 0.0 is the minimum and 1.0 is the maximum.
+// Values are interpolated linearly between centroids.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+
+	if t.count == 0 || len(t.centroids) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+
+	c := t.centroids
+	index := q * t.count
+
+	// each centroid's mean is at the middle of its weight
+	weightSoFar := c[0].count / 2
+	if index < weightSoFar {
+		return t.min + (c[0].mean-t.min)*index/weightSoFar
+	}
+	for i := 0; i < len(c)-1; i++ {
+		dw := (c[i].count + c[i+1].count) / 2
+		if weightSoFar+dw > index {
+			frac := (index - weightSoFar) / dw
+			return c[i].mean + frac*(c[i+1].mean-c[i].mean)
+		}
+		weightSoFar += dw
+	}
+
+	last := c[len(c)-1]
+	frac := (index - weightSoFar) / (last.count / 2)
+	return last.mean + frac*(t.max-last.mean)
+}
+
+// MarshalBinary encodes the TDigest. The encoding is a version byte,
+// followed by the compression, count, sum, minimum and maximum, the
+// number of centroids as a uvarint, and the mean and count of each
+// centroid. Floating point numbers are IEEE 754 binary64, big-endian.
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	t.compress()
+
+	b := make([]byte, 0, 1+5*8+binary.MaxVarintLen64+16*len(t.centroids))
+	b = append(b, tdigestVersion)
+	for _, v := range []float64{t.compression, t.count, t.sum, t.min, t.max} {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	b = binary.AppendUvarint(b, uint64(len(t.centroids)))
+	for _, c := range t.centroids {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(c.mean))
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(c.count))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a TDigest encoded by MarshalBinary,
+// replacing the receiver's contents. It returns ErrTDigestFormat, and
+// leaves the receiver unchanged, if the encoding is malformed or
+// inconsistent: if the compression is not positive, a centroid's mean
+// or count is not finite, or the count is not the sum of the
+// centroids' counts.
+func (t *TDigest) UnmarshalBinary(b []byte) error {
+	if len(b) < 1+5*8 || b[0] != tdigestVersion {
+		return ErrTDigestFormat
+	}
+	b = b[1:]
+
+	readFloat := func() float64 {
+		v := math.Float64frombits(binary.BigEndian.Uint64(b))
+		b = b[8:]
+		return v
+	}
+
+	compression, count, sum := readFloat(), readFloat(), readFloat()
+	min, max := readFloat(), readFloat()
+
+	n, l := binary.Uvarint(b)
+	if l <= 0 || n > uint64(len(b)) || uint64(len(b)-l) != 16*n {
+		return ErrTDigestFormat
+	}
+	b = b[l:]
+
+	centroids := make([]centroid, n)
+	total := 0.0
+	for i := range centroids {
+		centroids[i].mean = readFloat()
+		centroids[i].count = readFloat()
+		if !isFinite(centroids[i].mean) || !isFinite(centroids[i].count) ||
+			centroids[i].count <= 0 {
+
+			return ErrTDigestFormat
+		}
+		total += centroids[i].count
+	}
+
+	if !(compression > 0) || !isFinite(compression) || !isFinite(sum) ||
+		!isFinite(count) || math.Abs(total-count) > 1e-9*count {
+
+		return ErrTDigestFormat
+	}
+	if count > 0 && !(isFinite(min) && isFinite(max) && min <= max) {
+		return ErrTDigestFormat
+	}
+
+	t.compression = compression
+	t.count = count
+	t.sum = sum
+	t.min = min
+	t.max = max
+	t.centroids = centroids
+	t.unmerged = nil
+	return nil
+}
@@ -0,0 +1,155 @@
+package statistics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func testTDigestInit(begin, end int) *TDigest {
+	t := NewTDigest(100)
+	for _, v := range rand.New(rand.NewSource(1234)).Perm(end - begin) {
+		t.Add(float64(v + begin))
+	}
+	return t
+}
+
+func testTDigestEqual(t *testing.T, name string, v, expected float64) {
+	if v != expected {
+		t.Errorf("%s is %v, should be %v.", name, v, expected)
+	}
+}
+
+func testTDigestQuantiles(t *testing.T, name string, td *TDigest, n float64) {
+	for _, q := range []float64{0.001, 0.01, 0.25, 0.5, 0.75, 0.99, 0.999} {
+		v := td.Quantile(q)
+		// accuracy is relative to the quantile, and best at the tails
+		tolerance := n * math.Max(4*q*(1-q)*0.01, 1e-3)
+		if math.Abs(v-q*n) > tolerance {
+			t.Errorf("%s Quantile(%v) is %v, should be within %v of %v.",
+				name, q, v, tolerance, q*n)
+		}
+	}
+}
+
+func TestTDigestQuantile(t *testing.T) {
+	td := testTDigestInit(0, 100000)
+	testCompare(t, "tdigest Count", td.Count(), 100000.0)
+	testCompare(t, "tdigest Mean", td.Mean(), 49999.5)
+	testTDigestEqual(t, "tdigest Quantile(0)", td.Quantile(0), 0.0)
+	testCompare(t, "tdigest Quantile(1)", td.Quantile(1), 99999.0)
+	testTDigestQuantiles(t, "tdigest", td, 100000)
+
+	if td.Centroids() > 100 {
+		t.Errorf("tdigest has %d centroids, expected at most %d",
+			td.Centroids(), 100)
+	}
+}
+
+func TestTDigestMerge(t *testing.T) {
+	td := NewTDigest(100)
+	for i := 0; i < 10; i++ {
+		td.Merge(testTDigestInit(i*10000, (i+1)*10000))
+	}
+	testCompare(t, "merged tdigest Count", td.Count(), 100000.0)
+	testTDigestEqual(t, "merged tdigest Min", td.Min(), 0.0)
+	testCompare(t, "merged tdigest Max", td.Max(), 99999.0)
+	testTDigestQuantiles(t, "merged tdigest", td, 100000)
+}
+
+func TestTDigestBinary(t *testing.T) {
+	td := testTDigestInit(0, 10000)
+	b, err := td.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Could not marshal tdigest: %v", err)
+	}
+
+	u := NewTDigest(1)
+	if err := u.UnmarshalBinary(b); err != nil {
+		t.Fatalf("Could not unmarshal tdigest: %v", err)
+	}
+	testCompare(t, "unmarshalled tdigest Compression", u.Compression(), 100.0)
+	testCompare(t, "unmarshalled tdigest Count", u.Count(), td.Count())
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		testTDigestEqual(t, fmt.Sprintf("unmarshalled tdigest Quantile(%v)", q),
+			u.Quantile(q), td.Quantile(q))
+	}
+
+	for _, bad := range [][]byte{nil, b[:20], b[:len(b)-1], append([]byte{2}, b[1:]...)} {
+		if err := u.UnmarshalBinary(bad); err == nil {
+			t.Errorf("Invalid encoding of length %d was unmarshalled", len(bad))
+		}
+	}
+}
+
+// testTDigestEncode encodes a TDigest's fields as by MarshalBinary,
+// without checking them.
+func testTDigestEncode(compression, count float64, centroids ...float64) []byte {
+	b := []byte{tdigestVersion}
+	for _, v := range []float64{compression, count, 0, 0, 1} {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	b = binary.AppendUvarint(b, uint64(len(centroids)/2))
+	for _, v := range centroids {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+func TestTDigestBinaryInconsistent(t *testing.T) {
+	for name, bad := range map[string][]byte{
+		"no centroids":     testTDigestEncode(100, 5),
+		"wrong count":      testTDigestEncode(100, 5, 0.5, 2, 1, 2),
+		"zero compression": testTDigestEncode(0, 2, 0.5, 2),
+		"NaN compression":  testTDigestEncode(math.NaN(), 2, 0.5, 2),
+		"infinite mean":    testTDigestEncode(100, 2, math.Inf(1), 2),
+		"NaN count":        testTDigestEncode(100, 2, 0.5, math.NaN()),
+		"negative count":   testTDigestEncode(100, -2, 0.5, -2),
+		"infinite total":   testTDigestEncode(100, math.Inf(1), 0.5, math.Inf(1)),
+	} {
+		u := NewTDigest(100)
+		if err := u.UnmarshalBinary(bad); err != ErrTDigestFormat {
+			t.Errorf("Inconsistent tdigest with %s was unmarshalled: %v", name, err)
+		}
+		// a rejected sketch leaves the receiver usable
+		u.Merge(NewTDigest(100))
+		testTDigestEqual(t, "rejected tdigest Quantile(0.5)", u.Quantile(0.5), 0.0)
+	}
+
+	u := NewTDigest(100)
+	if err := u.UnmarshalBinary(testTDigestEncode(100, 4, 0.5, 2, 1, 2)); err != nil {
+		t.Errorf("Could not unmarshal consistent tdigest: %v", err)
+	}
+	testTDigestEqual(t, "consistent tdigest Quantile(0.5)", u.Quantile(0.5), 0.75)
+}
+
+func TestTDigestNonFinite(t *testing.T) {
+	td := NewTDigest(100)
+	td.Add(math.Inf(1))
+	td.Add(math.NaN())
+	td.AddWeighted(1, math.Inf(1))
+	testTDigestEqual(t, "non-finite tdigest Count", td.Count(), 0.0)
+
+	td.Add(1)
+	b, _ := td.MarshalBinary()
+	if err := NewTDigest(1).UnmarshalBinary(b); err != nil {
+		t.Errorf("Could not unmarshal tdigest: %v", err)
+	}
+}
+
+func TestTDigestEmpty(t *testing.T) {
+	td := NewTDigest(100)
+	testTDigestEqual(t, "empty tdigest Quantile(0.5)", td.Quantile(0.5), 0.0)
+	testTDigestEqual(t, "empty tdigest Mean", td.Mean(), 0.0)
+	td.Add(5)
+	testTDigestEqual(t, "single value tdigest Quantile(0.5)", td.Quantile(0.5), 5.0)
+}
+
+func BenchmarkTDigestAdd(b *testing.B) {
+	td := NewTDigest(100)
+	for i := 0; i < b.N; i++ {
+		td.Add(rand.Float64())
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer measures the duration of events. It stores the durations in a
+// Distribution, in nanoseconds, and counts the events in a Meter, whose
+// rate of change is the rate of events per second.
+type Timer struct {
+	d     *Distribution
+	m     *Meter
+	unit  time.Duration
+	clock Clock
+	lock  sync.RWMutex
+}
+
+type TimerSnapshot struct {
+	Distribution DistributionSnapshot
+	Meter        MeterSnapshot
+	Unit         time.Duration
+}
+
+// TimerContext is a running measurement started by Timer#Start.
+type TimerContext struct {
+	t     *Timer
+	start time.Time
+}
+
+func newTimer(clock Clock) *Timer {
+	return &Timer{
+		d:     newDistribution(clock),
+		m:     newMeter(clock),
+		clock: clock,
+	}
+}
+
+// Reset clears a Timer's durations and count.
+func (t *Timer) Reset() {
+	t.d.Reset()
+	t.m.Reset()
+}
+
+// Distribution returns the Distribution in which the Timer stores
+// durations, e.g. to set its window.
+func (t *Timer) Distribution() *Distribution {
+	return t.d
+}
+
+// Meter returns the Meter in which the Timer counts events.
+func (t *Timer) Meter() *Meter {
+	return t.m
+}
+
+// SetUnit sets the unit in which durations are displayed, for example
+// time.Millisecond. It has no effect on the Timer, but it is exported in
+// a TimerSnapshot. If the unit is 0, the default, the dashboard chooses
+// a unit suited to each duration.
+func (t *Timer) SetUnit(unit time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.unit = unit
+}
+
+// Update records an event that took duration d.
+func (t *Timer) Update(d time.Duration) {
+	t.d.Add(int64(d))
+	t.m.Inc(1)
+}
+
+// UpdateSince records an event that started at start and ended now.
+func (t *Timer) UpdateSince(start time.Time) {
+	t.Update(t.clock.Now().Sub(start))
+}
+
+// Time calls f and records how long it took.
+func (t *Timer) Time(f func()) {
+	start := t.clock.Now()
+	defer t.UpdateSince(start)
+	f()
+}
+
+// Start starts a measurement, which is recorded by calling Stop on the
+// returned TimerContext.
+func (t *Timer) Start() TimerContext {
+	return TimerContext{t: t, start: t.clock.Now()}
+}
+
+// Stop records the duration since the TimerContext was started, and
+// returns it.
+func (tc TimerContext) Stop() time.Duration {
+	d := tc.t.clock.Now().Sub(tc.start)
+	tc.t.Update(d)
+	return d
+}
+
+// Snapshot returns the statistics of the Timer's durations, and the
+// number and rate of events.
+func (t *Timer) Snapshot() TimerSnapshot {
+	t.lock.RLock()
+	unit := t.unit
+	t.lock.RUnlock()
+
+	return TimerSnapshot{
+		Distribution: t.d.Snapshot(),
+		Meter:        t.m.Snapshot(),
+		Unit:         unit,
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"metrics/metricstest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	c := metricstest.NewClock(testTime)
+	tm := newTimer(c)
+	tm.SetUnit(time.Millisecond)
+
+	tm.Update(time.Millisecond)
+
+	c.Add(time.Second)
+	tm.Time(func() { c.Add(3 * time.Millisecond) })
+
+	tc := tm.Start()
+	c.Add(2 * time.Millisecond)
+	if d := tc.Stop(); d != 2*time.Millisecond {
+		t.Errorf("Stop returned %v, expected %v", d, 2*time.Millisecond)
+	}
+
+	tm.UpdateSince(c.Now().Add(-4 * time.Millisecond))
+
+	s := tm.Snapshot()
+	if s.Distribution.Count != 4 || s.Meter.Value != 4 {
+		t.Errorf("Wrong count, got %d, %d expected %d",
+			s.Distribution.Count, s.Meter.Value, 4)
+	}
+	if s.Distribution.Mean != float64(2500*time.Microsecond) {
+		t.Errorf("Wrong mean duration, got %f expected %d",
+			s.Distribution.Mean, 2500*time.Microsecond)
+	}
+	if s.Unit != time.Millisecond {
+		t.Errorf("Wrong unit, got %v expected %v", s.Unit, time.Millisecond)
+	}
+
+	tm.Reset()
+	s = tm.Snapshot()
+	if s.Distribution.Count != 0 || s.Meter.Value != 0 {
+		t.Errorf("Wrong count after reset, got %d, %d",
+			s.Distribution.Count, s.Meter.Value)
+	}
+}
+
+func TestTimerConcurrent(t *testing.T) {
+	tm := newTimer(SystemClock)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				tm.Update(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := tm.Snapshot(); s.Meter.Value != 8000 {
+		t.Errorf("Wrong count, got %d expected %d", s.Meter.Value, 8000)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+// metricVec is a family of metrics of the same type, distinguished
+// by their label sets. Each member is registered in the family's
+// Registry under the family's name followed by its Labels.
+type metricVec struct {
+	registry  *Registry
+	name      string
+	newMetric func() Metric
+}
+
+// CounterVec is a family of Counters distinguished by labels.
+type CounterVec struct {
+	*metricVec
+}
+
+// DistributionVec is a family of Distributions distinguished by labels.
+type DistributionVec struct {
+	*metricVec
+}
+
+// GaugeVec is a family of Gauges distinguished by labels.
+type GaugeVec struct {
+	*metricVec
+}
+
+// MeterVec is a family of Meters distinguished by labels.
+type MeterVec struct {
+	*metricVec
+}
+
+// Name returns the full name of the family, as listed by the Registry
+// which created it.
+func (v *metricVec) Name() string {
+	return v.name[len(v.registry.prefix):]
+}
+
+func (v *metricVec) withLabels(l Labels) Metric {
+	if !l.Valid() {
+		return nil
+	}
+	return v.registry.registerLabeled(v, l)
+}
+
+// typeName names the type of the family, e.g. countervec.
+func (v *metricVec) typeName() string {
+	return metricType(v.newMetric()) + "vec"
+}
+
+// Reset resets every member of the family.
+func (v *metricVec) Reset() {
+	for _, m := range v.Find() {
+		m.Reset()
+	}
+}
+
+// Find returns the members of the family whose labels satisfy all of
+// the matchers, keyed by their full names.
+func (v *metricVec) Find(matchers ...LabelMatcher) map[string]Metric {
+	return v.registry.FindLabeledS(v.Name(), matchers...)
+}
+
+// WithLabels returns the Counter with the given labels, creating it
+// if it does not exist. It returns nil if a label name is invalid.
+func (v *CounterVec) WithLabels(l Labels) *Counter {
+	m, _ := v.withLabels(l).(*Counter)
+	return m
+}
+
+// WithLabels returns the Distribution with the given labels, creating it
+// if it does not exist. It returns nil if a label name is invalid.
+func (v *DistributionVec) WithLabels(l Labels) *Distribution {
+	m, _ := v.withLabels(l).(*Distribution)
+	return m
+}
+
+// WithLabels returns the Gauge with the given labels, creating it
+// if it does not exist. It returns nil if a label name is invalid.
+func (v *GaugeVec) WithLabels(l Labels) *Gauge {
+	m, _ := v.withLabels(l).(*Gauge)
+	return m
+}
+
+// WithLabels returns the Meter with the given labels, creating it
+// if it does not exist. It returns nil if a label name is invalid.
+func (v *MeterVec) WithLabels(l Labels) *Meter {
+	m, _ := v.withLabels(l).(*Meter)
+	return m
+}